@@ -1,6 +1,21 @@
 #!/bin/bash
 set -ex
 
-GOOS=linux GOARCH=amd64 go build -a -ldflags '-s' -o lambda/aqua
+# The ed25519 private key in PEM format that signs the checksum manifest. Create
+# one with: openssl genpkey -algorithm ed25519 -out aqua-signing.pem
+: "${AQUA_SIGNING_KEY:?Please set AQUA_SIGNING_KEY to the file with the ed25519 private key for signing the release}"
+
+# The raw public key is the last 32 bytes of its DER encoding. It's embedded
+# in the binaries, so aqua install can verify the signature of the manifest.
+PUBLIC_KEY=$(openssl pkey -in "${AQUA_SIGNING_KEY}" -pubout -outform DER | tail -c 32 | base64)
+LDFLAGS="-s -X github.com/ArjenSchwarz/aqua/builder.AquaSigningKey=${PUBLIC_KEY}"
+
+GOOS=linux GOARCH=amd64 go build -a -ldflags "${LDFLAGS}" -o lambda/aqua
+go build -ldflags "${LDFLAGS}" -o aqua
 
 zip -j lambda/aqua.zip lambda/index.js lambda/aqua
+
+# The checksum manifest and its signature are published with the release,
+# next to aqua_lambda.zip
+echo "$(sha256sum lambda/aqua.zip | cut -d ' ' -f 1)  aqua_lambda.zip" > lambda/checksums.txt
+openssl pkeyutl -sign -rawin -inkey "${AQUA_SIGNING_KEY}" -in lambda/checksums.txt | base64 | tr -d '\n' > lambda/checksums.txt.sig
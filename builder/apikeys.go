@@ -1,45 +1,275 @@
 package builder
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/apigateway"
 )
 
-// ListAPIKeys shows the available API keys
-func ListAPIKeys(settings *Config) (*apigateway.GetApiKeysOutput, error) {
-	svc := apigateway.New(session.New(), &aws.Config{Region: settings.Region})
-
-	params := &apigateway.GetApiKeysInput{}
-	resp, err := svc.GetApiKeys(params)
+// ListAPIKeys returns all API keys
+func ListAPIKeys(clients *Clients) ([]*apigateway.ApiKey, error) {
+	var keys []*apigateway.ApiKey
+	err := EachAPIKey(clients, ListOptions{}, func(key *apigateway.ApiKey) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys, err
+}
 
+// CreateAPIKey creates a new API key. If an API is provided the key is added
+// to a new usage plan with the same name and the settings, which gives it
+// access to the stage of the API.
+func CreateAPIKey(clients *Clients, name string, description string, enabled bool, apiID string, stage string, settings UsagePlanSettings) (*apigateway.ApiKey, *apigateway.UsagePlan, error) {
+	params := &apigateway.CreateApiKeyInput{
+		Description: aws.String(description),
+		Enabled:     aws.Bool(enabled),
+		Name:        aws.String(name),
+	}
+	if apiID != "" {
+		return createKeyWithPlan(clients, params, aws.String(apiID), stage, settings)
+	}
+	key, err := clients.APIGateway.CreateApiKey(params)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	return key, nil, nil
+}
+
+// GetAPIKey returns the API key. The value of the key is only included if
+// reveal is true.
+func GetAPIKey(clients *Clients, keyID string, reveal bool) (*apigateway.ApiKey, error) {
+	return clients.APIGateway.GetApiKey(&apigateway.GetApiKeyInput{
+		ApiKey:       aws.String(keyID),
+		IncludeValue: aws.Bool(reveal),
+	})
+}
+
+// DeleteAPIKey deletes the API key
+func DeleteAPIKey(clients *Clients, keyID string) error {
+	_, err := clients.APIGateway.DeleteApiKey(&apigateway.DeleteApiKeyInput{
+		ApiKey: aws.String(keyID),
+	})
+	return err
+}
 
-	return resp, nil
+// SetAPIKeyEnabled enables or disables the API key
+func SetAPIKeyEnabled(clients *Clients, keyID string, enabled bool) (*apigateway.ApiKey, error) {
+	return clients.APIGateway.UpdateApiKey(&apigateway.UpdateApiKeyInput{
+		ApiKey: aws.String(keyID),
+		PatchOperations: []*apigateway.PatchOperation{{
+			Op:    aws.String("replace"),
+			Path:  aws.String("/enabled"),
+			Value: aws.String(strconv.FormatBool(enabled)),
+		}},
+	})
 }
 
-// CreateAPIKey creates a new API key
-func CreateAPIKey(name string, description string, enabled bool, apikey string, region *string) (*apigateway.ApiKey, error) {
-	svc := apigateway.New(session.New(), &aws.Config{Region: region})
+// APIKeyPlans returns the usage plans the API key is part of
+func APIKeyPlans(clients *Clients, keyID string) ([]*apigateway.UsagePlan, error) {
+	var plans []*apigateway.UsagePlan
+	err := clients.APIGateway.GetUsagePlansPages(&apigateway.GetUsagePlansInput{
+		KeyId: aws.String(keyID),
+	}, func(page *apigateway.GetUsagePlansOutput, lastPage bool) bool {
+		plans = append(plans, page.Items...)
+		return true
+	})
+	return plans, err
+}
+
+// RotateAPIKey creates a replacement for the API key, with the same name,
+// description, stages, and usage plans. The old key is left alone, so it can
+// be disabled once everyone has switched to the new one. If the new key can't
+// be added to all the usage plans it is removed again, and when that fails as
+// well the error names it so it can be cleaned up.
+func RotateAPIKey(clients *Clients, keyID string) (*apigateway.ApiKey, []*apigateway.UsagePlan, error) {
+	old, err := GetAPIKey(clients, keyID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	plans, err := APIKeyPlans(clients, keyID)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	params := &apigateway.CreateApiKeyInput{
-		Description: aws.String(description),
-		Enabled:     aws.Bool(enabled),
-		Name:        aws.String(name),
+		Name:        old.Name,
+		Description: old.Description,
+		Enabled:     aws.Bool(true),
 	}
-	if apikey != "" {
-		stagekey := &apigateway.StageKey{
-			RestApiId: aws.String(apikey),
-			StageName: aws.String("prod"),
+	// Stage keys are listed as apiId/stage
+	for _, stageKey := range old.StageKeys {
+		parts := strings.SplitN(aws.StringValue(stageKey), "/", 2)
+		if len(parts) == 2 {
+			params.StageKeys = append(params.StageKeys, &apigateway.StageKey{
+				RestApiId: aws.String(parts[0]),
+				StageName: aws.String(parts[1]),
+			})
 		}
-		params.StageKeys = append(params.StageKeys, stagekey)
 	}
-	resp, err := svc.CreateApiKey(params)
+	key, err := clients.APIGateway.CreateApiKey(params)
+	if err != nil {
+		return nil, nil, err
+	}
+	var attached []*apigateway.UsagePlan
+	for _, plan := range plans {
+		if err = AttachAPIKey(clients, plan.Id, aws.StringValue(key.Id)); err != nil {
+			err = fmt.Errorf("Unable to add the new API key to usage plan %s: %s", aws.StringValue(plan.Name), err.Error())
+			if removeErr := removeAPIKey(clients, aws.StringValue(key.Id), attached); removeErr != nil {
+				return nil, nil, fmt.Errorf("%s\nRemoving the new API key %s failed as well, please delete it yourself: %s",
+					err.Error(), aws.StringValue(key.Id), removeErr.Error())
+			}
+			return nil, nil, err
+		}
+		attached = append(attached, plan)
+	}
+	return key, plans, nil
+}
+
+// DisableAfterTag is the tag on an API key that records when it should be
+// disabled, in RFC 3339 format
+const DisableAfterTag = "aqua:disable-after"
 
+// waitFor pauses for the grace period of a rotated key, tests replace it
+var waitFor = time.Sleep
+
+// DisableAPIKeyAfter waits for the grace period and then disables the API key
+func DisableAPIKeyAfter(clients *Clients, keyID string, grace time.Duration) (*apigateway.ApiKey, error) {
+	waitFor(grace)
+	return SetAPIKeyEnabled(clients, keyID, false)
+}
+
+// ScheduleAPIKeyDisable records on the API key, in the DisableAfterTag tag,
+// that it should be disabled once the grace period has passed. The key is
+// disabled by DisableExpiredAPIKeys. It returns the time the key expires.
+func ScheduleAPIKeyDisable(clients *Clients, region string, keyID string, grace time.Duration) (time.Time, error) {
+	expires := time.Now().UTC().Add(grace).Truncate(time.Second)
+	_, err := clients.APIGateway.TagResource(&apigateway.TagResourceInput{
+		ResourceArn: aws.String(apiKeyARN(region, keyID)),
+		Tags:        map[string]*string{DisableAfterTag: aws.String(expires.Format(time.RFC3339))},
+	})
+	return expires, err
+}
+
+// DisableExpiredAPIKeys disables the API keys whose scheduled disable time has
+// passed and removes the schedule from them. It returns the disabled keys.
+func DisableExpiredAPIKeys(clients *Clients, region string, now time.Time) ([]*apigateway.ApiKey, error) {
+	var expired []*apigateway.ApiKey
+	var problems []string
+	err := EachAPIKey(clients, ListOptions{}, func(key *apigateway.ApiKey) bool {
+		value, ok := key.Tags[DisableAfterTag]
+		if !ok {
+			return true
+		}
+		expires, err := time.Parse(time.RFC3339, aws.StringValue(value))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("API key %s has an invalid %s tag: %s", aws.StringValue(key.Id), DisableAfterTag, aws.StringValue(value)))
+			return true
+		}
+		if expires.After(now) {
+			return true
+		}
+		expired = append(expired, key)
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
-	return resp, nil
+	var disabled []*apigateway.ApiKey
+	for _, key := range expired {
+		updated, err := SetAPIKeyEnabled(clients, aws.StringValue(key.Id), false)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Unable to disable API key %s: %s", aws.StringValue(key.Id), err.Error()))
+			continue
+		}
+		_, err = clients.APIGateway.UntagResource(&apigateway.UntagResourceInput{
+			ResourceArn: aws.String(apiKeyARN(region, aws.StringValue(key.Id))),
+			TagKeys:     []*string{aws.String(DisableAfterTag)},
+		})
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Disabled API key %s, but unable to remove its %s tag: %s", aws.StringValue(key.Id), DisableAfterTag, err.Error()))
+		}
+		disabled = append(disabled, updated)
+	}
+	if len(problems) != 0 {
+		return disabled, errors.New(strings.Join(problems, "\n"))
+	}
+	return disabled, nil
+}
+
+// apiKeyARN returns the ARN of the API key, which is used for its tags
+func apiKeyARN(region string, keyID string) string {
+	return fmt.Sprintf("arn:aws:apigateway:%s::/apikeys/%s", region, keyID)
+}
+
+// removeAPIKey detaches the API key from the usage plans and deletes it
+func removeAPIKey(clients *Clients, keyID string, plans []*apigateway.UsagePlan) error {
+	for _, plan := range plans {
+		if err := DetachAPIKey(clients, plan.Id, keyID); err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return DeleteAPIKey(clients, keyID)
+}
+
+// KeyUsage is the number of requests made with an API key on a single day,
+// as counted by a usage plan
+type KeyUsage struct {
+	Date      string
+	Plan      string
+	Used      int64
+	Remaining int64
+}
+
+// usageDateFormat is the format of the dates used by GetUsage
+const usageDateFormat = "2006-01-02"
+
+// GetAPIKeyUsage returns the number of requests made with the API key per day
+// for every usage plan it is part of, over the provided number of days up to
+// and including today
+func GetAPIKeyUsage(clients *Clients, keyID string, days int) ([]KeyUsage, error) {
+	if days < 1 {
+		return nil, fmt.Errorf("The number of days has to be at least 1, not %d", days)
+	}
+	plans, err := APIKeyPlans(clients, keyID)
+	if err != nil {
+		return nil, err
+	}
+	end := time.Now().UTC()
+	start := end.AddDate(0, 0, 1-days)
+	var usage []KeyUsage
+	for _, plan := range plans {
+		// The days continue from one page to the next
+		day := 0
+		err = clients.APIGateway.GetUsagePages(&apigateway.GetUsageInput{
+			UsagePlanId: plan.Id,
+			KeyId:       aws.String(keyID),
+			StartDate:   aws.String(start.Format(usageDateFormat)),
+			EndDate:     aws.String(end.Format(usageDateFormat)),
+		}, func(page *apigateway.Usage, lastPage bool) bool {
+			// Every day has a pair of the used and remaining requests
+			for _, requests := range page.Items[keyID] {
+				date := start.AddDate(0, 0, day).Format(usageDateFormat)
+				day++
+				if len(requests) != 2 {
+					continue
+				}
+				usage = append(usage, KeyUsage{
+					Date:      date,
+					Plan:      aws.StringValue(plan.Name),
+					Used:      aws.Int64Value(requests[0]),
+					Remaining: aws.Int64Value(requests[1]),
+				})
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return usage, nil
 }
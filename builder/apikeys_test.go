@@ -0,0 +1,225 @@
+package builder
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+func TestRotateAPIKey(t *testing.T) {
+	tests := map[string]struct {
+		fail     map[string]error
+		rotated  bool
+		leftOver bool
+	}{
+		"rotated":            {rotated: true},
+		"attach fails":       {fail: map[string]error{"CreateUsagePlanKey": errors.New("limit exceeded")}},
+		"removing fails":     {fail: map[string]error{"CreateUsagePlanKey": errors.New("limit exceeded"), "DeleteApiKey": errors.New("throttled")}, leftOver: true},
+		"lookup fails":       {fail: map[string]error{"GetUsagePlans": errors.New("throttled")}},
+		"creating key fails": {fail: map[string]error{"CreateApiKey": errors.New("throttled")}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.apiKeys["old"] = &apigateway.ApiKey{
+				Id:        aws.String("old"),
+				Name:      aws.String("client"),
+				Enabled:   aws.Bool(true),
+				StageKeys: []*string{aws.String("api/prod")},
+			}
+			account.addUsagePlan("basic", "old")
+			account.addUsagePlan("premium", "old")
+			account.fail = test.fail
+
+			key, plans, err := RotateAPIKey(clients, "old")
+			if !test.rotated {
+				if err == nil {
+					t.Fatal("expected the rotation to fail")
+				}
+				if key != nil || plans != nil {
+					t.Error("expected no key or plans when the rotation fails")
+				}
+				if len(account.apiKeys) != 1 && !test.leftOver {
+					t.Errorf("expected the new key to be removed, found %d keys", len(account.apiKeys))
+				}
+				if test.leftOver {
+					for id := range account.apiKeys {
+						if id != "old" && !strings.Contains(err.Error(), id) {
+							t.Errorf("expected the error to name the left over key %s: %s", id, err)
+						}
+					}
+				}
+				for id, keys := range account.planKeys {
+					if len(keys) != 1 {
+						t.Errorf("expected usage plan %s to only have the old key, got %v", id, keys)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plans) != 2 {
+				t.Errorf("expected 2 usage plans, got %d", len(plans))
+			}
+			if aws.StringValue(key.Name) != "client" || len(key.StageKeys) != 1 {
+				t.Errorf("expected the new key to copy the old one, got %v", key)
+			}
+			for id, keys := range account.planKeys {
+				if !contains(keys, aws.StringValue(key.Id)) {
+					t.Errorf("expected usage plan %s to have the new key", id)
+				}
+			}
+			if !aws.BoolValue(account.apiKeys["old"].Enabled) {
+				t.Error("expected the old key to stay enabled")
+			}
+		})
+	}
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	tests := map[string]struct {
+		apiID string
+		plan  bool
+	}{
+		"without API": {},
+		"with API":    {apiID: "api", plan: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			key, plan, err := CreateAPIKey(clients, "client", "A client", false, test.apiID, DefaultStage, UsagePlanSettings{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if aws.StringValue(key.Description) != "A client" || aws.BoolValue(key.Enabled) {
+				t.Errorf("expected a disabled key with the description, got %v", key)
+			}
+			if len(key.StageKeys) != 0 {
+				t.Errorf("expected no stage keys, got %v", aws.StringValueSlice(key.StageKeys))
+			}
+			if (plan != nil) != test.plan {
+				t.Fatalf("expected a usage plan %t, got %v", test.plan, plan)
+			}
+			if !test.plan {
+				if len(account.usagePlans) != 0 {
+					t.Errorf("expected no usage plans, got %d", len(account.usagePlans))
+				}
+				return
+			}
+			if aws.StringValue(plan.Name) != "client" || len(plan.ApiStages) != 1 || aws.StringValue(plan.ApiStages[0].Stage) != DefaultStage {
+				t.Errorf("expected a client plan for the %s stage, got %v", DefaultStage, plan)
+			}
+			if !contains(account.planKeys[aws.StringValue(plan.Id)], aws.StringValue(key.Id)) {
+				t.Error("expected the key to be added to the plan")
+			}
+		})
+	}
+}
+
+func TestGetAPIKeyUsage(t *testing.T) {
+	clients, account := newFakeClients()
+	account.apiKeys["key"] = &apigateway.ApiKey{Id: aws.String("key"), Name: aws.String("client")}
+	plan := account.addUsagePlan("basic", "key")
+	// Three days take two pages
+	account.usage[aws.StringValue(plan.Id)+"/key"] = [][]*int64{
+		{aws.Int64(1), aws.Int64(99)},
+		{aws.Int64(2), aws.Int64(98)},
+		{aws.Int64(3), aws.Int64(97)},
+	}
+
+	usage, err := GetAPIKeyUsage(clients, "key", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := account.called("GetUsage"); got != 2 {
+		t.Errorf("expected the usage to take 2 pages, got %d", got)
+	}
+	today := time.Now().UTC()
+	var want []KeyUsage
+	for day := int64(1); day <= 3; day++ {
+		want = append(want, KeyUsage{
+			Date:      today.AddDate(0, 0, int(day)-3).Format(usageDateFormat),
+			Plan:      "basic",
+			Used:      day,
+			Remaining: 100 - day,
+		})
+	}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("expected usage %v, got %v", want, usage)
+	}
+}
+
+func TestDisableAPIKeyAfter(t *testing.T) {
+	clients, account := newFakeClients()
+	account.apiKeys["old"] = &apigateway.ApiKey{Id: aws.String("old"), Enabled: aws.Bool(true)}
+	var waited time.Duration
+	waitFor = func(grace time.Duration) {
+		if !aws.BoolValue(account.apiKeys["old"].Enabled) {
+			t.Error("expected the key to stay enabled during the grace period")
+		}
+		waited += grace
+	}
+	defer func() { waitFor = time.Sleep }()
+
+	key, err := DisableAPIKeyAfter(clients, "old", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waited != time.Hour {
+		t.Errorf("expected to wait for the grace period of 1h, waited %s", waited)
+	}
+	if aws.BoolValue(key.Enabled) {
+		t.Error("expected the key to be disabled after the grace period")
+	}
+}
+
+func TestScheduleAPIKeyDisable(t *testing.T) {
+	clients, account := newFakeClients()
+	for _, id := range []string{"old", "recent", "other"} {
+		account.apiKeys[id] = &apigateway.ApiKey{Id: aws.String(id), Enabled: aws.Bool(true)}
+	}
+	expires, err := ScheduleAPIKeyDisable(clients, account.region, "old", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ScheduleAPIKeyDisable(clients, account.region, "recent", 48*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got := aws.StringValue(account.apiKeys["old"].Tags[DisableAfterTag]); got != expires.Format(time.RFC3339) {
+		t.Errorf("expected the key to be tagged with %s, got %q", expires.Format(time.RFC3339), got)
+	}
+
+	// Before the grace period has passed nothing is disabled
+	disabled, err := DisableExpiredAPIKeys(clients, account.region, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(disabled) != 0 {
+		t.Errorf("expected no keys to be disabled yet, got %d", len(disabled))
+	}
+
+	disabled, err = DisableExpiredAPIKeys(clients, account.region, expires.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(disabled) != 1 || aws.StringValue(disabled[0].Id) != "old" {
+		t.Fatalf("expected only the old key to be disabled, got %v", disabled)
+	}
+	for id, enabled := range map[string]bool{"old": false, "recent": true, "other": true} {
+		if got := aws.BoolValue(account.apiKeys[id].Enabled); got != enabled {
+			t.Errorf("expected key %s enabled %t, got %t", id, enabled, got)
+		}
+	}
+	if _, ok := account.apiKeys["old"].Tags[DisableAfterTag]; ok {
+		t.Error("expected the schedule to be removed from the disabled key")
+	}
+	if _, ok := account.apiKeys["recent"].Tags[DisableAfterTag]; !ok {
+		t.Error("expected the schedule of the recent key to stay")
+	}
+}
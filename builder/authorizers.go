@@ -0,0 +1,399 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// SupportedAuthorizerTypes are the types of Lambda authorizers. TOKEN
+// authorizers receive the value of a single header, REQUEST authorizers the
+// full request.
+var SupportedAuthorizerTypes = []string{"TOKEN", "REQUEST"}
+
+// cognitoAuthorizerType is the type of authorizers, and the authorization type
+// of methods, that use Cognito user pools
+const cognitoAuthorizerType = "COGNITO_USER_POOLS"
+
+// DefaultAuthorizerIdentity is the header that identifies the caller if none
+// is provided
+const DefaultAuthorizerIdentity = "Authorization"
+
+// DefaultAuthorizerTTL is the number of seconds API Gateway caches the result
+// of an authorizer if none is provided
+const DefaultAuthorizerTTL = 300
+
+var userPoolARN = regexp.MustCompile(`^arn:aws[a-z-]*:cognito-idp:[a-z0-9-]+:[0-9]{12}:userpool/[a-z0-9-]+_[a-zA-Z0-9]+$`)
+
+// hasAuthorizer checks if the methods should be protected by a Lambda
+// authorizer
+func (config Config) hasAuthorizer() bool {
+	return aws.StringValue(config.AuthorizerFunction) != ""
+}
+
+// userPools returns the ARNs of the Cognito user pools that authorize the
+// methods
+func (config Config) userPools() []string {
+	var pools []string
+	if config.UserPools != nil {
+		for _, pool := range *config.UserPools {
+			if pool = strings.TrimSpace(pool); pool != "" {
+				pools = append(pools, pool)
+			}
+		}
+	}
+	return pools
+}
+
+// usesCognito checks if the methods should be protected by Cognito user pools
+func (config Config) usesCognito() bool {
+	return len(config.userPools()) > 0
+}
+
+// oauthScopes returns the OAuth scopes a Cognito access token needs for the
+// methods
+func (config Config) oauthScopes() []string {
+	var scopes []string
+	if config.OAuthScopes != nil {
+		for _, scope := range *config.OAuthScopes {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// authorizerType returns the type of the Lambda authorizer, TOKEN if none is
+// provided
+func (config Config) authorizerType() string {
+	if authorizerType := strings.ToUpper(aws.StringValue(config.AuthorizerType)); authorizerType != "" {
+		return authorizerType
+	}
+	return "TOKEN"
+}
+
+// authorizerTTL returns the number of seconds the result of the authorizer is
+// cached. A TTL of 0 disables caching.
+func (config Config) authorizerTTL() int64 {
+	if config.AuthorizerTTL == nil {
+		return DefaultAuthorizerTTL
+	}
+	return aws.Int64Value(config.AuthorizerTTL)
+}
+
+// ValidateAuthorizer checks that the authentication method and the settings
+// of the authorizers agree with each other, and returns all the problems it
+// finds
+func (config Config) ValidateAuthorizer() error {
+	var problems []string
+	authentication := aws.StringValue(config.Authentication)
+	switch {
+	case config.hasAuthorizer() && config.usesCognito():
+		problems = append(problems, "Please use either a Lambda authorizer or Cognito user pools, not both")
+	case config.hasAuthorizer() && authentication != "" && authentication != "NONE" && authentication != "CUSTOM":
+		problems = append(problems, fmt.Sprintf("A Lambda authorizer can't be combined with %s authentication", authentication))
+	case config.usesCognito() && authentication != "" && authentication != "NONE" && authentication != cognitoAuthorizerType:
+		problems = append(problems, fmt.Sprintf("Cognito user pools can't be combined with %s authentication", authentication))
+	case authentication == "CUSTOM" && !config.hasAuthorizer():
+		problems = append(problems, "CUSTOM authentication needs a Lambda authorizer, please provide it with --authorizer-function")
+	case authentication == cognitoAuthorizerType && !config.usesCognito():
+		problems = append(problems, "COGNITO_USER_POOLS authentication needs a user pool, please provide it with --cognito-user-pool")
+	}
+	if len(config.oauthScopes()) > 0 && !config.usesCognito() {
+		problems = append(problems, "OAuth scopes can only be required with Cognito user pools, please provide them with --cognito-user-pool")
+	}
+	for _, pool := range config.userPools() {
+		if !userPoolARN.MatchString(pool) {
+			problems = append(problems, fmt.Sprintf("%s is not the ARN of a Cognito user pool", pool))
+		}
+	}
+	headers := config.identityHeaders()
+	if config.usesCognito() && len(headers) != 1 {
+		problems = append(problems, "Cognito user pools need exactly one identity header, please provide it with --authorizer-identity")
+	}
+	if config.hasAuthorizer() {
+		if !contains(SupportedAuthorizerTypes, config.authorizerType()) {
+			problems = append(problems, fmt.Sprintf("%s is not a supported authorizer type, please use one of %s",
+				config.authorizerType(), strings.Join(SupportedAuthorizerTypes, ", ")))
+		}
+		if ttl := config.authorizerTTL(); ttl < 0 || ttl > 3600 {
+			problems = append(problems, fmt.Sprintf("The authorizer TTL has to be between 0 and 3600 seconds, not %d", ttl))
+		}
+		if config.authorizerType() == "TOKEN" && len(headers) != 1 {
+			problems = append(problems, "A TOKEN authorizer needs exactly one identity header, please provide it with --authorizer-identity")
+		}
+		// Cached results are looked up by the identity sources
+		if config.authorizerType() == "REQUEST" && len(headers) == 0 && config.authorizerTTL() > 0 {
+			problems = append(problems, "A REQUEST authorizer with caching needs at least one identity header, please provide them with --authorizer-identity or set --authorizer-ttl to 0")
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// identityHeaders returns the names of the headers that identify the caller
+func (config Config) identityHeaders() []string {
+	var headers []string
+	for _, header := range strings.Split(aws.StringValue(config.AuthorizerIdentity), ",") {
+		if header = strings.TrimSpace(header); header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
+// identitySource returns the identity headers as the identity source of an
+// authorizer
+func (config Config) identitySource() string {
+	var sources []string
+	for _, header := range config.identityHeaders() {
+		sources = append(sources, "method.request.header."+header)
+	}
+	return strings.Join(sources, ",")
+}
+
+// authorizerName returns the name Aqua gives the Lambda authorizer of the API
+func (builder *GatewayBuilder) authorizerName() string {
+	return fmt.Sprintf("%sAuthorizer", builder.Settings.CleanName())
+}
+
+// cognitoAuthorizerName returns the name Aqua gives the Cognito authorizer of
+// the API
+func (builder *GatewayBuilder) cognitoAuthorizerName() string {
+	return fmt.Sprintf("%sCognitoAuthorizer", builder.Settings.CleanName())
+}
+
+// authorizationType returns the authorization type of the methods
+func (builder *GatewayBuilder) authorizationType() *string {
+	switch {
+	case builder.Authorizer == nil:
+		return builder.Settings.Authentication
+	case builder.Settings.usesCognito():
+		return aws.String(cognitoAuthorizerType)
+	default:
+		return aws.String("CUSTOM")
+	}
+}
+
+// authorizerID returns the ID of the authorizer of the methods, if there is one
+func (builder *GatewayBuilder) authorizerID() *string {
+	if builder.Authorizer != nil {
+		return builder.Authorizer.Id
+	}
+	return nil
+}
+
+// authorizationScopes returns the OAuth scopes of the methods, which only
+// apply to methods protected by Cognito user pools
+func (builder *GatewayBuilder) authorizationScopes() []*string {
+	if builder.Authorizer == nil || !builder.Settings.usesCognito() {
+		return nil
+	}
+	return aws.StringSlice(builder.Settings.oauthScopes())
+}
+
+// EnsureAuthorizer creates or updates the authorizer of the API. Without a
+// Lambda authorizer or Cognito user pools in the settings it does nothing.
+func (builder *GatewayBuilder) EnsureAuthorizer() error {
+	switch {
+	case builder.Settings.usesCognito():
+		return builder.ensureCognitoAuthorizer()
+	case builder.Settings.hasAuthorizer():
+		return builder.ensureLambdaAuthorizer()
+	}
+	return nil
+}
+
+// ensureLambdaAuthorizer creates or updates the Lambda authorizer of the API,
+// and allows API Gateway to invoke the authorizer function
+func (builder *GatewayBuilder) ensureLambdaAuthorizer() error {
+	settings := builder.Settings
+	svc := builder.Clients.APIGateway
+	function, err := builder.Clients.Lambda.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: settings.AuthorizerFunction,
+	})
+	if err != nil {
+		return err
+	}
+	uri := settings.authorizerURI(function)
+
+	existing, err := builder.findAuthorizer(builder.authorizerName())
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		params := &apigateway.CreateAuthorizerInput{
+			Name:                         aws.String(builder.authorizerName()),
+			RestApiId:                    builder.APIGateway.Id,
+			Type:                         aws.String(settings.authorizerType()),
+			AuthorizerUri:                aws.String(uri),
+			AuthorizerResultTtlInSeconds: aws.Int64(settings.authorizerTTL()),
+		}
+		if identitySource := settings.identitySource(); identitySource != "" {
+			params.IdentitySource = aws.String(identitySource)
+		}
+		if err = builder.createAuthorizer(params); err != nil {
+			return err
+		}
+	} else {
+		builder.Authorizer, err = svc.UpdateAuthorizer(&apigateway.UpdateAuthorizerInput{
+			RestApiId:    builder.APIGateway.Id,
+			AuthorizerId: existing.Id,
+			PatchOperations: []*apigateway.PatchOperation{
+				replaceOperation("/type", settings.authorizerType()),
+				replaceOperation("/authorizerUri", uri),
+				replaceOperation("/identitySource", settings.identitySource()),
+				replaceOperation("/authorizerResultTtlInSeconds", strconv.FormatInt(settings.authorizerTTL(), 10)),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return builder.addPermission(&lambda.AddPermissionInput{
+		Action:       aws.String("lambda:InvokeFunction"),
+		FunctionName: function.FunctionName,
+		Principal:    aws.String("apigateway.amazonaws.com"),
+		StatementId: aws.String(fmt.Sprintf("apigateway-authorizer-%s-%s",
+			aws.StringValue(builder.APIGateway.Id), aws.StringValue(builder.Authorizer.Id))),
+		SourceArn: aws.String(fmt.Sprintf("%s/authorizers/%s", builder.APIARN(), aws.StringValue(builder.Authorizer.Id))),
+	})
+}
+
+// ensureCognitoAuthorizer creates or updates the authorizer of the API that
+// accepts the tokens of the Cognito user pools
+func (builder *GatewayBuilder) ensureCognitoAuthorizer() error {
+	settings := builder.Settings
+	existing, err := builder.findAuthorizer(builder.cognitoAuthorizerName())
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return builder.createAuthorizer(&apigateway.CreateAuthorizerInput{
+			Name:           aws.String(builder.cognitoAuthorizerName()),
+			RestApiId:      builder.APIGateway.Id,
+			Type:           aws.String(cognitoAuthorizerType),
+			ProviderARNs:   aws.StringSlice(settings.userPools()),
+			IdentitySource: aws.String(settings.identitySource()),
+		})
+	}
+
+	// The user pools can only be changed one at a time
+	operations := []*apigateway.PatchOperation{
+		replaceOperation("/identitySource", settings.identitySource()),
+	}
+	current := aws.StringValueSlice(existing.ProviderARNs)
+	for _, pool := range settings.userPools() {
+		if !contains(current, pool) {
+			operations = append(operations, &apigateway.PatchOperation{
+				Op: aws.String("add"), Path: aws.String("/providerARNs"), Value: aws.String(pool),
+			})
+		}
+	}
+	for _, pool := range current {
+		if !contains(settings.userPools(), pool) {
+			operations = append(operations, &apigateway.PatchOperation{
+				Op: aws.String("remove"), Path: aws.String("/providerARNs"), Value: aws.String(pool),
+			})
+		}
+	}
+	builder.Authorizer, err = builder.Clients.APIGateway.UpdateAuthorizer(&apigateway.UpdateAuthorizerInput{
+		RestApiId:       builder.APIGateway.Id,
+		AuthorizerId:    existing.Id,
+		PatchOperations: operations,
+	})
+	return err
+}
+
+// authorizerURI returns the URI API Gateway uses to invoke the authorizer
+// function
+func (config Config) authorizerURI(function *lambda.FunctionConfiguration) string {
+	return fmt.Sprintf("arn:aws:apigateway:%s:lambda:path/2015-03-31/functions/%s/invocations",
+		aws.StringValue(config.Region), aws.StringValue(function.FunctionArn))
+}
+
+// authorizerChanged looks up the authorizer the methods should use, and checks
+// if it's missing or its configuration differs from the settings. The
+// authorizer that is found is attached to the GatewayBuilder.
+func (builder *GatewayBuilder) authorizerChanged() (bool, error) {
+	settings := builder.Settings
+	switch {
+	case settings.usesCognito():
+		existing, err := builder.findAuthorizer(builder.cognitoAuthorizerName())
+		if err != nil || existing == nil {
+			return true, err
+		}
+		builder.Authorizer = existing
+		return aws.StringValue(existing.IdentitySource) != settings.identitySource() ||
+			!sameStrings(aws.StringValueSlice(existing.ProviderARNs), settings.userPools()), nil
+	case settings.hasAuthorizer():
+		function, err := builder.Clients.Lambda.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+			FunctionName: settings.AuthorizerFunction,
+		})
+		if err != nil {
+			if isNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		existing, err := builder.findAuthorizer(builder.authorizerName())
+		if err != nil || existing == nil {
+			return true, err
+		}
+		builder.Authorizer = existing
+		return aws.StringValue(existing.Type) != settings.authorizerType() ||
+			aws.StringValue(existing.AuthorizerUri) != settings.authorizerURI(function) ||
+			aws.StringValue(existing.IdentitySource) != settings.identitySource() ||
+			aws.Int64Value(existing.AuthorizerResultTtlInSeconds) != settings.authorizerTTL(), nil
+	}
+	return false, nil
+}
+
+// createAuthorizer creates the authorizer and attaches it to the
+// GatewayBuilder
+func (builder *GatewayBuilder) createAuthorizer(params *apigateway.CreateAuthorizerInput) error {
+	authorizer, err := builder.Clients.APIGateway.CreateAuthorizer(params)
+	if err != nil {
+		return err
+	}
+	builder.Authorizer = authorizer
+	builder.undoAuthorizer(authorizer)
+	return nil
+}
+
+func replaceOperation(path string, value string) *apigateway.PatchOperation {
+	return &apigateway.PatchOperation{Op: aws.String("replace"), Path: aws.String(path), Value: aws.String(value)}
+}
+
+// findAuthorizer returns the authorizer of the API with the name, or nil if
+// there is none
+func (builder *GatewayBuilder) findAuthorizer(name string) (*apigateway.Authorizer, error) {
+	params := &apigateway.GetAuthorizersInput{
+		RestApiId: builder.APIGateway.Id,
+	}
+	for {
+		resp, err := builder.Clients.APIGateway.GetAuthorizers(params)
+		if err != nil {
+			return nil, err
+		}
+		for _, authorizer := range resp.Items {
+			if aws.StringValue(authorizer.Name) == name {
+				return authorizer, nil
+			}
+		}
+		if aws.StringValue(resp.Position) == "" {
+			return nil, nil
+		}
+		params.Position = resp.Position
+	}
+}
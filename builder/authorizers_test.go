@@ -0,0 +1,301 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// checkProblems checks that the error mentions every problem, or that there
+// is no error if there are no problems
+func checkProblems(t *testing.T, err error, problems []string) {
+	t.Helper()
+	if len(problems) == 0 {
+		if err != nil {
+			t.Errorf("got error %q, want none", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Fatal("invalid settings were accepted")
+	}
+	for _, problem := range problems {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("the error doesn't mention %q:\n%s", problem, err)
+		}
+	}
+}
+
+func TestValidateLambdaAuthorizer(t *testing.T) {
+	tests := map[string]struct {
+		settings func(*Config)
+		problems []string
+	}{
+		"token": {
+			settings: func(settings *Config) { settings.AuthorizerFunction = aws.String("auth") },
+		},
+		"custom authentication": {
+			settings: func(settings *Config) {
+				settings.AuthorizerFunction = aws.String("auth")
+				settings.Authentication = aws.String("CUSTOM")
+			},
+		},
+		"request without caching": {
+			settings: func(settings *Config) {
+				settings.AuthorizerFunction = aws.String("auth")
+				settings.AuthorizerType = aws.String("request")
+				settings.AuthorizerIdentity = aws.String("")
+				settings.AuthorizerTTL = aws.Int64(0)
+			},
+		},
+		"iam authentication": {
+			settings: func(settings *Config) {
+				settings.AuthorizerFunction = aws.String("auth")
+				settings.Authentication = aws.String("AWS_IAM")
+			},
+			problems: []string{"A Lambda authorizer can't be combined with AWS_IAM authentication"},
+		},
+		"custom without authorizer": {
+			settings: func(settings *Config) { settings.Authentication = aws.String("CUSTOM") },
+			problems: []string{"CUSTOM authentication needs a Lambda authorizer"},
+		},
+		"invalid settings": {
+			settings: func(settings *Config) {
+				settings.AuthorizerFunction = aws.String("auth")
+				settings.AuthorizerType = aws.String("COOKIE")
+				settings.AuthorizerTTL = aws.Int64(3601)
+			},
+			problems: []string{
+				"COOKIE is not a supported authorizer type",
+				"The authorizer TTL has to be between 0 and 3600 seconds, not 3601",
+			},
+		},
+		"token with two headers": {
+			settings: func(settings *Config) {
+				settings.AuthorizerFunction = aws.String("auth")
+				settings.AuthorizerIdentity = aws.String("Authorization, X-Token")
+			},
+			problems: []string{"A TOKEN authorizer needs exactly one identity header"},
+		},
+		"cached request without headers": {
+			settings: func(settings *Config) {
+				settings.AuthorizerFunction = aws.String("auth")
+				settings.AuthorizerType = aws.String("REQUEST")
+				settings.AuthorizerIdentity = aws.String("")
+			},
+			problems: []string{"A REQUEST authorizer with caching needs at least one identity header"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			settings := testSettings()
+			settings.AuthorizerIdentity = aws.String(DefaultAuthorizerIdentity)
+			test.settings(settings)
+			checkProblems(t, settings.ValidateAuthorizer(), test.problems)
+		})
+	}
+}
+
+// addAuthorizerFunction creates the function that authorizes the requests
+func addAuthorizerFunction(t *testing.T, clients *Clients) {
+	t.Helper()
+	settings := testSettings()
+	settings.FunctionName = aws.String("auth")
+	settings.NoGateway = aws.Bool(true)
+	if err := (&GatewayBuilder{Settings: settings, Clients: clients}).Build(); err != nil {
+		t.Fatalf("creating the authorizer function failed: %s", err)
+	}
+}
+
+func TestBuildLambdaAuthorizer(t *testing.T) {
+	clients, account := newFakeClients()
+	account.addRole("lambda-basic")
+	addAuthorizerFunction(t, clients)
+	build := func(change func(*Config)) {
+		t.Helper()
+		settings := testSettings()
+		settings.AuthorizerFunction = aws.String("auth")
+		settings.AuthorizerIdentity = aws.String("X-Token")
+		if change != nil {
+			change(settings)
+		}
+		if err := (&GatewayBuilder{Settings: settings, Clients: clients}).Build(); err != nil {
+			t.Fatalf("Build failed: %s", err)
+		}
+	}
+	build(nil)
+
+	api := account.api()
+	if len(api.authorizers) != 1 {
+		t.Fatalf("got %d authorizers, want 1", len(api.authorizers))
+	}
+	var id string
+	for only := range api.authorizers {
+		id = only
+	}
+	authorizer := api.authorizers[id]
+	uri := fmt.Sprintf("arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/%s/invocations",
+		aws.StringValue(account.functions["auth"].FunctionArn))
+	if aws.StringValue(authorizer.Name) != "helloAuthorizer" || aws.StringValue(authorizer.Type) != "TOKEN" ||
+		aws.StringValue(authorizer.AuthorizerUri) != uri ||
+		aws.StringValue(authorizer.IdentitySource) != "method.request.header.X-Token" ||
+		aws.Int64Value(authorizer.AuthorizerResultTtlInSeconds) != DefaultAuthorizerTTL {
+		t.Errorf("got authorizer %v", authorizer)
+	}
+	for _, method := range api.resource("/hello").ResourceMethods {
+		if aws.StringValue(method.AuthorizationType) != "CUSTOM" || aws.StringValue(method.AuthorizerId) != id {
+			t.Errorf("%s uses %s authorization with authorizer %s, want CUSTOM with %s", aws.StringValue(method.HttpMethod),
+				aws.StringValue(method.AuthorizationType), aws.StringValue(method.AuthorizerId), id)
+		}
+	}
+	statement := fmt.Sprintf("apigateway-authorizer-%s-%s", aws.StringValue(api.api.Id), id)
+	permission, ok := account.policies["auth"][statement]
+	if !ok {
+		t.Fatalf("API Gateway isn't allowed to invoke the authorizer function, got statements %v", account.statements("auth"))
+	}
+	source := fmt.Sprintf("arn:aws:execute-api:us-east-1:%s:%s/authorizers/%s", fakeAccount, aws.StringValue(api.api.Id), id)
+	if got := permission.Condition["ArnLike"]["AWS:SourceArn"]; got != source {
+		t.Errorf("got source ARN %s for the authorizer permission, want %s", got, source)
+	}
+
+	// Building again updates the existing authorizer
+	build(func(settings *Config) {
+		settings.AuthorizerType = aws.String("REQUEST")
+		settings.AuthorizerTTL = aws.Int64(0)
+	})
+	if len(api.authorizers) != 1 || account.called("CreateAuthorizer") != 1 {
+		t.Fatalf("got %d authorizers from %d creates, want the first one updated", len(api.authorizers), account.called("CreateAuthorizer"))
+	}
+	if aws.StringValue(authorizer.Type) != "REQUEST" || aws.Int64Value(authorizer.AuthorizerResultTtlInSeconds) != 0 {
+		t.Errorf("got a %s authorizer with TTL %d, want a REQUEST authorizer with TTL 0",
+			aws.StringValue(authorizer.Type), aws.Int64Value(authorizer.AuthorizerResultTtlInSeconds))
+	}
+}
+
+const (
+	testUserPool  = "arn:aws:cognito-idp:us-east-1:123456789012:userpool/us-east-1_Abc123"
+	otherUserPool = "arn:aws:cognito-idp:us-east-1:123456789012:userpool/us-east-1_Def456"
+)
+
+func TestValidateCognitoAuthorizer(t *testing.T) {
+	tests := map[string]struct {
+		settings func(*Config)
+		problems []string
+	}{
+		"user pools": {
+			settings: func(settings *Config) {
+				settings.UserPools = &[]string{testUserPool, " " + otherUserPool}
+				settings.OAuthScopes = &[]string{"email"}
+			},
+		},
+		"cognito authentication": {
+			settings: func(settings *Config) {
+				settings.UserPools = &[]string{testUserPool}
+				settings.Authentication = aws.String("COGNITO_USER_POOLS")
+			},
+		},
+		"gov cloud": {
+			settings: func(settings *Config) {
+				settings.UserPools = &[]string{"arn:aws-us-gov:cognito-idp:us-gov-west-1:123456789012:userpool/us-gov-west-1_Abc123"}
+			},
+		},
+		"lambda authorizer too": {
+			settings: func(settings *Config) {
+				settings.UserPools = &[]string{testUserPool}
+				settings.AuthorizerFunction = aws.String("auth")
+			},
+			problems: []string{"Please use either a Lambda authorizer or Cognito user pools, not both"},
+		},
+		"iam authentication": {
+			settings: func(settings *Config) {
+				settings.UserPools = &[]string{testUserPool}
+				settings.Authentication = aws.String("AWS_IAM")
+			},
+			problems: []string{"Cognito user pools can't be combined with AWS_IAM authentication"},
+		},
+		"cognito without user pools": {
+			settings: func(settings *Config) { settings.Authentication = aws.String("COGNITO_USER_POOLS") },
+			problems: []string{"COGNITO_USER_POOLS authentication needs a user pool"},
+		},
+		"scopes without user pools": {
+			settings: func(settings *Config) { settings.OAuthScopes = &[]string{"email"} },
+			problems: []string{"OAuth scopes can only be required with Cognito user pools"},
+		},
+		"invalid user pools": {
+			settings: func(settings *Config) {
+				settings.UserPools = &[]string{"us-east-1_Abc123", "arn:aws:cognito-identity:us-east-1:123456789012:identitypool/us-east-1:abc"}
+				settings.AuthorizerIdentity = aws.String("Authorization,X-Token")
+			},
+			problems: []string{
+				"us-east-1_Abc123 is not the ARN of a Cognito user pool",
+				"identitypool/us-east-1:abc is not the ARN of a Cognito user pool",
+				"Cognito user pools need exactly one identity header",
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			settings := testSettings()
+			settings.AuthorizerIdentity = aws.String(DefaultAuthorizerIdentity)
+			test.settings(settings)
+			checkProblems(t, settings.ValidateAuthorizer(), test.problems)
+		})
+	}
+}
+
+func TestBuildCognitoAuthorizer(t *testing.T) {
+	clients, account := newFakeClients()
+	account.addRole("lambda-basic")
+	build := func(pools ...string) {
+		t.Helper()
+		settings := testSettings()
+		settings.AuthorizerIdentity = aws.String(DefaultAuthorizerIdentity)
+		settings.UserPools = &pools
+		settings.OAuthScopes = &[]string{"email", "openid"}
+		if err := (&GatewayBuilder{Settings: settings, Clients: clients}).Build(); err != nil {
+			t.Fatalf("Build failed: %s", err)
+		}
+	}
+	build(testUserPool)
+
+	api := account.api()
+	if len(api.authorizers) != 1 {
+		t.Fatalf("got %d authorizers, want 1", len(api.authorizers))
+	}
+	var id string
+	for only := range api.authorizers {
+		id = only
+	}
+	authorizer := api.authorizers[id]
+	if aws.StringValue(authorizer.Name) != "helloCognitoAuthorizer" || aws.StringValue(authorizer.Type) != "COGNITO_USER_POOLS" ||
+		aws.StringValue(authorizer.IdentitySource) != "method.request.header.Authorization" {
+		t.Errorf("got authorizer %v", authorizer)
+	}
+	if got := aws.StringValueSlice(authorizer.ProviderARNs); !sameStrings(got, []string{testUserPool}) {
+		t.Errorf("got provider ARNs %v, want [%s]", got, testUserPool)
+	}
+	for _, method := range api.resource("/hello").ResourceMethods {
+		if aws.StringValue(method.AuthorizationType) != "COGNITO_USER_POOLS" || aws.StringValue(method.AuthorizerId) != id {
+			t.Errorf("%s uses %s authorization with authorizer %s, want COGNITO_USER_POOLS with %s", aws.StringValue(method.HttpMethod),
+				aws.StringValue(method.AuthorizationType), aws.StringValue(method.AuthorizerId), id)
+		}
+		if got := aws.StringValueSlice(method.AuthorizationScopes); !sameStrings(got, []string{"email", "openid"}) {
+			t.Errorf("%s requires scopes %v, want [email openid]", aws.StringValue(method.HttpMethod), got)
+		}
+	}
+	// Cognito authorizers don't invoke a function
+	if account.called("AddPermission") != 4 {
+		t.Errorf("got %d permissions, want only the 4 for the methods", account.called("AddPermission"))
+	}
+
+	// The user pools are replaced one at a time
+	build(otherUserPool)
+	if len(api.authorizers) != 1 || account.called("CreateAuthorizer") != 1 {
+		t.Fatalf("got %d authorizers from %d creates, want the first one updated", len(api.authorizers), account.called("CreateAuthorizer"))
+	}
+	if got := aws.StringValueSlice(authorizer.ProviderARNs); !sameStrings(got, []string{otherUserPool}) {
+		t.Errorf("got provider ARNs %v after replacing the user pool, want [%s]", got, otherUserPool)
+	}
+}
@@ -11,11 +11,53 @@ import (
 
 // GatewayBuilder contains the resources needed for creating the Gateway
 type GatewayBuilder struct {
-	Lambda       *lambda.FunctionConfiguration
-	APIGateway   *apigateway.RestApi
-	RootResource *apigateway.Resource
-	Resource     *apigateway.Resource
-	Settings     *Config
+	Lambda         *lambda.FunctionConfiguration
+	APIGateway     *apigateway.RestApi
+	RootResource   *apigateway.Resource
+	Resource       *apigateway.Resource
+	ProxyResource  *apigateway.Resource
+	FunctionUpdate *FunctionUpdate
+	Version        *lambda.FunctionConfiguration
+	Alias          *lambda.AliasConfiguration
+	Authorizer     *apigateway.Authorizer
+	Warnings       []string
+	Package        *Package
+	Settings       *Config
+	Clients        *Clients
+	undo           []undoAction
+}
+
+// Build ensures the Lambda function exists and, unless disabled in the
+// settings, sets up and deploys the Gateway for it
+func (builder *GatewayBuilder) Build() error {
+	warnings, err := builder.Settings.Validate(builder.Clients)
+	builder.Warnings = warnings
+	if err != nil {
+		return err
+	}
+	if err := builder.EnsureLambdaFunction(); err != nil {
+		return err
+	}
+	if err := builder.PublishAndAlias(); err != nil {
+		return err
+	}
+	if aws.BoolValue(builder.Settings.NoGateway) {
+		return nil
+	}
+	steps := []func() error{
+		builder.EnsureAPIGateway,
+		builder.EnsureAuthorizer,
+		builder.AddResources,
+		builder.ConfigureResources,
+		builder.DeployAPI,
+		builder.AddPermissions,
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // APIARN returns the ARN of the API
@@ -26,10 +68,38 @@ func (builder *GatewayBuilder) APIARN() string {
 		aws.StringValue(builder.APIGateway.Id), 1)
 }
 
+// StageName returns the name of the stage the API is deployed to. Unless a
+// stage is provided, each alias gets a stage with its own name, and otherwise
+// the API is deployed to prod.
+func (builder *GatewayBuilder) StageName() string {
+	if stage := aws.StringValue(builder.Settings.Stage); stage != "" {
+		return stage
+	}
+	if alias := aws.StringValue(builder.Settings.Alias); alias != "" {
+		return alias
+	}
+	return DefaultStage
+}
+
 // Endpoint returns the endpoint of the API Gateway
 func (builder *GatewayBuilder) Endpoint() string {
-	return fmt.Sprintf("https://%s.execute-api.%s.amazonaws.com/prod/%s",
-		aws.StringValue(builder.APIGateway.Id),
-		aws.StringValue(builder.Settings.Region),
-		builder.Settings.CleanName())
+	return fmt.Sprintf("%s/%s", builder.stageURL(builder.StageName()), builder.Settings.CleanName())
+}
+
+// Endpoints returns the endpoint of the API Gateway in each of its stages
+func (builder *GatewayBuilder) Endpoints() (map[string]string, error) {
+	stages, err := ListStages(builder.Clients, builder.APIGateway.Id)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make(map[string]string)
+	for _, stage := range stages {
+		endpoints[aws.StringValue(stage.StageName)] = fmt.Sprintf("%s/%s",
+			builder.stageURL(aws.StringValue(stage.StageName)), builder.Settings.CleanName())
+	}
+	return endpoints, nil
+}
+
+func (builder *GatewayBuilder) stageURL(stage string) string {
+	return StageURL(aws.StringValue(builder.APIGateway.Id), aws.StringValue(builder.Settings.Region), stage)
 }
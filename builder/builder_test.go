@@ -0,0 +1,275 @@
+package builder
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// testSettings returns the settings for a function with a GET and POST endpoint
+func testSettings() *Config {
+	return &Config{
+		FunctionName:   aws.String("hello"),
+		RoleName:       aws.String("lambda-basic"),
+		Region:         aws.String("us-east-1"),
+		Authentication: aws.String("NONE"),
+		HTTPMethods:    &[]string{"GET", "POST"},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	tests := map[string]struct {
+		settings    func(*Config)
+		paths       []string
+		methods     map[string][]string
+		qualifier   string
+		permissions int
+	}{
+		"methods": {
+			paths:       []string{"/", "/hello"},
+			methods:     map[string][]string{"/hello": {"GET", "POST"}},
+			permissions: 4,
+		},
+		"proxy": {
+			settings:    func(settings *Config) { settings.Proxy = aws.Bool(true) },
+			paths:       []string{"/", "/hello", "/hello/{proxy+}"},
+			methods:     map[string][]string{"/hello": {"ANY"}, "/hello/{proxy+}": {"ANY"}},
+			permissions: 4,
+		},
+		"alias": {
+			settings: func(settings *Config) {
+				settings.Publish = aws.Bool(true)
+				settings.Alias = aws.String("live")
+			},
+			paths:       []string{"/", "/hello"},
+			methods:     map[string][]string{"/hello": {"GET", "POST"}},
+			qualifier:   "live",
+			permissions: 4,
+		},
+		"cors": {
+			settings:    func(settings *Config) { settings.CORS = aws.Bool(true) },
+			paths:       []string{"/", "/hello"},
+			methods:     map[string][]string{"/hello": {"GET", "OPTIONS", "POST"}},
+			permissions: 4,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.addRole("lambda-basic")
+			settings := testSettings()
+			if test.settings != nil {
+				test.settings(settings)
+			}
+			builder := &GatewayBuilder{Settings: settings, Clients: clients}
+			if err := builder.Build(); err != nil {
+				t.Fatalf("Build failed: %s", err)
+			}
+			if _, ok := account.functions["hello"]; !ok {
+				t.Error("the function wasn't created")
+			}
+			api := account.api()
+			if api == nil {
+				t.Fatal("the API wasn't created")
+			}
+			if paths := api.resourcePaths(); !reflect.DeepEqual(paths, test.paths) {
+				t.Errorf("got resources %v, want %v", paths, test.paths)
+			}
+			for path, methods := range test.methods {
+				if got := api.methods(path); !reflect.DeepEqual(got, methods) {
+					t.Errorf("got methods %v on %s, want %v", got, path, methods)
+				}
+			}
+			if api.deployments != 1 {
+				t.Errorf("got %d deployments, want 1", api.deployments)
+			}
+			key := "hello"
+			if test.qualifier != "" {
+				key += ":" + test.qualifier
+			}
+			if got := len(account.statements(key)); got != test.permissions {
+				t.Errorf("got %d permissions on %s, want %d", got, key, test.permissions)
+			}
+		})
+	}
+}
+
+func TestBuildRollback(t *testing.T) {
+	failure := errors.New("failure")
+	tests := map[string]struct {
+		operation string
+		reuse     bool
+	}{
+		"function":    {operation: "PutFunctionConcurrency"},
+		"alias":       {operation: "CreateAlias"},
+		"api":         {operation: "CreateResource"},
+		"deployment":  {operation: "CreateDeployment"},
+		"permissions": {operation: "AddPermission"},
+		"reused api":  {operation: "CreateDeployment", reuse: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.addRole("lambda-basic")
+			settings := testSettings()
+			settings.Concurrency = aws.Int64(5)
+			settings.Alias = aws.String("live")
+			var existing []string
+			if test.reuse {
+				// Only the methods the build replaces can be changed
+				first := &GatewayBuilder{Settings: testSettings(), Clients: clients}
+				if err := first.Build(); err != nil {
+					t.Fatalf("first Build failed: %s", err)
+				}
+				settings.Alias = nil
+				existing = account.api().resourcePaths()
+			}
+			account.fail[test.operation] = failure
+
+			builder := &GatewayBuilder{Settings: settings, Clients: clients}
+			if err := builder.Build(); err != failure {
+				t.Fatalf("got error %v, want %v", err, failure)
+			}
+			if _, errs := builder.Rollback(); len(errs) > 0 {
+				t.Fatalf("Rollback failed: %v", errs)
+			}
+
+			if !test.reuse {
+				if len(account.functions) != 0 || len(account.apis) != 0 || len(account.aliases) != 0 {
+					t.Errorf("Rollback left the function, API, or alias behind")
+				}
+				return
+			}
+			if _, ok := account.functions["hello"]; !ok {
+				t.Error("Rollback removed the existing function")
+			}
+			api := account.api()
+			if api == nil {
+				t.Fatal("Rollback removed the existing API")
+			}
+			if paths := api.resourcePaths(); !reflect.DeepEqual(paths, existing) {
+				t.Errorf("got resources %v after Rollback, want %v", paths, existing)
+			}
+			if got := api.methods("/hello"); !reflect.DeepEqual(got, []string{"GET", "POST"}) {
+				t.Errorf("got methods %v after Rollback, want [GET POST]", got)
+			}
+			for _, method := range api.resource("/hello").ResourceMethods {
+				if method.MethodIntegration == nil || len(method.MethodIntegration.IntegrationResponses) == 0 {
+					t.Errorf("the integration of %s wasn't restored", aws.StringValue(method.HttpMethod))
+				}
+			}
+		})
+	}
+}
+
+func TestBuildRollbackRestoresStage(t *testing.T) {
+	tests := map[string]struct {
+		stage   string
+		deleted bool
+	}{
+		"existing stage": {stage: "prod"},
+		"new stage":      {stage: "dev", deleted: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.addRole("lambda-basic")
+			if err := (&GatewayBuilder{Settings: testSettings(), Clients: clients}).Build(); err != nil {
+				t.Fatalf("first Build failed: %s", err)
+			}
+			api := account.api()
+			deployment := aws.StringValue(api.stages["prod"].DeploymentId)
+
+			settings := testSettings()
+			settings.Alias = aws.String("live")
+			settings.Stage = aws.String(test.stage)
+			account.fail["AddPermission"] = errors.New("failure")
+			builder := &GatewayBuilder{Settings: settings, Clients: clients}
+			if err := builder.Build(); err == nil {
+				t.Fatal("Build didn't fail")
+			}
+			if _, errs := builder.Rollback(); len(errs) > 0 {
+				t.Fatalf("Rollback failed: %v", errs)
+			}
+
+			stage, ok := api.stages[test.stage]
+			if test.deleted {
+				if ok {
+					t.Errorf("Rollback left the new stage %s behind", test.stage)
+				}
+				return
+			}
+			if got := aws.StringValue(stage.DeploymentId); got != deployment {
+				t.Errorf("got deployment %s after Rollback, want %s", got, deployment)
+			}
+			if len(stage.Variables) != 0 {
+				t.Errorf("got stage variables %v after Rollback, want none", aws.StringValueMap(stage.Variables))
+			}
+		})
+	}
+}
+
+func TestBuildReusesAPI(t *testing.T) {
+	clients, account := newFakeClients()
+	account.addRole("lambda-basic")
+	for i := 0; i < 2; i++ {
+		builder := &GatewayBuilder{Settings: testSettings(), Clients: clients}
+		if err := builder.Build(); err != nil {
+			t.Fatalf("Build %d failed: %s", i+1, err)
+		}
+	}
+	if got := account.called("CreateRestApi"); got != 1 {
+		t.Errorf("got %d APIs created, want 1", got)
+	}
+	if got := account.called("CreateFunction"); got != 1 {
+		t.Errorf("got %d functions created, want 1", got)
+	}
+	if got := account.api().methods("/hello"); !reflect.DeepEqual(got, []string{"GET", "POST"}) {
+		t.Errorf("got methods %v, want [GET POST]", got)
+	}
+}
+
+func TestBuildSwitchesProxyMode(t *testing.T) {
+	tests := map[string]struct {
+		fail  string
+		paths []string
+	}{
+		"switch":   {paths: []string{"/", "/hello"}},
+		"rollback": {fail: "CreateDeployment", paths: []string{"/", "/hello", "/hello/{proxy+}"}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.addRole("lambda-basic")
+			settings := testSettings()
+			settings.Proxy = aws.Bool(true)
+			if err := (&GatewayBuilder{Settings: settings, Clients: clients}).Build(); err != nil {
+				t.Fatalf("Build in proxy mode failed: %s", err)
+			}
+			if test.fail != "" {
+				account.fail[test.fail] = errors.New("failure")
+			}
+
+			builder := &GatewayBuilder{Settings: testSettings(), Clients: clients}
+			if err := builder.Build(); err != nil {
+				if test.fail == "" {
+					t.Fatalf("Build failed: %s", err)
+				}
+				if _, errs := builder.Rollback(); len(errs) > 0 {
+					t.Fatalf("Rollback failed: %v", errs)
+				}
+			}
+			api := account.api()
+			if paths := api.resourcePaths(); !reflect.DeepEqual(paths, test.paths) {
+				t.Errorf("got resources %v, want %v", paths, test.paths)
+			}
+			if test.fail != "" {
+				if got := api.methods("/hello/{proxy+}"); !reflect.DeepEqual(got, []string{"ANY"}) {
+					t.Errorf("got methods %v on the restored proxy resource, want [ANY]", got)
+				}
+			}
+		})
+	}
+}
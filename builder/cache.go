@@ -0,0 +1,230 @@
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CacheDirVariable is the environment variable that overrides where
+// downloaded files are cached
+const CacheDirVariable = "AQUA_CACHE_DIR"
+
+// CacheEntry describes a downloaded file in the cache. The contents are
+// stored by their SHA256 checksum, so a file downloaded from several URLs is
+// only stored once.
+type CacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	SHA256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	Fetched      time.Time `json:"fetched"`
+}
+
+// CacheDir returns the directory downloaded files are cached in
+func CacheDir() (string, error) {
+	if dir := os.Getenv(CacheDirVariable); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "aqua"), nil
+}
+
+// ListCache returns the cached downloads, sorted by URL
+func ListCache() ([]CacheEntry, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "entries", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []CacheEntry
+	for _, file := range files {
+		entry, err := readCacheEntry(file)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].URL < entries[j].URL })
+	return entries, nil
+}
+
+// cacheLayout maps the directories Aqua keeps in the cache to the extension
+// of the files in them
+var cacheLayout = map[string]string{
+	"entries": ".json",
+	"objects": ".zip",
+}
+
+// CleanCache removes all cached downloads and returns how many there were.
+// As the cache directory can be set to any directory, only the directories
+// Aqua created are removed, and only if they contain nothing but Aqua's files.
+func CleanCache() (int, error) {
+	entries, err := ListCache()
+	if err != nil {
+		return 0, err
+	}
+	dir, err := CacheDir()
+	if err != nil {
+		return 0, err
+	}
+	if err = checkCacheLayout(dir); err != nil {
+		return 0, err
+	}
+	for subdir := range cacheLayout {
+		if err = os.RemoveAll(filepath.Join(dir, subdir)); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// checkCacheLayout checks that the cache directories only contain files Aqua
+// stores there, or the temporary files it writes them through
+func checkCacheLayout(dir string) error {
+	for subdir, extension := range cacheLayout {
+		files, err := ioutil.ReadDir(filepath.Join(dir, subdir))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() || (filepath.Ext(name) != extension && !strings.HasPrefix(name, tempPrefix)) {
+				return fmt.Errorf("%s doesn't look like an Aqua cache, as it contains %s. Nothing was removed",
+					dir, filepath.Join(subdir, name))
+			}
+		}
+	}
+	return nil
+}
+
+// cachedDownload returns the cache entry for the URL and its contents, if
+// they are available
+func cachedDownload(rawURL string) (*CacheEntry, []byte) {
+	dir, err := CacheDir()
+	if err != nil {
+		return nil, nil
+	}
+	entry, err := readCacheEntry(cacheEntryPath(dir, rawURL))
+	if err != nil {
+		return nil, nil
+	}
+	contents, err := ioutil.ReadFile(cacheObjectPath(dir, entry.SHA256))
+	if err != nil || VerifySHA256(contents, entry.SHA256) != nil {
+		return nil, nil
+	}
+	return entry, contents
+}
+
+// cacheDownload stores the contents downloaded from the URL. Nothing is
+// cached without an ETag or Last-Modified header, as the file can't be
+// revalidated later.
+func cacheDownload(entry CacheEntry, contents []byte) error {
+	if entry.ETag == "" && entry.LastModified == "" {
+		return nil
+	}
+	dir, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(contents)
+	entry.SHA256 = hex.EncodeToString(hash[:])
+	entry.Size = int64(len(contents))
+	entry.Fetched = time.Now().UTC()
+	if err = writeCacheFile(cacheObjectPath(dir, entry.SHA256), contents); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	previous, _ := readCacheEntry(cacheEntryPath(dir, entry.URL))
+	if err = writeCacheFile(cacheEntryPath(dir, entry.URL), data); err != nil {
+		return err
+	}
+	if previous != nil && previous.SHA256 != entry.SHA256 {
+		return pruneCacheObject(dir, previous.SHA256)
+	}
+	return nil
+}
+
+// pruneCacheObject removes the contents with the checksum from the cache,
+// unless another cached download still has the same contents
+func pruneCacheObject(dir string, checksum string) error {
+	entries, err := ListCache()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.SHA256 == checksum {
+			return nil
+		}
+	}
+	err = os.Remove(cacheObjectPath(dir, checksum))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func cacheEntryPath(dir string, rawURL string) string {
+	hash := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(dir, "entries", hex.EncodeToString(hash[:])+".json")
+}
+
+func cacheObjectPath(dir string, checksum string) string {
+	return filepath.Join(dir, "objects", checksum+".zip")
+}
+
+func readCacheEntry(file string) (*CacheEntry, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	entry := new(CacheEntry)
+	if err = json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// tempPrefix starts the names of files that are still being written
+const tempPrefix = ".download-"
+
+// writeCacheFile writes to a temporary file first, so an interrupted
+// download never leaves a partial file in the cache
+func writeCacheFile(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(file), tempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err = temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), file)
+}
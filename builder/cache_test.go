@@ -0,0 +1,167 @@
+package builder
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanCache(t *testing.T) {
+	tests := map[string]struct {
+		extra   string
+		refused bool
+	}{
+		"cache only":         {},
+		"other directory":    {extra: "notes/todo.txt"},
+		"unexpected entry":   {extra: "entries/todo.txt", refused: true},
+		"unexpected object":  {extra: "objects/backup.tar", refused: true},
+		"interrupted writes": {extra: "objects/.download-123"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv(CacheDirVariable, dir)
+			if err := cacheDownload(CacheEntry{URL: "https://example.com/function.zip", ETag: `"v1"`}, append(zipSignature, 'x')); err != nil {
+				t.Fatal(err)
+			}
+			if test.extra != "" {
+				extra := filepath.Join(dir, test.extra)
+				if err := os.MkdirAll(filepath.Dir(extra), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(extra, []byte("keep"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			removed, err := CleanCache()
+			if test.refused {
+				if err == nil {
+					t.Fatal("expected the cache directory to be refused")
+				}
+				if entries, _ := ListCache(); len(entries) != 1 {
+					t.Errorf("expected the cache to be left alone, found %d entries", len(entries))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removed != 1 {
+				t.Errorf("expected 1 removed download, got %d", removed)
+			}
+			for subdir := range cacheLayout {
+				if _, err := os.Stat(filepath.Join(dir, subdir)); !os.IsNotExist(err) {
+					t.Errorf("expected %s to be removed", subdir)
+				}
+			}
+			if _, err := os.Stat(dir); err != nil {
+				t.Errorf("expected the cache directory itself to be kept: %s", err)
+			}
+			if test.extra != "" && filepath.Dir(test.extra) == "notes" {
+				if _, err := os.Stat(filepath.Join(dir, test.extra)); err != nil {
+					t.Errorf("expected %s to be kept: %s", test.extra, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadFileRevalidation(t *testing.T) {
+	cached := append(append([]byte{}, zipSignature...), "cached"...)
+	fresh := append(append([]byte{}, zipSignature...), "fresh"...)
+	tests := map[string]struct {
+		etag     string
+		expected []byte
+		cached   string
+	}{
+		"unchanged":      {etag: `"v1"`, expected: cached, cached: `"v1"`},
+		"weak unchanged": {etag: `W/"v1"`, expected: cached, cached: `"v1"`},
+		"no etag":        {expected: cached, cached: `"v1"`},
+		"changed":        {etag: `"v2"`, expected: fresh, cached: `"v2"`},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(CacheDirVariable, t.TempDir())
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("If-None-Match") != "" {
+					if test.etag != "" {
+						w.Header().Set("ETag", test.etag)
+					}
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", `"v2"`)
+				w.Write(fresh)
+			}))
+			defer server.Close()
+			url := server.URL + "/function.zip"
+			if err := cacheDownload(CacheEntry{URL: url, ETag: `"v1"`}, cached); err != nil {
+				t.Fatal(err)
+			}
+
+			contents, err := downloadFile(url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(contents) != string(test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, contents)
+			}
+			entry, _ := cachedDownload(url)
+			if entry == nil {
+				t.Fatal("expected the download to stay cached")
+			}
+			if entry.ETag != test.cached {
+				t.Errorf("expected the cached ETag to be %s, got %s", test.cached, entry.ETag)
+			}
+		})
+	}
+}
+
+func TestCacheDownloadPrunesObjects(t *testing.T) {
+	first := append(append([]byte{}, zipSignature...), "first"...)
+	second := append(append([]byte{}, zipSignature...), "second"...)
+	tests := map[string]struct {
+		shared bool
+		kept   int
+	}{
+		"superseded": {kept: 1},
+		"shared":     {shared: true, kept: 2},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv(CacheDirVariable, dir)
+			if err := cacheDownload(CacheEntry{URL: "https://example.com/function.zip", ETag: `"v1"`}, first); err != nil {
+				t.Fatal(err)
+			}
+			if test.shared {
+				if err := cacheDownload(CacheEntry{URL: "https://example.org/function.zip", ETag: `"v1"`}, first); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := cacheDownload(CacheEntry{URL: "https://example.com/function.zip", ETag: `"v2"`}, second); err != nil {
+				t.Fatal(err)
+			}
+
+			objects, err := filepath.Glob(filepath.Join(dir, "objects", "*.zip"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != test.kept {
+				t.Errorf("got %d cached objects, want %d", len(objects), test.kept)
+			}
+			if _, contents := cachedDownload("https://example.com/function.zip"); string(contents) != string(second) {
+				t.Errorf("got %q for the rewritten download, want %q", contents, second)
+			}
+			if test.shared {
+				if _, contents := cachedDownload("https://example.org/function.zip"); string(contents) != string(first) {
+					t.Errorf("got %q for the other download, want %q", contents, first)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package builder
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/apigateway/apigatewayiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents/cloudwatcheventsiface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// Clients contains the AWS service clients used by the builder. Any of them
+// can be replaced by a different implementation, such as an in-memory fake.
+type Clients struct {
+	APIGateway apigatewayiface.APIGatewayAPI
+	Lambda     lambdaiface.LambdaAPI
+	IAM        iamiface.IAMAPI
+	Events     cloudwatcheventsiface.CloudWatchEventsAPI
+	S3         s3iface.S3API
+}
+
+// NewClients creates the AWS service clients for the provided region
+func NewClients(region *string) *Clients {
+	sess := session.New(&aws.Config{Region: region})
+	return &Clients{
+		APIGateway: apigateway.New(sess),
+		Lambda:     lambda.New(sess),
+		IAM:        iam.New(sess),
+		Events:     cloudwatchevents.New(sess),
+		S3:         s3.New(sess),
+	}
+}
@@ -1,6 +1,11 @@
 package builder
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -17,15 +22,72 @@ type Config struct {
 	ApikeyRequired *bool
 	Runtime        *string
 	RoleType       *string
-	HTTPMethod     *string
+	HTTPMethods    *[]string
 	RoleFilename   *string
 	NoGateway      *bool
+	KeepOnFailure  *bool
+	Proxy          *bool
+	APIID          *string
+	DryRun         *bool
+	Update         *bool
+	Handler        *string
+	MemorySize     *int64
+	Timeout        *int64
+	Description    *string
+	Environment    *[]string
+	EnvFile        *string
+	Architecture   *string
+	TracingMode    *string
+	Concurrency    *int64
+	DeadLetterARN  *string
+	Tags           *[]string
+	Source         *string
+	SHA256         *string
+	UploadBucket   *string
+	Publish        *bool
+	Alias          *string
+	Stage          *string
+	CORS           *bool
+	CORSOriginList *[]string
+	CORSHeaderList *[]string
+	CORSMethodList *[]string
+	CORSMaxAge     *int64
+	// AuthorizerFunction is the name or ARN of the Lambda authorizer function
+	AuthorizerFunction *string
+	AuthorizerType     *string
+	// AuthorizerIdentity is a comma separated list of header names
+	AuthorizerIdentity *string
+	AuthorizerTTL      *int64
+	// UserPools are the ARNs of the Cognito user pools that authorize requests
+	UserPools   *[]string
+	OAuthScopes *[]string
+	Schedule    *string
 }
 
+// DefaultRuntime is the runtime used for new Lambda functions if none is provided
+const DefaultRuntime = "nodejs22.x"
+
+// DefaultHandler is the handler used for new Lambda functions if none is provided
+const DefaultHandler = "index.handler"
+
+// DefaultStage is the stage the API is deployed to if no stage or alias is
+// provided
+const DefaultStage = "prod"
+
 // IsWebPath checks if the provided filepath is a web address
 func (config Config) IsWebPath() bool {
 	value := aws.StringValue(config.FilePath)
-	return value[0:5] == "http:" || value[0:6] == "https:"
+	return strings.HasPrefix(value, "http:") || strings.HasPrefix(value, "https:")
+}
+
+// IsS3Path checks if the provided filepath is an S3 location
+func (config Config) IsS3Path() bool {
+	return strings.HasPrefix(aws.StringValue(config.FilePath), "s3:")
+}
+
+// hasCode checks if the settings provide code for the function
+func (config Config) hasCode() bool {
+	return aws.StringValue(config.FilePath) != "" || aws.StringValue(config.Source) != ""
 }
 
 // CleanName returns a cleaned up version of the FunctionName
@@ -33,3 +95,180 @@ func (config Config) CleanName() string {
 	value := aws.StringValue(config.FunctionName)
 	return strings.ToLower(value)
 }
+
+// SupportedHTTPMethods are the HTTP methods a Gateway can be configured for
+var SupportedHTTPMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "ANY"}
+
+// Methods returns the requested HTTP methods in upper case, without
+// duplicates. If no methods were requested it defaults to POST. In proxy mode
+// every request is passed on, so the only method is ANY.
+func (config Config) Methods() ([]string, error) {
+	if aws.BoolValue(config.Proxy) {
+		return []string{"ANY"}, nil
+	}
+	var methods []string
+	seen := make(map[string]bool)
+	if config.HTTPMethods != nil {
+		for _, method := range *config.HTTPMethods {
+			method = strings.ToUpper(strings.TrimSpace(method))
+			if seen[method] {
+				continue
+			}
+			if !contains(SupportedHTTPMethods, method) {
+				return nil, fmt.Errorf("%s is not a supported HTTP method, please use one of %s",
+					method, strings.Join(SupportedHTTPMethods, ", "))
+			}
+			seen[method] = true
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		methods = []string{"POST"}
+	}
+	return methods, nil
+}
+
+// SupportedArchitectures are the instruction set architectures of Lambda functions
+var SupportedArchitectures = []string{"x86_64", "arm64"}
+
+// SupportedTracingModes are the X-Ray tracing modes of Lambda functions
+var SupportedTracingModes = []string{"Active", "PassThrough"}
+
+var environmentKey = regexp.MustCompile("^[a-zA-Z][a-zA-Z0-9_]*$")
+
+var aliasName = regexp.MustCompile("^[a-zA-Z0-9_-]{1,128}$")
+
+var stageName = regexp.MustCompile("^[a-zA-Z0-9_-]{1,128}$")
+
+var numeric = regexp.MustCompile("^[0-9]+$")
+
+var sha256Checksum = regexp.MustCompile("^[0-9a-fA-F]{64}$")
+
+var deadLetterARN = regexp.MustCompile("^arn:aws[a-z-]*:(sqs|sns):")
+
+// EnvironmentVariables returns the environment variables from the environment
+// file followed by those provided directly, so the latter take precedence
+func (config Config) EnvironmentVariables() (map[string]*string, error) {
+	var pairs []string
+	if aws.StringValue(config.EnvFile) != "" {
+		file, err := os.Open(aws.StringValue(config.EnvFile))
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			pairs = append(pairs, line)
+		}
+		if err = scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if config.Environment != nil {
+		pairs = append(pairs, *config.Environment...)
+	}
+	variables, err := keyValues(pairs, "environment variable")
+	if err != nil {
+		return nil, err
+	}
+	for key := range variables {
+		if !environmentKey.MatchString(key) {
+			return nil, fmt.Errorf("%s is not a valid environment variable name", key)
+		}
+	}
+	return variables, nil
+}
+
+// TagMap returns the provided tags
+func (config Config) TagMap() (map[string]*string, error) {
+	if config.Tags == nil {
+		return nil, nil
+	}
+	return keyValues(*config.Tags, "tag")
+}
+
+// keyValues turns a list of KEY=VALUE strings into a map
+func keyValues(pairs []string, description string) (map[string]*string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	values := make(map[string]*string)
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%s is not a valid %s, please use KEY=VALUE", pair, description)
+		}
+		values[parts[0]] = aws.String(parts[1])
+	}
+	return values, nil
+}
+
+// ValidateFunction checks the settings used for creating a Lambda function,
+// and returns all the problems it finds
+func (config Config) ValidateFunction() error {
+	var problems []string
+	if memory := aws.Int64Value(config.MemorySize); memory != 0 && (memory < 128 || memory > 10240) {
+		problems = append(problems, fmt.Sprintf("The memory size has to be between 128 and 10240 MB, not %d", memory))
+	}
+	if timeout := aws.Int64Value(config.Timeout); timeout != 0 && (timeout < 1 || timeout > 900) {
+		problems = append(problems, fmt.Sprintf("The timeout has to be between 1 and 900 seconds, not %d", timeout))
+	}
+	if architecture := aws.StringValue(config.Architecture); architecture != "" && !contains(SupportedArchitectures, architecture) {
+		problems = append(problems, fmt.Sprintf("%s is not a supported architecture, please use one of %s",
+			architecture, strings.Join(SupportedArchitectures, ", ")))
+	}
+	if mode := aws.StringValue(config.TracingMode); mode != "" && !contains(SupportedTracingModes, mode) {
+		problems = append(problems, fmt.Sprintf("%s is not a supported tracing mode, please use one of %s",
+			mode, strings.Join(SupportedTracingModes, ", ")))
+	}
+	// A reserved concurrency of -1 means it isn't set
+	if concurrency := aws.Int64Value(config.Concurrency); concurrency < -1 {
+		problems = append(problems, fmt.Sprintf("The reserved concurrency can't be negative, not %d", concurrency))
+	}
+	if target := aws.StringValue(config.DeadLetterARN); target != "" && !deadLetterARN.MatchString(target) {
+		problems = append(problems, fmt.Sprintf("The dead-letter target has to be the ARN of an SQS queue or SNS topic, not %s", target))
+	}
+	if _, err := config.EnvironmentVariables(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if _, err := config.TagMap(); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if aws.StringValue(config.Source) != "" && aws.StringValue(config.FilePath) != "" {
+		problems = append(problems, "Please provide either a source directory or a file, not both")
+	}
+	if checksum := aws.StringValue(config.SHA256); checksum != "" && !sha256Checksum.MatchString(checksum) {
+		problems = append(problems, fmt.Sprintf("%s is not a hex encoded SHA256 checksum", checksum))
+	}
+	if alias := aws.StringValue(config.Alias); alias != "" && (!aliasName.MatchString(alias) || numeric.MatchString(alias)) {
+		problems = append(problems, fmt.Sprintf("%s is not a valid alias, it can only contain letters, numbers, - and _ and can't be a version number", alias))
+	}
+	if stage := aws.StringValue(config.Stage); stage != "" && !stageName.MatchString(stage) {
+		problems = append(problems, fmt.Sprintf("%s is not a valid stage name, it can only contain letters, numbers, - and _", stage))
+	}
+	if config.IsS3Path() {
+		if _, err := ParseS3Path(aws.StringValue(config.FilePath)); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if aws.StringValue(config.SHA256) != "" {
+			problems = append(problems, "The checksum of a file in S3 can't be verified, please leave out --sha256")
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
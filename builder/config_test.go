@@ -0,0 +1,29 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestValidateFunctionDeadLetter(t *testing.T) {
+	tests := map[string]bool{
+		"arn:aws:sqs:us-east-1:123456789012:failed":            true,
+		"arn:aws:sns:us-east-1:123456789012:failed":            true,
+		"arn:aws-cn:sqs:cn-north-1:123456789012:failed":        true,
+		"arn:aws-us-gov:sns:us-gov-west-1:123456789012:failed": true,
+		"arn:aws:lambda:us-east-1:123456789012:function:other": false,
+		"sqs:failed": false,
+	}
+	for target, valid := range tests {
+		t.Run(target, func(t *testing.T) {
+			err := Config{DeadLetterARN: aws.String(target)}.ValidateFunction()
+			if valid && err != nil {
+				t.Errorf("got error %s", err)
+			}
+			if !valid && err == nil {
+				t.Error("the target was accepted")
+			}
+		})
+	}
+}
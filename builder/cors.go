@@ -0,0 +1,213 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+// DefaultCORSHeaders are the request headers browsers are allowed to send if
+// none are provided
+var DefaultCORSHeaders = []string{"Content-Type", "X-Amz-Date", "Authorization", "X-Api-Key", "X-Amz-Security-Token"}
+
+// CORSEnabled checks if the endpoint should be callable from browsers on
+// other origins
+func (config Config) CORSEnabled() bool {
+	return aws.BoolValue(config.CORS)
+}
+
+// CORSOrigins returns the origins that are allowed to call the endpoint. If
+// none were provided, every origin is allowed.
+func (config Config) CORSOrigins() []string {
+	if config.CORSOriginList == nil || len(*config.CORSOriginList) == 0 {
+		return []string{"*"}
+	}
+	return *config.CORSOriginList
+}
+
+// CORSHeaders returns the request headers browsers are allowed to send
+func (config Config) CORSHeaders() []string {
+	if config.CORSHeaderList == nil || len(*config.CORSHeaderList) == 0 {
+		return DefaultCORSHeaders
+	}
+	return *config.CORSHeaderList
+}
+
+// CORSMethods returns the HTTP methods browsers are allowed to use. If none
+// were provided, these are the methods the endpoint listens to.
+func (config Config) CORSMethods() ([]string, error) {
+	var methods []string
+	if config.CORSMethodList != nil && len(*config.CORSMethodList) > 0 {
+		for _, method := range *config.CORSMethodList {
+			methods = append(methods, strings.ToUpper(strings.TrimSpace(method)))
+		}
+	} else {
+		configured, err := config.Methods()
+		if err != nil {
+			return nil, err
+		}
+		for _, method := range configured {
+			// Browsers don't understand ANY, so it's replaced by what it matches
+			if method == "ANY" {
+				methods = append(methods, "GET", "POST", "PUT", "PATCH", "DELETE")
+				continue
+			}
+			methods = append(methods, method)
+		}
+	}
+	if !contains(methods, "OPTIONS") {
+		methods = append(methods, "OPTIONS")
+	}
+	return methods, nil
+}
+
+// ValidateCORS checks the CORS settings, and returns all the problems it finds
+func (config Config) ValidateCORS() error {
+	if !config.CORSEnabled() {
+		return nil
+	}
+	var problems []string
+	for _, origin := range config.CORSOrigins() {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			problems = append(problems, fmt.Sprintf("%s is not a valid origin, please use * or a URL like https://example.com", origin))
+		}
+	}
+	if len(config.CORSOrigins()) > 1 && contains(config.CORSOrigins(), "*") {
+		problems = append(problems, "The * origin already allows every origin and can't be combined with others")
+	}
+	if config.CORSMethodList != nil {
+		for _, method := range *config.CORSMethodList {
+			method = strings.ToUpper(strings.TrimSpace(method))
+			if method != "OPTIONS" && (method == "ANY" || !contains(SupportedHTTPMethods, method)) {
+				problems = append(problems, fmt.Sprintf("%s is not a method browsers can be allowed to use", method))
+			}
+		}
+	}
+	if aws.Int64Value(config.CORSMaxAge) < 0 {
+		problems = append(problems, fmt.Sprintf("The CORS max age can't be negative, not %d", aws.Int64Value(config.CORSMaxAge)))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// corsResponseHeaders returns the CORS headers of the response to the
+// preflight OPTIONS request, or of a regular response if preflight is false,
+// with their values as API Gateway mapping expressions
+func (builder *GatewayBuilder) corsResponseHeaders(preflight bool) (map[string]string, error) {
+	settings := builder.Settings
+	// Static values are quoted. With several origins the matching one is
+	// set by the template, the first one is only used if none match.
+	headers := map[string]string{
+		"Access-Control-Allow-Origin": fmt.Sprintf("'%s'", settings.CORSOrigins()[0]),
+	}
+	if !preflight {
+		return headers, nil
+	}
+	methods, err := settings.CORSMethods()
+	if err != nil {
+		return nil, err
+	}
+	headers["Access-Control-Allow-Headers"] = fmt.Sprintf("'%s'", strings.Join(settings.CORSHeaders(), ","))
+	headers["Access-Control-Allow-Methods"] = fmt.Sprintf("'%s'", strings.Join(methods, ","))
+	if maxAge := aws.Int64Value(settings.CORSMaxAge); maxAge > 0 {
+		headers["Access-Control-Max-Age"] = fmt.Sprintf("'%s'", strconv.FormatInt(maxAge, 10))
+	}
+	return headers, nil
+}
+
+// corsOriginTemplate returns a mapping template that sets the
+// Access-Control-Allow-Origin header to the origin of the request if it's
+// one of several allowed origins. The body is passed on unchanged.
+func (builder *GatewayBuilder) corsOriginTemplate(body string) map[string]*string {
+	origins := builder.Settings.CORSOrigins()
+	if len(origins) < 2 {
+		return nil
+	}
+	quoted := make([]string, len(origins))
+	for index, origin := range origins {
+		quoted[index] = fmt.Sprintf(`"%s"`, origin)
+	}
+	template := fmt.Sprintf(`#set($origin = $input.params().header.get("Origin"))
+#if($origin == "")#set($origin = $input.params().header.get("origin"))#end
+#if([%s].contains($origin))
+#set($context.responseOverride.header.Access-Control-Allow-Origin = $origin)
+#end
+%s`, strings.Join(quoted, ", "), body)
+	return map[string]*string{"application/json": aws.String(template)}
+}
+
+// corsMappings turns the headers into the parameters of a method response and
+// an integration response
+func corsMappings(headers map[string]string) (map[string]*bool, map[string]*string) {
+	methodParameters := make(map[string]*bool)
+	integrationParameters := make(map[string]*string)
+	for header, value := range headers {
+		parameter := "method.response.header." + header
+		methodParameters[parameter] = aws.Bool(false)
+		integrationParameters[parameter] = aws.String(value)
+	}
+	return methodParameters, integrationParameters
+}
+
+// configureCORS adds an OPTIONS method to the resource that answers the
+// preflight requests of browsers without invoking the Lambda function
+func (builder *GatewayBuilder) configureCORS(resource *apigateway.Resource) error {
+	svc := builder.Clients.APIGateway
+	headers, err := builder.corsResponseHeaders(true)
+	if err != nil {
+		return err
+	}
+	methodParameters, integrationParameters := corsMappings(headers)
+
+	// Browsers don't send credentials or API keys with preflight requests
+	_, err = svc.PutMethod(&apigateway.PutMethodInput{
+		AuthorizationType: aws.String("NONE"),
+		HttpMethod:        aws.String("OPTIONS"),
+		ResourceId:        resource.Id,
+		RestApiId:         builder.APIGateway.Id,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.PutIntegration(&apigateway.PutIntegrationInput{
+		HttpMethod: aws.String("OPTIONS"),
+		ResourceId: resource.Id,
+		RestApiId:  builder.APIGateway.Id,
+		Type:       aws.String("MOCK"),
+		RequestTemplates: map[string]*string{
+			"application/json": aws.String(`{"statusCode": 200}`),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.PutMethodResponse(&apigateway.PutMethodResponseInput{
+		HttpMethod:         aws.String("OPTIONS"),
+		ResourceId:         resource.Id,
+		RestApiId:          builder.APIGateway.Id,
+		StatusCode:         aws.String("200"),
+		ResponseModels:     map[string]*string{},
+		ResponseParameters: methodParameters,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.PutIntegrationResponse(&apigateway.PutIntegrationResponseInput{
+		HttpMethod:         aws.String("OPTIONS"),
+		ResourceId:         resource.Id,
+		RestApiId:          builder.APIGateway.Id,
+		StatusCode:         aws.String("200"),
+		ResponseParameters: integrationParameters,
+		ResponseTemplates:  builder.corsOriginTemplate(""),
+	})
+	return err
+}
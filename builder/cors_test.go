@@ -0,0 +1,201 @@
+package builder
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestBuildCORS(t *testing.T) {
+	tests := map[string]struct {
+		settings  func(*Config)
+		preflight map[string]string
+		origin    string
+		origins   []string
+	}{
+		"every origin": {
+			preflight: map[string]string{
+				"Access-Control-Allow-Origin":  "'*'",
+				"Access-Control-Allow-Headers": "'" + strings.Join(DefaultCORSHeaders, ",") + "'",
+				"Access-Control-Allow-Methods": "'GET,POST,OPTIONS'",
+			},
+			origin: "'*'",
+		},
+		"several origins": {
+			settings: func(settings *Config) {
+				settings.CORSOriginList = &[]string{"https://example.com", "https://example.org"}
+				settings.CORSHeaderList = &[]string{"Content-Type"}
+				settings.CORSMethodList = &[]string{"get"}
+				settings.CORSMaxAge = aws.Int64(600)
+			},
+			preflight: map[string]string{
+				"Access-Control-Allow-Origin":  "'https://example.com'",
+				"Access-Control-Allow-Headers": "'Content-Type'",
+				"Access-Control-Allow-Methods": "'GET,OPTIONS'",
+				"Access-Control-Max-Age":       "'600'",
+			},
+			origin:  "'https://example.com'",
+			origins: []string{"https://example.com", "https://example.org"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.addRole("lambda-basic")
+			settings := testSettings()
+			settings.CORS = aws.Bool(true)
+			if test.settings != nil {
+				test.settings(settings)
+			}
+			if err := (&GatewayBuilder{Settings: settings, Clients: clients}).Build(); err != nil {
+				t.Fatalf("Build failed: %s", err)
+			}
+			methods := account.api().resource("/hello").ResourceMethods
+
+			options := methods["OPTIONS"]
+			if got := aws.StringValue(options.AuthorizationType); got != "NONE" {
+				t.Errorf("got authorization %s for OPTIONS, want NONE", got)
+			}
+			integration := options.MethodIntegration
+			if got := aws.StringValue(integration.Type); got != "MOCK" {
+				t.Errorf("got integration type %s for OPTIONS, want MOCK", got)
+			}
+			if got := aws.StringValue(integration.RequestTemplates["application/json"]); got != `{"statusCode": 200}` {
+				t.Errorf("got request template %q for OPTIONS", got)
+			}
+			response := integration.IntegrationResponses["200"]
+			want := make(map[string]string)
+			for header, value := range test.preflight {
+				want["method.response.header."+header] = value
+				if _, ok := options.MethodResponses["200"].ResponseParameters["method.response.header."+header]; !ok {
+					t.Errorf("the OPTIONS method response doesn't declare %s", header)
+				}
+			}
+			if got := aws.StringValueMap(response.ResponseParameters); !reflect.DeepEqual(got, want) {
+				t.Errorf("got preflight headers %v, want %v", got, want)
+			}
+			checkOriginTemplate(t, "OPTIONS", response.ResponseTemplates, test.origins, "")
+
+			for _, method := range []string{"GET", "POST"} {
+				response := methods[method].MethodIntegration.IntegrationResponses["200"]
+				want := map[string]string{"method.response.header.Access-Control-Allow-Origin": test.origin}
+				if got := aws.StringValueMap(response.ResponseParameters); !reflect.DeepEqual(got, want) {
+					t.Errorf("got %s headers %v, want %v", method, got, want)
+				}
+				checkOriginTemplate(t, method, response.ResponseTemplates, test.origins, `$input.json("$")`)
+			}
+		})
+	}
+}
+
+// checkOriginTemplate checks that the template sets the origin of the
+// request if it's one of the origins, and passes on the body. With a single
+// origin there is no need for a template.
+func checkOriginTemplate(t *testing.T, method string, templates map[string]*string, origins []string, body string) {
+	t.Helper()
+	if len(origins) == 0 {
+		if len(templates) != 0 {
+			t.Errorf("got response templates %v for %s, want none", aws.StringValueMap(templates), method)
+		}
+		return
+	}
+	template := aws.StringValue(templates["application/json"])
+	quoted := make([]string, len(origins))
+	for index, origin := range origins {
+		quoted[index] = `"` + origin + `"`
+	}
+	for _, part := range []string{
+		"[" + strings.Join(quoted, ", ") + "].contains($origin)",
+		"#set($context.responseOverride.header.Access-Control-Allow-Origin = $origin)",
+	} {
+		if !strings.Contains(template, part) {
+			t.Errorf("the %s template doesn't contain %s:\n%s", method, part, template)
+		}
+	}
+	if !strings.HasSuffix(template, "#end\n"+body) {
+		t.Errorf("the %s template doesn't end with the body %q:\n%s", method, body, template)
+	}
+}
+
+func TestBuildCORSProxy(t *testing.T) {
+	clients, account := newFakeClients()
+	account.addRole("lambda-basic")
+	settings := testSettings()
+	settings.Proxy = aws.Bool(true)
+	settings.CORS = aws.Bool(true)
+	if err := (&GatewayBuilder{Settings: settings, Clients: clients}).Build(); err != nil {
+		t.Fatalf("Build failed: %s", err)
+	}
+	for _, path := range []string{"/hello", "/hello/{proxy+}"} {
+		resource := account.api().resource(path)
+		if got := account.api().methods(path); !reflect.DeepEqual(got, []string{"ANY", "OPTIONS"}) {
+			t.Errorf("got methods %v on %s, want [ANY OPTIONS]", got, path)
+		}
+		headers := resource.ResourceMethods["OPTIONS"].MethodIntegration.IntegrationResponses["200"].ResponseParameters
+		if got := aws.StringValue(headers["method.response.header.Access-Control-Allow-Methods"]); got != "'GET,POST,PUT,PATCH,DELETE,OPTIONS'" {
+			t.Errorf("got allowed methods %s on %s, want every method ANY matches", got, path)
+		}
+	}
+}
+
+func TestValidateCORS(t *testing.T) {
+	tests := map[string]struct {
+		settings func(*Config)
+		problems []string
+	}{
+		"disabled": {
+			settings: func(settings *Config) {
+				settings.CORS = aws.Bool(false)
+				settings.CORSOriginList = &[]string{"example.com"}
+			},
+		},
+		"defaults": {},
+		"valid": {
+			settings: func(settings *Config) {
+				settings.CORSOriginList = &[]string{"https://example.com", "http://localhost:3000"}
+				settings.CORSMethodList = &[]string{"get", "OPTIONS"}
+				settings.CORSMaxAge = aws.Int64(300)
+			},
+		},
+		"invalid": {
+			settings: func(settings *Config) {
+				settings.CORSOriginList = &[]string{"example.com", "*"}
+				settings.CORSMethodList = &[]string{"ANY", "TRACE"}
+				settings.CORSMaxAge = aws.Int64(-1)
+			},
+			problems: []string{
+				"example.com is not a valid origin",
+				"The * origin already allows every origin",
+				"ANY is not a method",
+				"TRACE is not a method",
+				"The CORS max age can't be negative",
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			settings := testSettings()
+			settings.CORS = aws.Bool(true)
+			if test.settings != nil {
+				test.settings(settings)
+			}
+			err := settings.ValidateCORS()
+			if len(test.problems) == 0 {
+				if err != nil {
+					t.Errorf("ValidateCORS failed: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("ValidateCORS accepted invalid settings")
+			}
+			for _, problem := range test.problems {
+				if !strings.Contains(err.Error(), problem) {
+					t.Errorf("the error doesn't mention %q:\n%s", problem, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+// SupportedEndpointTypes are the types of custom domain names
+var SupportedEndpointTypes = []string{"REGIONAL", "EDGE"}
+
+// rootBasePath is how API Gateway refers to a mapping without a base path
+const rootBasePath = "(none)"
+
+// CreateDomain creates a custom domain name using an existing ACM
+// certificate. Certificates for EDGE domains have to be in us-east-1,
+// certificates for REGIONAL domains in the region of the domain.
+func CreateDomain(clients *Clients, domain string, certificateARN string, endpointType string) (*apigateway.DomainName, error) {
+	if !contains(SupportedEndpointTypes, endpointType) {
+		return nil, fmt.Errorf("%s is not a supported endpoint type, please use one of %s",
+			endpointType, strings.Join(SupportedEndpointTypes, ", "))
+	}
+	params := &apigateway.CreateDomainNameInput{
+		DomainName: aws.String(domain),
+		EndpointConfiguration: &apigateway.EndpointConfiguration{
+			Types: []*string{aws.String(endpointType)},
+		},
+	}
+	if endpointType == "EDGE" {
+		params.CertificateArn = aws.String(certificateARN)
+	} else {
+		params.RegionalCertificateArn = aws.String(certificateARN)
+	}
+	return clients.APIGateway.CreateDomainName(params)
+}
+
+// GetDomain returns the custom domain name
+func GetDomain(clients *Clients, domain string) (*apigateway.DomainName, error) {
+	return clients.APIGateway.GetDomainName(&apigateway.GetDomainNameInput{
+		DomainName: aws.String(domain),
+	})
+}
+
+// DomainTarget returns the DNS name the custom domain name has to point to,
+// and the ID of the Route 53 hosted zone of that DNS name
+func DomainTarget(domain *apigateway.DomainName) (string, string) {
+	if domain.RegionalDomainName != nil {
+		return aws.StringValue(domain.RegionalDomainName), aws.StringValue(domain.RegionalHostedZoneId)
+	}
+	return aws.StringValue(domain.DistributionDomainName), aws.StringValue(domain.DistributionHostedZoneId)
+}
+
+// MapBasePath makes the stage of the API available under the base path of the
+// custom domain name. An empty base path maps the API to the root of the
+// domain.
+func MapBasePath(clients *Clients, domain string, basePath string, apiID *string, stage string) (*apigateway.BasePathMapping, error) {
+	params := &apigateway.CreateBasePathMappingInput{
+		DomainName: aws.String(domain),
+		RestApiId:  apiID,
+		Stage:      aws.String(stage),
+	}
+	if basePath != "" {
+		params.BasePath = aws.String(basePath)
+	}
+	return clients.APIGateway.CreateBasePathMapping(params)
+}
+
+// ListBasePathMappings returns the base path mappings of the custom domain
+// name
+func ListBasePathMappings(clients *Clients, domain string) ([]*apigateway.BasePathMapping, error) {
+	var mappings []*apigateway.BasePathMapping
+	err := EachBasePathMapping(clients, domain, ListOptions{}, func(mapping *apigateway.BasePathMapping) bool {
+		mappings = append(mappings, mapping)
+		return true
+	})
+	return mappings, err
+}
+
+// DeleteBasePathMapping removes the base path mapping from the custom domain
+// name. An empty base path removes the mapping of the root of the domain.
+func DeleteBasePathMapping(clients *Clients, domain string, basePath string) error {
+	if basePath == "" {
+		basePath = rootBasePath
+	}
+	_, err := clients.APIGateway.DeleteBasePathMapping(&apigateway.DeleteBasePathMappingInput{
+		DomainName: aws.String(domain),
+		BasePath:   aws.String(basePath),
+	})
+	return err
+}
+
+// DomainURL returns the URL of the base path of the custom domain name
+func DomainURL(domain string, basePath string) string {
+	if basePath == "" || basePath == rootBasePath {
+		return fmt.Sprintf("https://%s", domain)
+	}
+	return fmt.Sprintf("https://%s/%s", domain, basePath)
+}
+
+// changeBatch is the input of the Route 53 ChangeResourceRecordSets call
+type changeBatch struct {
+	Comment string   `json:"Comment"`
+	Changes []change `json:"Changes"`
+}
+
+type change struct {
+	Action            string            `json:"Action"`
+	ResourceRecordSet resourceRecordSet `json:"ResourceRecordSet"`
+}
+
+type resourceRecordSet struct {
+	Name        string      `json:"Name"`
+	Type        string      `json:"Type"`
+	AliasTarget aliasTarget `json:"AliasTarget"`
+}
+
+type aliasTarget struct {
+	HostedZoneID         string `json:"HostedZoneId"`
+	DNSName              string `json:"DNSName"`
+	EvaluateTargetHealth bool   `json:"EvaluateTargetHealth"`
+}
+
+// WriteRoute53ChangeBatch writes a Route 53 change batch to the file, which
+// creates or updates an alias record that points the custom domain name to
+// its target. It can be applied with
+// aws route53 change-resource-record-sets --change-batch file://<filename>
+func WriteRoute53ChangeBatch(domain *apigateway.DomainName, filename string) error {
+	dnsName, hostedZoneID := DomainTarget(domain)
+	batch := changeBatch{
+		Comment: fmt.Sprintf("Alias for API Gateway domain %s", aws.StringValue(domain.DomainName)),
+		Changes: []change{{
+			Action: "UPSERT",
+			ResourceRecordSet: resourceRecordSet{
+				Name: aws.StringValue(domain.DomainName),
+				Type: "A",
+				AliasTarget: aliasTarget{
+					HostedZoneID: hostedZoneID,
+					DNSName:      dnsName,
+				},
+			},
+		}},
+	}
+	contents, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(contents, '\n'), 0644)
+}
@@ -0,0 +1,157 @@
+package builder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestCreateDomain(t *testing.T) {
+	tests := map[string]struct {
+		endpointType string
+		edge         bool
+		invalid      bool
+	}{
+		"regional": {endpointType: "REGIONAL"},
+		"edge":     {endpointType: "EDGE", edge: true},
+		"private":  {endpointType: "PRIVATE", invalid: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			certificate := "arn:aws:acm:us-east-1:123456789012:certificate/abc"
+			domain, err := CreateDomain(clients, "api.example.com", certificate, test.endpointType)
+			if test.invalid {
+				if err == nil {
+					t.Error("CreateDomain accepted an unsupported endpoint type")
+				}
+				if account.called("CreateDomainName") != 0 {
+					t.Error("CreateDomain called AWS with an unsupported endpoint type")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateDomain failed: %s", err)
+			}
+			if got := aws.StringValue(domain.CertificateArn) == certificate; got != test.edge {
+				t.Errorf("got the edge certificate set %t, want %t", got, test.edge)
+			}
+			if got := aws.StringValue(domain.RegionalCertificateArn) == certificate; got == test.edge {
+				t.Errorf("got the regional certificate set %t, want %t", got, !test.edge)
+			}
+			if got := aws.StringValueSlice(domain.EndpointConfiguration.Types); !reflect.DeepEqual(got, []string{test.endpointType}) {
+				t.Errorf("got endpoint types %v, want [%s]", got, test.endpointType)
+			}
+		})
+	}
+}
+
+func TestMapBasePath(t *testing.T) {
+	tests := map[string]struct {
+		basePath string
+		stored   string
+		url      string
+	}{
+		"root":      {stored: rootBasePath, url: "https://api.example.com"},
+		"base path": {basePath: "v1", stored: "v1", url: "https://api.example.com/v1"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account, apiID := testAPI(t)
+			if _, err := CreateDomain(clients, "api.example.com", "arn:aws:acm:us-east-1:123456789012:certificate/abc", "REGIONAL"); err != nil {
+				t.Fatal(err)
+			}
+			mapping, err := MapBasePath(clients, "api.example.com", test.basePath, apiID, DefaultStage)
+			if err != nil {
+				t.Fatalf("MapBasePath failed: %s", err)
+			}
+			if got := aws.StringValue(mapping.BasePath); got != test.stored {
+				t.Errorf("got base path %s, want %s", got, test.stored)
+			}
+			if got := DomainURL("api.example.com", aws.StringValue(mapping.BasePath)); got != test.url {
+				t.Errorf("got URL %s, want %s", got, test.url)
+			}
+			if _, err = MapBasePath(clients, "api.example.com", test.basePath, apiID, DefaultStage); err == nil {
+				t.Error("MapBasePath mapped the same base path twice")
+			}
+
+			mappings, err := ListBasePathMappings(clients, "api.example.com")
+			if err != nil {
+				t.Fatalf("ListBasePathMappings failed: %s", err)
+			}
+			if len(mappings) != 1 || aws.StringValue(mappings[0].RestApiId) != aws.StringValue(apiID) ||
+				aws.StringValue(mappings[0].Stage) != DefaultStage {
+				t.Errorf("got mappings %v, want the %s stage of %s", mappings, DefaultStage, aws.StringValue(apiID))
+			}
+
+			if err = DeleteBasePathMapping(clients, "api.example.com", test.basePath); err != nil {
+				t.Fatalf("DeleteBasePathMapping failed: %s", err)
+			}
+			if len(account.mappings["api.example.com"]) != 0 {
+				t.Error("the base path mapping wasn't deleted")
+			}
+		})
+	}
+}
+
+func TestWriteRoute53ChangeBatch(t *testing.T) {
+	tests := map[string]struct {
+		endpointType string
+		dnsName      string
+		hostedZone   string
+	}{
+		"regional": {endpointType: "REGIONAL", dnsName: "d-id1.execute-api.us-east-1.amazonaws.com", hostedZone: "Z1UJRXOUMOOFQ8"},
+		"edge":     {endpointType: "EDGE", dnsName: "id1.cloudfront.net", hostedZone: "Z2FDTNDATAQYW2"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, _ := newFakeClients()
+			domain, err := CreateDomain(clients, "api.example.com", "arn:aws:acm:us-east-1:123456789012:certificate/abc", test.endpointType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filename := filepath.Join(t.TempDir(), "change-batch.json")
+			if err = WriteRoute53ChangeBatch(domain, filename); err != nil {
+				t.Fatalf("WriteRoute53ChangeBatch failed: %s", err)
+			}
+			contents, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Read it the way the AWS CLI does, without Aqua's types
+			var batch struct {
+				Changes []struct {
+					Action            string
+					ResourceRecordSet struct {
+						Name        string
+						Type        string
+						AliasTarget struct {
+							HostedZoneId         string
+							DNSName              string
+							EvaluateTargetHealth bool
+						}
+					}
+				}
+			}
+			if err = json.Unmarshal(contents, &batch); err != nil {
+				t.Fatalf("the change batch isn't valid JSON: %s", err)
+			}
+			if len(batch.Changes) != 1 {
+				t.Fatalf("got %d changes, want 1", len(batch.Changes))
+			}
+			change := batch.Changes[0]
+			record := change.ResourceRecordSet
+			if change.Action != "UPSERT" || record.Name != "api.example.com" || record.Type != "A" {
+				t.Errorf("got %s of %s record %s, want UPSERT of A record api.example.com", change.Action, record.Type, record.Name)
+			}
+			if record.AliasTarget.DNSName != test.dnsName || record.AliasTarget.HostedZoneId != test.hostedZone {
+				t.Errorf("got alias target %s in zone %s, want %s in zone %s",
+					record.AliasTarget.DNSName, record.AliasTarget.HostedZoneId, test.dnsName, test.hostedZone)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package builder
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// MaxDownloadSize is the largest file Aqua will download
+const MaxDownloadSize = MaxUnzippedSize
+
+// maxManifestSize is the largest checksum manifest or signature Aqua will download
+const maxManifestSize = 1024 * 1024
+
+// zipSignature is what every zip file starts with
+var zipSignature = []byte("PK\x03\x04")
+
+// AquaChecksumsURL is the URL to the checksum manifest for the Lambda
+// installation file, in the format of sha256sum
+var AquaChecksumsURL = "https://github.com/ArjenSchwarz/aqua/releases/download/latest/checksums.txt"
+
+// AquaSigningKey is the base64 encoded ed25519 public key that the checksum
+// manifest is signed with. The signature is published next to the manifest,
+// with a .sig extension. It is set at build time by bin/build.sh using
+// -ldflags "-X github.com/ArjenSchwarz/aqua/builder.AquaSigningKey=...", and
+// aqua install refuses to run without it unless told otherwise.
+var AquaSigningKey = ""
+
+// downloadFile returns the zip file at the URL. Files are cached, and only
+// downloaded again when the server reports they have changed.
+func downloadFile(rawURL string) ([]byte, error) {
+	header := http.Header{}
+	entry, cached := cachedDownload(rawURL)
+	if entry != nil {
+		if entry.ETag != "" {
+			header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := request(rawURL, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		// The cached file is only used if the server confirms it has the same
+		// version, otherwise it is downloaded again without conditions
+		if etag := resp.Header.Get("ETag"); etag == "" || sameETag(etag, entry.ETag) {
+			return cached, nil
+		}
+		resp.Body.Close()
+		if resp, err = request(rawURL, http.Header{}); err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+	}
+	contents, err := readResponse(rawURL, resp, MaxDownloadSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Error pages are often served with a success status
+	if !bytes.HasPrefix(contents, zipSignature) {
+		return nil, fmt.Errorf("%s didn't return a zip file", rawURL)
+	}
+
+	// A file that can't be cached can still be used
+	cacheDownload(CacheEntry{
+		URL:          rawURL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, contents)
+	return contents, nil
+}
+
+// sameETag checks if two ETags refer to the same version of a file. Weak
+// ETags are good enough for that.
+func sameETag(first string, second string) bool {
+	return strings.TrimPrefix(first, "W/") == strings.TrimPrefix(second, "W/")
+}
+
+// fetch downloads the contents of the URL, as long as the request is
+// successful and the contents aren't larger than the limit
+func fetch(rawURL string, limit int64) ([]byte, error) {
+	resp, err := request(rawURL, http.Header{})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readResponse(rawURL, resp, limit)
+}
+
+func request(rawURL string, header http.Header) (*http.Response, error) {
+	check := http.Client{
+		CheckRedirect: func(r *http.Request, via []*http.Request) error {
+			r.URL.Opaque = r.URL.Path
+			return nil
+		},
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+	return check.Do(req)
+}
+
+// readResponse returns the body of a successful response, as long as it
+// isn't an HTML page or larger than the limit
+func readResponse(rawURL string, resp *http.Response, limit int64) ([]byte, error) {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("Downloading %s failed: %s", rawURL, resp.Status)
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return nil, fmt.Errorf("%s returned an HTML page instead of a file", rawURL)
+	}
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("%s is %d bytes, which is more than the maximum of %d", rawURL, resp.ContentLength, limit)
+	}
+
+	contents, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(contents)) > limit {
+		return nil, fmt.Errorf("%s is more than the maximum of %d bytes", rawURL, limit)
+	}
+	return contents, nil
+}
+
+// VerifySHA256 checks that the hex encoded SHA256 checksum of the data is
+// the expected one
+func VerifySHA256(data []byte, expected string) error {
+	hash := sha256.Sum256(data)
+	actual := hex.EncodeToString(hash[:])
+	if !strings.EqualFold(actual, strings.TrimSpace(expected)) {
+		return fmt.Errorf("The SHA256 checksum of the file is %s instead of %s", actual, expected)
+	}
+	return nil
+}
+
+// ReleaseChecksum returns the SHA256 checksum for the file at fileURL from
+// the checksum manifest at manifestURL. If a signing key is provided, the
+// manifest's signature is verified first. It returns whether the signature
+// was verified.
+func ReleaseChecksum(fileURL string, manifestURL string, signingKey string) (string, bool, error) {
+	manifest, err := fetch(manifestURL, maxManifestSize)
+	if err != nil {
+		return "", false, err
+	}
+
+	verified := false
+	if signingKey != "" {
+		if err = verifySignature(manifest, manifestURL+".sig", signingKey); err != nil {
+			return "", false, err
+		}
+		verified = true
+	}
+
+	parsedURL, err := url.Parse(fileURL)
+	if err != nil {
+		return "", false, err
+	}
+	fileName := path.Base(parsedURL.Path)
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// sha256sum marks files read in binary mode with an asterisk
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == fileName {
+			return fields[0], verified, nil
+		}
+	}
+	return "", false, fmt.Errorf("The checksum manifest doesn't contain %s", fileName)
+}
+
+// verifySignature checks the base64 encoded ed25519 signature found at
+// signatureURL for the manifest
+func verifySignature(manifest []byte, signatureURL string, signingKey string) error {
+	key, err := base64.StdEncoding.DecodeString(signingKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("The signing key isn't a valid base64 encoded ed25519 public key")
+	}
+	encoded, err := fetch(signatureURL, maxManifestSize)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), manifest, signature) {
+		return errors.New("The signature of the checksum manifest is invalid")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package builder
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReleaseChecksum(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := []byte("0123abcd  aqua_lambda.zip\n")
+	signingKey := base64.StdEncoding.EncodeToString(public)
+
+	tests := map[string]struct {
+		signature string
+		key       string
+		verified  bool
+		invalid   bool
+	}{
+		"signed":            {signature: base64.StdEncoding.EncodeToString(ed25519.Sign(private, manifest)), key: signingKey, verified: true},
+		"other key":         {signature: base64.StdEncoding.EncodeToString(ed25519.Sign(other, manifest)), key: signingKey, invalid: true},
+		"missing signature": {key: signingKey, invalid: true},
+		"bad key":           {signature: base64.StdEncoding.EncodeToString(ed25519.Sign(private, manifest)), key: "not a key", invalid: true},
+		"without key":       {},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/checksums.txt":
+					w.Write(manifest)
+				case "/checksums.txt.sig":
+					if test.signature == "" {
+						http.NotFound(w, r)
+						return
+					}
+					w.Write([]byte(test.signature))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			checksum, verified, err := ReleaseChecksum(server.URL+"/aqua_lambda.zip", server.URL+"/checksums.txt", test.key)
+			if test.invalid {
+				if err == nil {
+					t.Error("the manifest was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReleaseChecksum failed: %s", err)
+			}
+			if checksum != "0123abcd" {
+				t.Errorf("got checksum %s, want 0123abcd", checksum)
+			}
+			if verified != test.verified {
+				t.Errorf("got verified %t, want %t", verified, test.verified)
+			}
+		})
+	}
+}
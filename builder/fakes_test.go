@@ -0,0 +1,1561 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/apigateway/apigatewayiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents/cloudwatcheventsiface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+const fakeAccount = "123456789012"
+
+// fakeAWS is an in-memory AWS account shared by the fake clients. Calls the
+// fakes don't implement panic, so a test notices when the builder starts
+// using something new.
+type fakeAWS struct {
+	region       string
+	calls        []string
+	fail         map[string]error
+	failures     map[string]int
+	nextID       int
+	functions    map[string]*lambda.FunctionConfiguration
+	policies     map[string]map[string]fakeStatement
+	aliases      map[string]*lambda.AliasConfiguration
+	versions     map[string]int
+	tags         map[string]map[string]*string
+	concurrency  map[string]*int64
+	roles        map[string]*iam.Role
+	rolePolicies map[string]string
+	apis         map[string]*fakeAPI
+	rules        map[string]*cloudwatchevents.DescribeRuleOutput
+	targets      map[string][]*cloudwatchevents.Target
+	apiKeys      map[string]*apigateway.ApiKey
+	usagePlans   map[string]*apigateway.UsagePlan
+	planKeys     map[string][]string
+	usage        map[string][][]*int64
+	domains      map[string]*apigateway.DomainName
+	mappings     map[string]map[string]*apigateway.BasePathMapping
+	objects      map[string][]byte
+}
+
+type fakeAPI struct {
+	api         *apigateway.RestApi
+	resources   map[string]*apigateway.Resource
+	stages      map[string]*apigateway.Stage
+	authorizers map[string]*apigateway.Authorizer
+	deployments int
+}
+
+type fakeStatement struct {
+	Sid       string
+	Condition map[string]map[string]string
+}
+
+// newFakeClients returns clients backed by an empty fake account
+func newFakeClients() (*Clients, *fakeAWS) {
+	account := &fakeAWS{
+		region:       "us-east-1",
+		fail:         make(map[string]error),
+		failures:     make(map[string]int),
+		functions:    make(map[string]*lambda.FunctionConfiguration),
+		policies:     make(map[string]map[string]fakeStatement),
+		aliases:      make(map[string]*lambda.AliasConfiguration),
+		versions:     make(map[string]int),
+		tags:         make(map[string]map[string]*string),
+		concurrency:  make(map[string]*int64),
+		roles:        make(map[string]*iam.Role),
+		rolePolicies: make(map[string]string),
+		apis:         make(map[string]*fakeAPI),
+		rules:        make(map[string]*cloudwatchevents.DescribeRuleOutput),
+		targets:      make(map[string][]*cloudwatchevents.Target),
+		apiKeys:      make(map[string]*apigateway.ApiKey),
+		usagePlans:   make(map[string]*apigateway.UsagePlan),
+		planKeys:     make(map[string][]string),
+		usage:        make(map[string][][]*int64),
+		domains:      make(map[string]*apigateway.DomainName),
+		mappings:     make(map[string]map[string]*apigateway.BasePathMapping),
+		objects:      make(map[string][]byte),
+	}
+	return &Clients{
+		APIGateway: &fakeAPIGateway{account: account},
+		Lambda:     &fakeLambda{account: account},
+		IAM:        &fakeIAM{account: account},
+		Events:     &fakeEvents{account: account},
+		S3:         &fakeS3{account: account},
+	}, account
+}
+
+// call records the operation and returns the error the test wants it to fail
+// with. If failures has a count for the operation, only that many calls fail.
+func (account *fakeAWS) call(operation string) error {
+	account.calls = append(account.calls, operation)
+	if remaining, limited := account.failures[operation]; limited {
+		if remaining == 0 {
+			return nil
+		}
+		account.failures[operation] = remaining - 1
+	}
+	return account.fail[operation]
+}
+
+// called counts how often the operation was called
+func (account *fakeAWS) called(operation string) int {
+	count := 0
+	for _, call := range account.calls {
+		if call == operation {
+			count++
+		}
+	}
+	return count
+}
+
+func (account *fakeAWS) newID() string {
+	account.nextID++
+	return fmt.Sprintf("id%d", account.nextID)
+}
+
+func (account *fakeAWS) arn(service string, resource string) string {
+	return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, account.region, fakeAccount, resource)
+}
+
+// addRole adds a role to the account, as aqua role create would
+func (account *fakeAWS) addRole(name string) {
+	account.roles[name] = &iam.Role{RoleName: aws.String(name), Arn: aws.String(fmt.Sprintf("arn:aws:iam::%s:role/%s", fakeAccount, name))}
+}
+
+// addUsagePlan adds a usage plan with the API keys to the account
+func (account *fakeAWS) addUsagePlan(name string, keys ...string) *apigateway.UsagePlan {
+	plan := &apigateway.UsagePlan{Id: aws.String(account.newID()), Name: aws.String(name)}
+	account.usagePlans[aws.StringValue(plan.Id)] = plan
+	account.planKeys[aws.StringValue(plan.Id)] = keys
+	return plan
+}
+
+// api returns the only API in the account
+func (account *fakeAWS) api() *fakeAPI {
+	for _, api := range account.apis {
+		return api
+	}
+	return nil
+}
+
+// resourcePaths returns the paths of the resources of the API, sorted
+func (api *fakeAPI) resourcePaths() []string {
+	var paths []string
+	for _, resource := range api.resources {
+		paths = append(paths, aws.StringValue(resource.Path))
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// resource returns the resource of the API with the path
+func (api *fakeAPI) resource(path string) *apigateway.Resource {
+	for _, resource := range api.resources {
+		if aws.StringValue(resource.Path) == path {
+			return resource
+		}
+	}
+	return nil
+}
+
+// methods returns the methods of the resource with the path, sorted
+func (api *fakeAPI) methods(path string) []string {
+	var methods []string
+	if resource := api.resource(path); resource != nil {
+		for method := range resource.ResourceMethods {
+			methods = append(methods, method)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// statements returns the IDs of the statements in the policy of the function
+// or its qualifier, sorted
+func (account *fakeAWS) statements(function string) []string {
+	var sids []string
+	for sid := range account.policies[function] {
+		sids = append(sids, sid)
+	}
+	sort.Strings(sids)
+	return sids
+}
+
+func notFound(code string, format string, args ...interface{}) error {
+	return awserr.New(code, fmt.Sprintf(format, args...), nil)
+}
+
+type fakeAPIGateway struct {
+	apigatewayiface.APIGatewayAPI
+	account *fakeAWS
+}
+
+func (svc *fakeAPIGateway) findAPI(id *string) (*fakeAPI, error) {
+	api, ok := svc.account.apis[aws.StringValue(id)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid API identifier specified %s", aws.StringValue(id))
+	}
+	return api, nil
+}
+
+func (svc *fakeAPIGateway) GetRestApisPages(input *apigateway.GetRestApisInput, fn func(*apigateway.GetRestApisOutput, bool) bool) error {
+	if err := svc.account.call("GetRestApis"); err != nil {
+		return err
+	}
+	// Every API is its own page, to exercise the paging
+	var ids []string
+	for id := range svc.account.apis {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for index, id := range ids {
+		page := &apigateway.GetRestApisOutput{Items: []*apigateway.RestApi{svc.account.apis[id].api}}
+		if !fn(page, index == len(ids)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func (svc *fakeAPIGateway) GetRestApi(input *apigateway.GetRestApiInput) (*apigateway.RestApi, error) {
+	if err := svc.account.call("GetRestApi"); err != nil {
+		return nil, err
+	}
+	api, err := svc.findAPI(input.RestApiId)
+	if err != nil {
+		return nil, err
+	}
+	return api.api, nil
+}
+
+func (svc *fakeAPIGateway) CreateRestApi(input *apigateway.CreateRestApiInput) (*apigateway.RestApi, error) {
+	if err := svc.account.call("CreateRestApi"); err != nil {
+		return nil, err
+	}
+	api := &apigateway.RestApi{Id: aws.String(svc.account.newID()), Name: input.Name, Description: input.Description}
+	root := &apigateway.Resource{Id: aws.String(svc.account.newID()), Path: aws.String("/")}
+	svc.account.apis[aws.StringValue(api.Id)] = &fakeAPI{
+		api:         api,
+		resources:   map[string]*apigateway.Resource{aws.StringValue(root.Id): root},
+		stages:      make(map[string]*apigateway.Stage),
+		authorizers: make(map[string]*apigateway.Authorizer),
+	}
+	return api, nil
+}
+
+func (svc *fakeAPIGateway) DeleteRestApi(input *apigateway.DeleteRestApiInput) (*apigateway.DeleteRestApiOutput, error) {
+	if err := svc.account.call("DeleteRestApi"); err != nil {
+		return nil, err
+	}
+	if _, err := svc.findAPI(input.RestApiId); err != nil {
+		return nil, err
+	}
+	// Like the real service, an API that something still refers to can't be
+	// deleted
+	id := aws.StringValue(input.RestApiId)
+	for domain, mappings := range svc.account.mappings {
+		for _, mapping := range mappings {
+			if aws.StringValue(mapping.RestApiId) == id {
+				return nil, awserr.New("BadRequestException", fmt.Sprintf("Cannot delete API %s, it is mapped to %s", id, domain), nil)
+			}
+		}
+	}
+	for _, plan := range svc.account.usagePlans {
+		for _, stage := range plan.ApiStages {
+			if aws.StringValue(stage.ApiId) == id {
+				return nil, awserr.New("BadRequestException", fmt.Sprintf("Cannot delete API %s, it is in usage plan %s", id, aws.StringValue(plan.Name)), nil)
+			}
+		}
+	}
+	delete(svc.account.apis, id)
+	return &apigateway.DeleteRestApiOutput{}, nil
+}
+
+// GetResourcesPages returns copies of the resources, like the real service
+// does, so changes by the builder don't leak into the account
+func (svc *fakeAPIGateway) GetResourcesPages(input *apigateway.GetResourcesInput, fn func(*apigateway.GetResourcesOutput, bool) bool) error {
+	if err := svc.account.call("GetResources"); err != nil {
+		return err
+	}
+	api, err := svc.findAPI(input.RestApiId)
+	if err != nil {
+		return err
+	}
+	page := &apigateway.GetResourcesOutput{}
+	for _, resource := range api.resources {
+		copied := *resource
+		copied.ResourceMethods = make(map[string]*apigateway.Method)
+		for method, value := range resource.ResourceMethods {
+			copied.ResourceMethods[method] = value
+		}
+		page.Items = append(page.Items, &copied)
+	}
+	fn(page, true)
+	return nil
+}
+
+func (svc *fakeAPIGateway) CreateResource(input *apigateway.CreateResourceInput) (*apigateway.Resource, error) {
+	if err := svc.account.call("CreateResource"); err != nil {
+		return nil, err
+	}
+	api, err := svc.findAPI(input.RestApiId)
+	if err != nil {
+		return nil, err
+	}
+	parent, ok := api.resources[aws.StringValue(input.ParentId)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid resource identifier specified")
+	}
+	path := strings.TrimSuffix(aws.StringValue(parent.Path), "/") + "/" + aws.StringValue(input.PathPart)
+	if api.resource(path) != nil {
+		return nil, awserr.New("ConflictException", "Another resource with the same parent already has this name", nil)
+	}
+	resource := &apigateway.Resource{
+		Id:       aws.String(svc.account.newID()),
+		ParentId: parent.Id,
+		PathPart: input.PathPart,
+		Path:     aws.String(path),
+	}
+	api.resources[aws.StringValue(resource.Id)] = resource
+	copied := *resource
+	return &copied, nil
+}
+
+// DeleteResource removes the resource and everything below it
+func (svc *fakeAPIGateway) DeleteResource(input *apigateway.DeleteResourceInput) (*apigateway.DeleteResourceOutput, error) {
+	if err := svc.account.call("DeleteResource"); err != nil {
+		return nil, err
+	}
+	api, err := svc.findAPI(input.RestApiId)
+	if err != nil {
+		return nil, err
+	}
+	resource, ok := api.resources[aws.StringValue(input.ResourceId)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid resource identifier specified")
+	}
+	path := aws.StringValue(resource.Path)
+	for id, other := range api.resources {
+		if aws.StringValue(other.Path) == path || strings.HasPrefix(aws.StringValue(other.Path), path+"/") {
+			delete(api.resources, id)
+		}
+	}
+	return &apigateway.DeleteResourceOutput{}, nil
+}
+
+func (svc *fakeAPIGateway) findResource(apiID *string, resourceID *string) (*apigateway.Resource, error) {
+	api, err := svc.findAPI(apiID)
+	if err != nil {
+		return nil, err
+	}
+	resource, ok := api.resources[aws.StringValue(resourceID)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid resource identifier specified")
+	}
+	return resource, nil
+}
+
+func (svc *fakeAPIGateway) PutMethod(input *apigateway.PutMethodInput) (*apigateway.Method, error) {
+	if err := svc.account.call("PutMethod"); err != nil {
+		return nil, err
+	}
+	resource, err := svc.findResource(input.RestApiId, input.ResourceId)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := resource.ResourceMethods[aws.StringValue(input.HttpMethod)]; ok {
+		return nil, awserr.New("ConflictException", "Method already exists for this resource", nil)
+	}
+	if resource.ResourceMethods == nil {
+		resource.ResourceMethods = make(map[string]*apigateway.Method)
+	}
+	method := &apigateway.Method{
+		HttpMethod:          input.HttpMethod,
+		AuthorizationType:   input.AuthorizationType,
+		AuthorizerId:        input.AuthorizerId,
+		AuthorizationScopes: input.AuthorizationScopes,
+		ApiKeyRequired:      input.ApiKeyRequired,
+	}
+	resource.ResourceMethods[aws.StringValue(input.HttpMethod)] = method
+	return method, nil
+}
+
+func (svc *fakeAPIGateway) GetMethod(input *apigateway.GetMethodInput) (*apigateway.Method, error) {
+	if err := svc.account.call("GetMethod"); err != nil {
+		return nil, err
+	}
+	resource, err := svc.findResource(input.RestApiId, input.ResourceId)
+	if err != nil {
+		return nil, err
+	}
+	method, ok := resource.ResourceMethods[aws.StringValue(input.HttpMethod)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid Method identifier specified")
+	}
+	return method, nil
+}
+
+func (svc *fakeAPIGateway) DeleteMethod(input *apigateway.DeleteMethodInput) (*apigateway.DeleteMethodOutput, error) {
+	if err := svc.account.call("DeleteMethod"); err != nil {
+		return nil, err
+	}
+	resource, err := svc.findResource(input.RestApiId, input.ResourceId)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := resource.ResourceMethods[aws.StringValue(input.HttpMethod)]; !ok {
+		return nil, notFound("NotFoundException", "Invalid Method identifier specified")
+	}
+	delete(resource.ResourceMethods, aws.StringValue(input.HttpMethod))
+	return &apigateway.DeleteMethodOutput{}, nil
+}
+
+func (svc *fakeAPIGateway) method(apiID *string, resourceID *string, httpMethod *string) (*apigateway.Method, error) {
+	resource, err := svc.findResource(apiID, resourceID)
+	if err != nil {
+		return nil, err
+	}
+	method, ok := resource.ResourceMethods[aws.StringValue(httpMethod)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid Method identifier specified")
+	}
+	return method, nil
+}
+
+func (svc *fakeAPIGateway) PutIntegration(input *apigateway.PutIntegrationInput) (*apigateway.Integration, error) {
+	if err := svc.account.call("PutIntegration"); err != nil {
+		return nil, err
+	}
+	method, err := svc.method(input.RestApiId, input.ResourceId, input.HttpMethod)
+	if err != nil {
+		return nil, err
+	}
+	method.MethodIntegration = &apigateway.Integration{
+		Type:                 input.Type,
+		Uri:                  input.Uri,
+		HttpMethod:           input.IntegrationHttpMethod,
+		RequestTemplates:     input.RequestTemplates,
+		IntegrationResponses: make(map[string]*apigateway.IntegrationResponse),
+	}
+	return method.MethodIntegration, nil
+}
+
+func (svc *fakeAPIGateway) PutMethodResponse(input *apigateway.PutMethodResponseInput) (*apigateway.MethodResponse, error) {
+	if err := svc.account.call("PutMethodResponse"); err != nil {
+		return nil, err
+	}
+	method, err := svc.method(input.RestApiId, input.ResourceId, input.HttpMethod)
+	if err != nil {
+		return nil, err
+	}
+	if method.MethodResponses == nil {
+		method.MethodResponses = make(map[string]*apigateway.MethodResponse)
+	}
+	response := &apigateway.MethodResponse{StatusCode: input.StatusCode, ResponseParameters: input.ResponseParameters, ResponseModels: input.ResponseModels}
+	method.MethodResponses[aws.StringValue(input.StatusCode)] = response
+	return response, nil
+}
+
+func (svc *fakeAPIGateway) PutIntegrationResponse(input *apigateway.PutIntegrationResponseInput) (*apigateway.IntegrationResponse, error) {
+	if err := svc.account.call("PutIntegrationResponse"); err != nil {
+		return nil, err
+	}
+	method, err := svc.method(input.RestApiId, input.ResourceId, input.HttpMethod)
+	if err != nil {
+		return nil, err
+	}
+	if method.MethodIntegration == nil {
+		return nil, notFound("NotFoundException", "No integration defined for method")
+	}
+	if _, ok := method.MethodResponses[aws.StringValue(input.StatusCode)]; !ok {
+		return nil, notFound("NotFoundException", "Invalid Response status code specified")
+	}
+	response := &apigateway.IntegrationResponse{
+		StatusCode:         input.StatusCode,
+		SelectionPattern:   input.SelectionPattern,
+		ResponseParameters: input.ResponseParameters,
+		ResponseTemplates:  input.ResponseTemplates,
+	}
+	method.MethodIntegration.IntegrationResponses[aws.StringValue(input.StatusCode)] = response
+	return response, nil
+}
+
+func (svc *fakeAPIGateway) CreateDeployment(input *apigateway.CreateDeploymentInput) (*apigateway.Deployment, error) {
+	if err := svc.account.call("CreateDeployment"); err != nil {
+		return nil, err
+	}
+	api, err := svc.findAPI(input.RestApiId)
+	if err != nil {
+		return nil, err
+	}
+	api.deployments++
+	deployment := &apigateway.Deployment{Id: aws.String(svc.account.newID())}
+	name := aws.StringValue(input.StageName)
+	if name == "" {
+		return deployment, nil
+	}
+	// An existing stage keeps its settings, apart from the deployment and
+	// the variables provided
+	stage, ok := api.stages[name]
+	if !ok {
+		stage = &apigateway.Stage{StageName: input.StageName}
+		api.stages[name] = stage
+	}
+	stage.DeploymentId = deployment.Id
+	for key, value := range input.Variables {
+		if stage.Variables == nil {
+			stage.Variables = make(map[string]*string)
+		}
+		stage.Variables[key] = value
+	}
+	return deployment, nil
+}
+
+func (svc *fakeAPIGateway) stage(apiID *string, name *string) (*apigateway.Stage, error) {
+	api, err := svc.findAPI(apiID)
+	if err != nil {
+		return nil, err
+	}
+	stage, ok := api.stages[aws.StringValue(name)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid stage identifier specified")
+	}
+	return stage, nil
+}
+
+// copyStage returns a copy of the stage, as a later change to the stage in
+// the account doesn't change what was returned earlier
+func copyStage(stage *apigateway.Stage) *apigateway.Stage {
+	copied := *stage
+	if stage.Variables != nil {
+		copied.Variables = make(map[string]*string)
+		for key, value := range stage.Variables {
+			copied.Variables[key] = value
+		}
+	}
+	return &copied
+}
+
+func (svc *fakeAPIGateway) GetStage(input *apigateway.GetStageInput) (*apigateway.Stage, error) {
+	if err := svc.account.call("GetStage"); err != nil {
+		return nil, err
+	}
+	stage, err := svc.stage(input.RestApiId, input.StageName)
+	if err != nil {
+		return nil, err
+	}
+	return copyStage(stage), nil
+}
+
+func (svc *fakeAPIGateway) UpdateStage(input *apigateway.UpdateStageInput) (*apigateway.Stage, error) {
+	if err := svc.account.call("UpdateStage"); err != nil {
+		return nil, err
+	}
+	stage, err := svc.stage(input.RestApiId, input.StageName)
+	if err != nil {
+		return nil, err
+	}
+	for _, operation := range input.PatchOperations {
+		path := aws.StringValue(operation.Path)
+		switch {
+		case path == "/deploymentId":
+			stage.DeploymentId = operation.Value
+		case path == "/description":
+			stage.Description = operation.Value
+		case strings.HasPrefix(path, "/variables/"):
+			key := strings.TrimPrefix(path, "/variables/")
+			if aws.StringValue(operation.Op) == "remove" {
+				delete(stage.Variables, key)
+				continue
+			}
+			if stage.Variables == nil {
+				stage.Variables = make(map[string]*string)
+			}
+			stage.Variables[key] = operation.Value
+		case strings.HasPrefix(path, "/*/*/throttling/"):
+			if stage.MethodSettings == nil {
+				stage.MethodSettings = map[string]*apigateway.MethodSetting{"*/*": {}}
+			}
+			setting := stage.MethodSettings["*/*"]
+			if strings.HasSuffix(path, "rateLimit") {
+				rate, _ := strconv.ParseFloat(aws.StringValue(operation.Value), 64)
+				setting.ThrottlingRateLimit = aws.Float64(rate)
+			} else {
+				burst, _ := strconv.ParseInt(aws.StringValue(operation.Value), 10, 64)
+				setting.ThrottlingBurstLimit = aws.Int64(burst)
+			}
+		default:
+			return nil, fmt.Errorf("the fake can't patch %s", path)
+		}
+	}
+	return copyStage(stage), nil
+}
+
+func (svc *fakeAPIGateway) DeleteStage(input *apigateway.DeleteStageInput) (*apigateway.DeleteStageOutput, error) {
+	if err := svc.account.call("DeleteStage"); err != nil {
+		return nil, err
+	}
+	if _, err := svc.stage(input.RestApiId, input.StageName); err != nil {
+		return nil, err
+	}
+	api, _ := svc.findAPI(input.RestApiId)
+	delete(api.stages, aws.StringValue(input.StageName))
+	return &apigateway.DeleteStageOutput{}, nil
+}
+
+func (svc *fakeAPIGateway) GetStages(input *apigateway.GetStagesInput) (*apigateway.GetStagesOutput, error) {
+	if err := svc.account.call("GetStages"); err != nil {
+		return nil, err
+	}
+	api, err := svc.findAPI(input.RestApiId)
+	if err != nil {
+		return nil, err
+	}
+	resp := &apigateway.GetStagesOutput{}
+	for _, stage := range api.stages {
+		resp.Item = append(resp.Item, stage)
+	}
+	return resp, nil
+}
+
+func (svc *fakeAPIGateway) GetAuthorizers(input *apigateway.GetAuthorizersInput) (*apigateway.GetAuthorizersOutput, error) {
+	if err := svc.account.call("GetAuthorizers"); err != nil {
+		return nil, err
+	}
+	api, err := svc.findAPI(input.RestApiId)
+	if err != nil {
+		return nil, err
+	}
+	resp := &apigateway.GetAuthorizersOutput{}
+	for _, authorizer := range api.authorizers {
+		resp.Items = append(resp.Items, authorizer)
+	}
+	return resp, nil
+}
+
+func (svc *fakeAPIGateway) CreateAuthorizer(input *apigateway.CreateAuthorizerInput) (*apigateway.Authorizer, error) {
+	if err := svc.account.call("CreateAuthorizer"); err != nil {
+		return nil, err
+	}
+	api, err := svc.findAPI(input.RestApiId)
+	if err != nil {
+		return nil, err
+	}
+	authorizer := &apigateway.Authorizer{
+		Id:                           aws.String(svc.account.newID()),
+		Name:                         input.Name,
+		Type:                         input.Type,
+		AuthorizerUri:                input.AuthorizerUri,
+		IdentitySource:               input.IdentitySource,
+		ProviderARNs:                 input.ProviderARNs,
+		AuthorizerResultTtlInSeconds: input.AuthorizerResultTtlInSeconds,
+	}
+	api.authorizers[aws.StringValue(authorizer.Id)] = authorizer
+	return authorizer, nil
+}
+
+func (svc *fakeAPIGateway) UpdateAuthorizer(input *apigateway.UpdateAuthorizerInput) (*apigateway.Authorizer, error) {
+	if err := svc.account.call("UpdateAuthorizer"); err != nil {
+		return nil, err
+	}
+	api, err := svc.findAPI(input.RestApiId)
+	if err != nil {
+		return nil, err
+	}
+	authorizer, ok := api.authorizers[aws.StringValue(input.AuthorizerId)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid authorizer identifier specified")
+	}
+	for _, operation := range input.PatchOperations {
+		value := operation.Value
+		switch aws.StringValue(operation.Path) {
+		case "/type":
+			authorizer.Type = value
+		case "/authorizerUri":
+			authorizer.AuthorizerUri = value
+		case "/identitySource":
+			authorizer.IdentitySource = value
+		case "/authorizerResultTtlInSeconds":
+			ttl, _ := strconv.ParseInt(aws.StringValue(value), 10, 64)
+			authorizer.AuthorizerResultTtlInSeconds = aws.Int64(ttl)
+		case "/providerARNs":
+			var pools []*string
+			for _, pool := range authorizer.ProviderARNs {
+				if aws.StringValue(pool) != aws.StringValue(value) {
+					pools = append(pools, pool)
+				}
+			}
+			if aws.StringValue(operation.Op) == "add" {
+				pools = append(pools, value)
+			}
+			authorizer.ProviderARNs = pools
+		default:
+			return nil, fmt.Errorf("the fake can't patch %s", aws.StringValue(operation.Path))
+		}
+	}
+	copied := *authorizer
+	return &copied, nil
+}
+
+func (svc *fakeAPIGateway) DeleteAuthorizer(input *apigateway.DeleteAuthorizerInput) (*apigateway.DeleteAuthorizerOutput, error) {
+	if err := svc.account.call("DeleteAuthorizer"); err != nil {
+		return nil, err
+	}
+	api, err := svc.findAPI(input.RestApiId)
+	if err != nil {
+		return nil, err
+	}
+	delete(api.authorizers, aws.StringValue(input.AuthorizerId))
+	return &apigateway.DeleteAuthorizerOutput{}, nil
+}
+
+func (svc *fakeAPIGateway) GetApiKey(input *apigateway.GetApiKeyInput) (*apigateway.ApiKey, error) {
+	if err := svc.account.call("GetApiKey"); err != nil {
+		return nil, err
+	}
+	key, ok := svc.account.apiKeys[aws.StringValue(input.ApiKey)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid API Key identifier specified")
+	}
+	return key, nil
+}
+
+func (svc *fakeAPIGateway) UpdateApiKey(input *apigateway.UpdateApiKeyInput) (*apigateway.ApiKey, error) {
+	if err := svc.account.call("UpdateApiKey"); err != nil {
+		return nil, err
+	}
+	key, ok := svc.account.apiKeys[aws.StringValue(input.ApiKey)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid API Key identifier specified")
+	}
+	for _, operation := range input.PatchOperations {
+		if aws.StringValue(operation.Path) != "/enabled" {
+			return nil, fmt.Errorf("the fake can't patch %s", aws.StringValue(operation.Path))
+		}
+		key.Enabled = aws.Bool(aws.StringValue(operation.Value) == "true")
+	}
+	return key, nil
+}
+
+// apiKey returns the API key the ARN refers to
+func (svc *fakeAPIGateway) apiKey(arn string) (*apigateway.ApiKey, error) {
+	prefix := fmt.Sprintf("arn:aws:apigateway:%s::/apikeys/", svc.account.region)
+	key, ok := svc.account.apiKeys[strings.TrimPrefix(arn, prefix)]
+	if !strings.HasPrefix(arn, prefix) || !ok {
+		return nil, notFound("NotFoundException", "Invalid resource ARN %s", arn)
+	}
+	return key, nil
+}
+
+func (svc *fakeAPIGateway) TagResource(input *apigateway.TagResourceInput) (*apigateway.TagResourceOutput, error) {
+	if err := svc.account.call("TagResource"); err != nil {
+		return nil, err
+	}
+	key, err := svc.apiKey(aws.StringValue(input.ResourceArn))
+	if err != nil {
+		return nil, err
+	}
+	if key.Tags == nil {
+		key.Tags = make(map[string]*string)
+	}
+	for name, value := range input.Tags {
+		key.Tags[name] = value
+	}
+	return &apigateway.TagResourceOutput{}, nil
+}
+
+func (svc *fakeAPIGateway) UntagResource(input *apigateway.UntagResourceInput) (*apigateway.UntagResourceOutput, error) {
+	if err := svc.account.call("UntagResource"); err != nil {
+		return nil, err
+	}
+	key, err := svc.apiKey(aws.StringValue(input.ResourceArn))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range input.TagKeys {
+		delete(key.Tags, aws.StringValue(name))
+	}
+	return &apigateway.UntagResourceOutput{}, nil
+}
+
+func (svc *fakeAPIGateway) CreateApiKey(input *apigateway.CreateApiKeyInput) (*apigateway.ApiKey, error) {
+	if err := svc.account.call("CreateApiKey"); err != nil {
+		return nil, err
+	}
+	key := &apigateway.ApiKey{
+		Id:          aws.String(svc.account.newID()),
+		Name:        input.Name,
+		Description: input.Description,
+		Enabled:     input.Enabled,
+		Value:       aws.String("secret"),
+	}
+	for _, stageKey := range input.StageKeys {
+		key.StageKeys = append(key.StageKeys, aws.String(aws.StringValue(stageKey.RestApiId)+"/"+aws.StringValue(stageKey.StageName)))
+	}
+	svc.account.apiKeys[aws.StringValue(key.Id)] = key
+	return key, nil
+}
+
+func (svc *fakeAPIGateway) DeleteApiKey(input *apigateway.DeleteApiKeyInput) (*apigateway.DeleteApiKeyOutput, error) {
+	if err := svc.account.call("DeleteApiKey"); err != nil {
+		return nil, err
+	}
+	if _, ok := svc.account.apiKeys[aws.StringValue(input.ApiKey)]; !ok {
+		return nil, notFound("NotFoundException", "Invalid API Key identifier specified")
+	}
+	delete(svc.account.apiKeys, aws.StringValue(input.ApiKey))
+	return &apigateway.DeleteApiKeyOutput{}, nil
+}
+
+// fakePageSize is the number of items in each page of the paged calls, kept
+// small so tests cover several pages
+const fakePageSize = 2
+
+// pages calls fn with the page for every call of the operation, stopping when
+// fn returns false
+func (account *fakeAWS) pages(operation string, count int, fn func(start int, end int, lastPage bool) bool) error {
+	for start := 0; ; start += fakePageSize {
+		if err := account.call(operation); err != nil {
+			return err
+		}
+		end := start + fakePageSize
+		if end >= count {
+			fn(start, count, true)
+			return nil
+		}
+		if !fn(start, end, false) {
+			return nil
+		}
+	}
+}
+
+func (svc *fakeAPIGateway) GetApiKeysPages(input *apigateway.GetApiKeysInput, fn func(*apigateway.GetApiKeysOutput, bool) bool) error {
+	var keys []*apigateway.ApiKey
+	for _, key := range svc.account.apiKeys {
+		if strings.HasPrefix(aws.StringValue(key.Name), aws.StringValue(input.NameQuery)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return aws.StringValue(keys[i].Id) < aws.StringValue(keys[j].Id) })
+	return svc.account.pages("GetApiKeys", len(keys), func(start int, end int, lastPage bool) bool {
+		return fn(&apigateway.GetApiKeysOutput{Items: keys[start:end]}, lastPage)
+	})
+}
+
+// GetUsagePages pages through the days in usage, which is keyed by the IDs of
+// the usage plan and key as plan/key
+func (svc *fakeAPIGateway) GetUsagePages(input *apigateway.GetUsageInput, fn func(*apigateway.Usage, bool) bool) error {
+	keyID := aws.StringValue(input.KeyId)
+	days := svc.account.usage[aws.StringValue(input.UsagePlanId)+"/"+keyID]
+	return svc.account.pages("GetUsage", len(days), func(start int, end int, lastPage bool) bool {
+		return fn(&apigateway.Usage{
+			UsagePlanId: input.UsagePlanId,
+			StartDate:   input.StartDate,
+			EndDate:     input.EndDate,
+			Items:       map[string][][]*int64{keyID: days[start:end]},
+		}, lastPage)
+	})
+}
+
+func (svc *fakeAPIGateway) GetUsagePlansPages(input *apigateway.GetUsagePlansInput, fn func(*apigateway.GetUsagePlansOutput, bool) bool) error {
+	var ids []string
+	for id := range svc.account.usagePlans {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var plans []*apigateway.UsagePlan
+	for _, id := range ids {
+		if input.KeyId == nil || contains(svc.account.planKeys[id], aws.StringValue(input.KeyId)) {
+			plans = append(plans, svc.account.usagePlans[id])
+		}
+	}
+	return svc.account.pages("GetUsagePlans", len(plans), func(start int, end int, lastPage bool) bool {
+		return fn(&apigateway.GetUsagePlansOutput{Items: plans[start:end]}, lastPage)
+	})
+}
+
+func (svc *fakeAPIGateway) CreateUsagePlanKey(input *apigateway.CreateUsagePlanKeyInput) (*apigateway.UsagePlanKey, error) {
+	if err := svc.account.call("CreateUsagePlanKey"); err != nil {
+		return nil, err
+	}
+	id := aws.StringValue(input.UsagePlanId)
+	svc.account.planKeys[id] = append(svc.account.planKeys[id], aws.StringValue(input.KeyId))
+	return &apigateway.UsagePlanKey{Id: input.KeyId}, nil
+}
+
+func (svc *fakeAPIGateway) DeleteUsagePlanKey(input *apigateway.DeleteUsagePlanKeyInput) (*apigateway.DeleteUsagePlanKeyOutput, error) {
+	if err := svc.account.call("DeleteUsagePlanKey"); err != nil {
+		return nil, err
+	}
+	id := aws.StringValue(input.UsagePlanId)
+	var keys []string
+	for _, key := range svc.account.planKeys[id] {
+		if key != aws.StringValue(input.KeyId) {
+			keys = append(keys, key)
+		}
+	}
+	svc.account.planKeys[id] = keys
+	return &apigateway.DeleteUsagePlanKeyOutput{}, nil
+}
+
+func (svc *fakeAPIGateway) CreateUsagePlan(input *apigateway.CreateUsagePlanInput) (*apigateway.UsagePlan, error) {
+	if err := svc.account.call("CreateUsagePlan"); err != nil {
+		return nil, err
+	}
+	plan := svc.account.addUsagePlan(aws.StringValue(input.Name))
+	plan.Description = input.Description
+	plan.ApiStages = input.ApiStages
+	plan.Throttle = input.Throttle
+	plan.Quota = input.Quota
+	return plan, nil
+}
+
+func (svc *fakeAPIGateway) UpdateUsagePlan(input *apigateway.UpdateUsagePlanInput) (*apigateway.UsagePlan, error) {
+	if err := svc.account.call("UpdateUsagePlan"); err != nil {
+		return nil, err
+	}
+	plan, ok := svc.account.usagePlans[aws.StringValue(input.UsagePlanId)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid Usage Plan ID specified")
+	}
+	for _, operation := range input.PatchOperations {
+		if aws.StringValue(operation.Path) != "/apiStages" {
+			return nil, fmt.Errorf("the fake can't patch %s", aws.StringValue(operation.Path))
+		}
+		parts := strings.SplitN(aws.StringValue(operation.Value), ":", 2)
+		if aws.StringValue(operation.Op) == "add" {
+			plan.ApiStages = append(plan.ApiStages, &apigateway.ApiStage{ApiId: aws.String(parts[0]), Stage: aws.String(parts[1])})
+			continue
+		}
+		var stages []*apigateway.ApiStage
+		for _, stage := range plan.ApiStages {
+			if aws.StringValue(stage.ApiId) != parts[0] || aws.StringValue(stage.Stage) != parts[1] {
+				stages = append(stages, stage)
+			}
+		}
+		plan.ApiStages = stages
+	}
+	return plan, nil
+}
+
+func (svc *fakeAPIGateway) DeleteUsagePlan(input *apigateway.DeleteUsagePlanInput) (*apigateway.DeleteUsagePlanOutput, error) {
+	if err := svc.account.call("DeleteUsagePlan"); err != nil {
+		return nil, err
+	}
+	plan, ok := svc.account.usagePlans[aws.StringValue(input.UsagePlanId)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid Usage Plan ID specified")
+	}
+	if len(plan.ApiStages) != 0 {
+		return nil, awserr.New("BadRequestException", "Cannot delete Usage Plan with associated API stages", nil)
+	}
+	delete(svc.account.usagePlans, aws.StringValue(input.UsagePlanId))
+	delete(svc.account.planKeys, aws.StringValue(input.UsagePlanId))
+	return &apigateway.DeleteUsagePlanOutput{}, nil
+}
+
+func (svc *fakeAPIGateway) CreateDomainName(input *apigateway.CreateDomainNameInput) (*apigateway.DomainName, error) {
+	if err := svc.account.call("CreateDomainName"); err != nil {
+		return nil, err
+	}
+	domain := &apigateway.DomainName{
+		DomainName:             input.DomainName,
+		CertificateArn:         input.CertificateArn,
+		RegionalCertificateArn: input.RegionalCertificateArn,
+		EndpointConfiguration:  input.EndpointConfiguration,
+	}
+	if input.RegionalCertificateArn != nil {
+		domain.RegionalDomainName = aws.String("d-" + svc.account.newID() + ".execute-api." + svc.account.region + ".amazonaws.com")
+		domain.RegionalHostedZoneId = aws.String("Z1UJRXOUMOOFQ8")
+	} else {
+		domain.DistributionDomainName = aws.String(svc.account.newID() + ".cloudfront.net")
+		domain.DistributionHostedZoneId = aws.String("Z2FDTNDATAQYW2")
+	}
+	svc.account.domains[aws.StringValue(input.DomainName)] = domain
+	return domain, nil
+}
+
+func (svc *fakeAPIGateway) GetDomainName(input *apigateway.GetDomainNameInput) (*apigateway.DomainName, error) {
+	if err := svc.account.call("GetDomainName"); err != nil {
+		return nil, err
+	}
+	domain, ok := svc.account.domains[aws.StringValue(input.DomainName)]
+	if !ok {
+		return nil, notFound("NotFoundException", "Invalid domain name identifier specified")
+	}
+	return domain, nil
+}
+
+func (svc *fakeAPIGateway) GetDomainNamesPages(input *apigateway.GetDomainNamesInput, fn func(*apigateway.GetDomainNamesOutput, bool) bool) error {
+	var names []string
+	for name := range svc.account.domains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return svc.account.pages("GetDomainNames", len(names), func(start int, end int, lastPage bool) bool {
+		page := &apigateway.GetDomainNamesOutput{}
+		for _, name := range names[start:end] {
+			page.Items = append(page.Items, svc.account.domains[name])
+		}
+		return fn(page, lastPage)
+	})
+}
+
+func (svc *fakeAPIGateway) CreateBasePathMapping(input *apigateway.CreateBasePathMappingInput) (*apigateway.BasePathMapping, error) {
+	if err := svc.account.call("CreateBasePathMapping"); err != nil {
+		return nil, err
+	}
+	domain := aws.StringValue(input.DomainName)
+	if _, ok := svc.account.domains[domain]; !ok {
+		return nil, notFound("NotFoundException", "Invalid domain name identifier specified")
+	}
+	basePath := aws.StringValue(input.BasePath)
+	if basePath == "" {
+		basePath = rootBasePath
+	}
+	if svc.account.mappings[domain] == nil {
+		svc.account.mappings[domain] = make(map[string]*apigateway.BasePathMapping)
+	}
+	if _, ok := svc.account.mappings[domain][basePath]; ok {
+		return nil, awserr.New("ConflictException", "Base path already exists for this domain name", nil)
+	}
+	mapping := &apigateway.BasePathMapping{BasePath: aws.String(basePath), RestApiId: input.RestApiId, Stage: input.Stage}
+	svc.account.mappings[domain][basePath] = mapping
+	return mapping, nil
+}
+
+func (svc *fakeAPIGateway) GetBasePathMappingsPages(input *apigateway.GetBasePathMappingsInput, fn func(*apigateway.GetBasePathMappingsOutput, bool) bool) error {
+	mappings := svc.account.mappings[aws.StringValue(input.DomainName)]
+	var paths []string
+	for path := range mappings {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return svc.account.pages("GetBasePathMappings", len(paths), func(start int, end int, lastPage bool) bool {
+		page := &apigateway.GetBasePathMappingsOutput{}
+		for _, path := range paths[start:end] {
+			page.Items = append(page.Items, mappings[path])
+		}
+		return fn(page, lastPage)
+	})
+}
+
+func (svc *fakeAPIGateway) DeleteBasePathMapping(input *apigateway.DeleteBasePathMappingInput) (*apigateway.DeleteBasePathMappingOutput, error) {
+	if err := svc.account.call("DeleteBasePathMapping"); err != nil {
+		return nil, err
+	}
+	mappings := svc.account.mappings[aws.StringValue(input.DomainName)]
+	if _, ok := mappings[aws.StringValue(input.BasePath)]; !ok {
+		return nil, notFound("NotFoundException", "Invalid base path mapping identifier specified")
+	}
+	delete(mappings, aws.StringValue(input.BasePath))
+	return &apigateway.DeleteBasePathMappingOutput{}, nil
+}
+
+// codeSha256 returns the checksum of the code sent directly, or of the object
+// in S3 it refers to
+func (account *fakeAWS) codeSha256(zipFile []byte, bucket *string, key *string) string {
+	if bucket != nil {
+		zipFile = account.objects[aws.StringValue(bucket)+"/"+aws.StringValue(key)]
+	}
+	return NewPackage(zipFile).SHA256
+}
+
+type fakeLambda struct {
+	lambdaiface.LambdaAPI
+	account *fakeAWS
+}
+
+func (svc *fakeLambda) findFunction(name *string) (*lambda.FunctionConfiguration, error) {
+	function, ok := svc.account.functions[fakeFunctionName(name)]
+	if !ok {
+		return nil, notFound("ResourceNotFoundException", "Function not found: %s", aws.StringValue(name))
+	}
+	return function, nil
+}
+
+func (svc *fakeLambda) GetFunctionConfiguration(input *lambda.GetFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	if err := svc.account.call("GetFunctionConfiguration"); err != nil {
+		return nil, err
+	}
+	function, err := svc.findFunction(input.FunctionName)
+	if err != nil {
+		return nil, err
+	}
+	copied := *function
+	return &copied, nil
+}
+
+func (svc *fakeLambda) CreateFunction(input *lambda.CreateFunctionInput) (*lambda.FunctionConfiguration, error) {
+	if err := svc.account.call("CreateFunction"); err != nil {
+		return nil, err
+	}
+	name := aws.StringValue(input.FunctionName)
+	if _, ok := svc.account.functions[name]; ok {
+		return nil, awserr.New("ResourceConflictException", "Function already exist: "+name, nil)
+	}
+	function := &lambda.FunctionConfiguration{
+		FunctionName: input.FunctionName,
+		FunctionArn:  aws.String(svc.account.arn("lambda", "function:"+name)),
+		Runtime:      input.Runtime,
+		Handler:      input.Handler,
+		Role:         input.Role,
+		MemorySize:   input.MemorySize,
+		Timeout:      input.Timeout,
+		Description:  input.Description,
+		CodeSha256:   aws.String(svc.account.codeSha256(input.Code.ZipFile, input.Code.S3Bucket, input.Code.S3Key)),
+		Version:      aws.String("$LATEST"),
+	}
+	if input.Environment != nil {
+		function.Environment = &lambda.EnvironmentResponse{Variables: input.Environment.Variables}
+	}
+	if input.TracingConfig != nil {
+		function.TracingConfig = &lambda.TracingConfigResponse{Mode: input.TracingConfig.Mode}
+	}
+	function.Architectures = input.Architectures
+	function.DeadLetterConfig = input.DeadLetterConfig
+	svc.account.tags[aws.StringValue(function.FunctionArn)] = input.Tags
+	svc.account.functions[name] = function
+	copied := *function
+	return &copied, nil
+}
+
+func (svc *fakeLambda) DeleteFunction(input *lambda.DeleteFunctionInput) (*lambda.DeleteFunctionOutput, error) {
+	if err := svc.account.call("DeleteFunction"); err != nil {
+		return nil, err
+	}
+	name := aws.StringValue(input.FunctionName)
+	if _, err := svc.findFunction(input.FunctionName); err != nil {
+		return nil, err
+	}
+	delete(svc.account.functions, name)
+	// The policies of the function and its aliases go with it
+	for key := range svc.account.policies {
+		if key == name || strings.HasPrefix(key, name+":") {
+			delete(svc.account.policies, key)
+		}
+	}
+	for key := range svc.account.aliases {
+		if strings.HasPrefix(key, name+":") {
+			delete(svc.account.aliases, key)
+		}
+	}
+	return &lambda.DeleteFunctionOutput{}, nil
+}
+
+func (svc *fakeLambda) UpdateFunctionCode(input *lambda.UpdateFunctionCodeInput) (*lambda.FunctionConfiguration, error) {
+	if err := svc.account.call("UpdateFunctionCode"); err != nil {
+		return nil, err
+	}
+	function, err := svc.findFunction(input.FunctionName)
+	if err != nil {
+		return nil, err
+	}
+	function.CodeSha256 = aws.String(svc.account.codeSha256(input.ZipFile, input.S3Bucket, input.S3Key))
+	if input.Architectures != nil {
+		function.Architectures = input.Architectures
+	}
+	copied := *function
+	return &copied, nil
+}
+
+func (svc *fakeLambda) UpdateFunctionConfiguration(input *lambda.UpdateFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	if err := svc.account.call("UpdateFunctionConfiguration"); err != nil {
+		return nil, err
+	}
+	function, err := svc.findFunction(input.FunctionName)
+	if err != nil {
+		return nil, err
+	}
+	if input.Runtime != nil {
+		function.Runtime = input.Runtime
+	}
+	if input.Handler != nil {
+		function.Handler = input.Handler
+	}
+	if input.MemorySize != nil {
+		function.MemorySize = input.MemorySize
+	}
+	if input.Timeout != nil {
+		function.Timeout = input.Timeout
+	}
+	if input.Role != nil {
+		function.Role = input.Role
+	}
+	if input.Description != nil {
+		function.Description = input.Description
+	}
+	if input.Environment != nil {
+		function.Environment = &lambda.EnvironmentResponse{Variables: input.Environment.Variables}
+	}
+	if input.TracingConfig != nil {
+		function.TracingConfig = &lambda.TracingConfigResponse{Mode: input.TracingConfig.Mode}
+	}
+	if input.DeadLetterConfig != nil {
+		function.DeadLetterConfig = input.DeadLetterConfig
+	}
+	copied := *function
+	return &copied, nil
+}
+
+func (svc *fakeLambda) WaitUntilFunctionUpdated(input *lambda.GetFunctionConfigurationInput) error {
+	return svc.account.call("WaitUntilFunctionUpdated")
+}
+
+func (svc *fakeLambda) WaitUntilFunctionActive(input *lambda.GetFunctionConfigurationInput) error {
+	return svc.account.call("WaitUntilFunctionActive")
+}
+
+func (svc *fakeLambda) PutFunctionConcurrency(input *lambda.PutFunctionConcurrencyInput) (*lambda.PutFunctionConcurrencyOutput, error) {
+	if err := svc.account.call("PutFunctionConcurrency"); err != nil {
+		return nil, err
+	}
+	svc.account.concurrency[aws.StringValue(input.FunctionName)] = input.ReservedConcurrentExecutions
+	return &lambda.PutFunctionConcurrencyOutput{ReservedConcurrentExecutions: input.ReservedConcurrentExecutions}, nil
+}
+
+func (svc *fakeLambda) GetFunctionConcurrency(input *lambda.GetFunctionConcurrencyInput) (*lambda.GetFunctionConcurrencyOutput, error) {
+	if err := svc.account.call("GetFunctionConcurrency"); err != nil {
+		return nil, err
+	}
+	return &lambda.GetFunctionConcurrencyOutput{ReservedConcurrentExecutions: svc.account.concurrency[aws.StringValue(input.FunctionName)]}, nil
+}
+
+func (svc *fakeLambda) TagResource(input *lambda.TagResourceInput) (*lambda.TagResourceOutput, error) {
+	if err := svc.account.call("TagResource"); err != nil {
+		return nil, err
+	}
+	resource := aws.StringValue(input.Resource)
+	if svc.account.tags[resource] == nil {
+		svc.account.tags[resource] = make(map[string]*string)
+	}
+	for key, value := range input.Tags {
+		svc.account.tags[resource][key] = value
+	}
+	return &lambda.TagResourceOutput{}, nil
+}
+
+func (svc *fakeLambda) ListTags(input *lambda.ListTagsInput) (*lambda.ListTagsOutput, error) {
+	if err := svc.account.call("ListTags"); err != nil {
+		return nil, err
+	}
+	return &lambda.ListTagsOutput{Tags: svc.account.tags[aws.StringValue(input.Resource)]}, nil
+}
+
+func (svc *fakeLambda) PublishVersion(input *lambda.PublishVersionInput) (*lambda.FunctionConfiguration, error) {
+	if err := svc.account.call("PublishVersion"); err != nil {
+		return nil, err
+	}
+	function, err := svc.findFunction(input.FunctionName)
+	if err != nil {
+		return nil, err
+	}
+	name := aws.StringValue(input.FunctionName)
+	svc.account.versions[name]++
+	version := *function
+	version.Version = aws.String(fmt.Sprintf("%d", svc.account.versions[name]))
+	return &version, nil
+}
+
+func (svc *fakeLambda) GetAlias(input *lambda.GetAliasInput) (*lambda.AliasConfiguration, error) {
+	if err := svc.account.call("GetAlias"); err != nil {
+		return nil, err
+	}
+	alias, ok := svc.account.aliases[aws.StringValue(input.FunctionName)+":"+aws.StringValue(input.Name)]
+	if !ok {
+		return nil, notFound("ResourceNotFoundException", "Alias not found")
+	}
+	return alias, nil
+}
+
+func (svc *fakeLambda) CreateAlias(input *lambda.CreateAliasInput) (*lambda.AliasConfiguration, error) {
+	if err := svc.account.call("CreateAlias"); err != nil {
+		return nil, err
+	}
+	alias := &lambda.AliasConfiguration{Name: input.Name, FunctionVersion: input.FunctionVersion}
+	svc.account.aliases[aws.StringValue(input.FunctionName)+":"+aws.StringValue(input.Name)] = alias
+	return alias, nil
+}
+
+func (svc *fakeLambda) UpdateAlias(input *lambda.UpdateAliasInput) (*lambda.AliasConfiguration, error) {
+	if err := svc.account.call("UpdateAlias"); err != nil {
+		return nil, err
+	}
+	alias, ok := svc.account.aliases[aws.StringValue(input.FunctionName)+":"+aws.StringValue(input.Name)]
+	if !ok {
+		return nil, notFound("ResourceNotFoundException", "Alias not found")
+	}
+	alias.FunctionVersion = input.FunctionVersion
+	return alias, nil
+}
+
+func (svc *fakeLambda) DeleteAlias(input *lambda.DeleteAliasInput) (*lambda.DeleteAliasOutput, error) {
+	if err := svc.account.call("DeleteAlias"); err != nil {
+		return nil, err
+	}
+	delete(svc.account.aliases, aws.StringValue(input.FunctionName)+":"+aws.StringValue(input.Name))
+	return &lambda.DeleteAliasOutput{}, nil
+}
+
+func (svc *fakeLambda) ListAliasesPages(input *lambda.ListAliasesInput, fn func(*lambda.ListAliasesOutput, bool) bool) error {
+	if err := svc.account.call("ListAliases"); err != nil {
+		return err
+	}
+	page := &lambda.ListAliasesOutput{}
+	for key, alias := range svc.account.aliases {
+		if strings.HasPrefix(key, aws.StringValue(input.FunctionName)+":") {
+			page.Aliases = append(page.Aliases, alias)
+		}
+	}
+	fn(page, true)
+	return nil
+}
+
+func (svc *fakeLambda) ListVersionsByFunctionPages(input *lambda.ListVersionsByFunctionInput, fn func(*lambda.ListVersionsByFunctionOutput, bool) bool) error {
+	if err := svc.account.call("ListVersionsByFunction"); err != nil {
+		return err
+	}
+	page := &lambda.ListVersionsByFunctionOutput{
+		Versions: []*lambda.FunctionConfiguration{{Version: aws.String("$LATEST")}},
+	}
+	for version := 1; version <= svc.account.versions[aws.StringValue(input.FunctionName)]; version++ {
+		page.Versions = append(page.Versions, &lambda.FunctionConfiguration{Version: aws.String(fmt.Sprintf("%d", version))})
+	}
+	fn(page, true)
+	return nil
+}
+
+// policyKey is the function, followed by the qualifier if there is one
+// policyKey returns the key of the policy of the function, which can be
+// provided by name or by ARN
+func policyKey(function *string, qualifier *string) string {
+	name := fakeFunctionName(function)
+	if aws.StringValue(qualifier) == "" {
+		return name
+	}
+	return name + ":" + aws.StringValue(qualifier)
+}
+
+// fakeFunctionName returns the name of the function, which can be provided by
+// name or by its unqualified ARN
+func fakeFunctionName(function *string) string {
+	name := aws.StringValue(function)
+	if strings.HasPrefix(name, "arn:") {
+		return name[strings.LastIndex(name, ":")+1:]
+	}
+	return name
+}
+
+func (svc *fakeLambda) AddPermission(input *lambda.AddPermissionInput) (*lambda.AddPermissionOutput, error) {
+	if err := svc.account.call("AddPermission"); err != nil {
+		return nil, err
+	}
+	if _, err := svc.findFunction(input.FunctionName); err != nil {
+		return nil, err
+	}
+	key := policyKey(input.FunctionName, input.Qualifier)
+	if svc.account.policies[key] == nil {
+		svc.account.policies[key] = make(map[string]fakeStatement)
+	}
+	sid := aws.StringValue(input.StatementId)
+	if _, ok := svc.account.policies[key][sid]; ok {
+		return nil, awserr.New("ResourceConflictException", "The statement id provided already exists", nil)
+	}
+	svc.account.policies[key][sid] = fakeStatement{
+		Sid:       sid,
+		Condition: map[string]map[string]string{"ArnLike": {"AWS:SourceArn": aws.StringValue(input.SourceArn)}},
+	}
+	return &lambda.AddPermissionOutput{}, nil
+}
+
+func (svc *fakeLambda) RemovePermission(input *lambda.RemovePermissionInput) (*lambda.RemovePermissionOutput, error) {
+	if err := svc.account.call("RemovePermission"); err != nil {
+		return nil, err
+	}
+	key := policyKey(input.FunctionName, input.Qualifier)
+	if _, ok := svc.account.policies[key][aws.StringValue(input.StatementId)]; !ok {
+		return nil, notFound("ResourceNotFoundException", "Statement not found")
+	}
+	delete(svc.account.policies[key], aws.StringValue(input.StatementId))
+	if len(svc.account.policies[key]) == 0 {
+		delete(svc.account.policies, key)
+	}
+	return &lambda.RemovePermissionOutput{}, nil
+}
+
+func (svc *fakeLambda) GetPolicy(input *lambda.GetPolicyInput) (*lambda.GetPolicyOutput, error) {
+	if err := svc.account.call("GetPolicy"); err != nil {
+		return nil, err
+	}
+	statements, ok := svc.account.policies[policyKey(input.FunctionName, input.Qualifier)]
+	if !ok {
+		return nil, notFound("ResourceNotFoundException", "The resource you requested does not exist.")
+	}
+	policy := struct{ Statement []fakeStatement }{}
+	for _, statement := range statements {
+		policy.Statement = append(policy.Statement, statement)
+	}
+	document, _ := json.Marshal(policy)
+	return &lambda.GetPolicyOutput{Policy: aws.String(string(document))}, nil
+}
+
+type fakeIAM struct {
+	iamiface.IAMAPI
+	account *fakeAWS
+}
+
+func (svc *fakeIAM) GetRole(input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	if err := svc.account.call("GetRole"); err != nil {
+		return nil, err
+	}
+	role, ok := svc.account.roles[aws.StringValue(input.RoleName)]
+	if !ok {
+		return nil, notFound("NoSuchEntity", "The role with name %s cannot be found.", aws.StringValue(input.RoleName))
+	}
+	return &iam.GetRoleOutput{Role: role}, nil
+}
+
+func (svc *fakeIAM) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	if err := svc.account.call("CreateRole"); err != nil {
+		return nil, err
+	}
+	svc.account.addRole(aws.StringValue(input.RoleName))
+	return &iam.CreateRoleOutput{Role: svc.account.roles[aws.StringValue(input.RoleName)]}, nil
+}
+
+func (svc *fakeIAM) PutRolePolicy(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	if err := svc.account.call("PutRolePolicy"); err != nil {
+		return nil, err
+	}
+	svc.account.rolePolicies[aws.StringValue(input.RoleName)] = aws.StringValue(input.PolicyDocument)
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func (svc *fakeIAM) GetRolePolicy(input *iam.GetRolePolicyInput) (*iam.GetRolePolicyOutput, error) {
+	if err := svc.account.call("GetRolePolicy"); err != nil {
+		return nil, err
+	}
+	document, ok := svc.account.rolePolicies[aws.StringValue(input.RoleName)]
+	if !ok {
+		return nil, notFound("NoSuchEntity", "The role policy cannot be found.")
+	}
+	return &iam.GetRolePolicyOutput{PolicyDocument: aws.String(document)}, nil
+}
+
+type fakeEvents struct {
+	cloudwatcheventsiface.CloudWatchEventsAPI
+	account *fakeAWS
+}
+
+func (svc *fakeEvents) DescribeRule(input *cloudwatchevents.DescribeRuleInput) (*cloudwatchevents.DescribeRuleOutput, error) {
+	if err := svc.account.call("DescribeRule"); err != nil {
+		return nil, err
+	}
+	rule, ok := svc.account.rules[aws.StringValue(input.Name)]
+	if !ok {
+		return nil, notFound("ResourceNotFoundException", "Rule %s does not exist.", aws.StringValue(input.Name))
+	}
+	return rule, nil
+}
+
+func (svc *fakeEvents) PutRule(input *cloudwatchevents.PutRuleInput) (*cloudwatchevents.PutRuleOutput, error) {
+	if err := svc.account.call("PutRule"); err != nil {
+		return nil, err
+	}
+	arn := svc.account.arn("events", "rule/"+aws.StringValue(input.Name))
+	svc.account.rules[aws.StringValue(input.Name)] = &cloudwatchevents.DescribeRuleOutput{
+		Name:               input.Name,
+		Arn:                aws.String(arn),
+		ScheduleExpression: input.ScheduleExpression,
+	}
+	return &cloudwatchevents.PutRuleOutput{RuleArn: aws.String(arn)}, nil
+}
+
+func (svc *fakeEvents) PutTargets(input *cloudwatchevents.PutTargetsInput) (*cloudwatchevents.PutTargetsOutput, error) {
+	if err := svc.account.call("PutTargets"); err != nil {
+		return nil, err
+	}
+	rule := aws.StringValue(input.Rule)
+	// Targets with the same ID are replaced
+	for _, target := range input.Targets {
+		replaced := false
+		for index, existing := range svc.account.targets[rule] {
+			if aws.StringValue(existing.Id) == aws.StringValue(target.Id) {
+				svc.account.targets[rule][index] = target
+				replaced = true
+			}
+		}
+		if !replaced {
+			svc.account.targets[rule] = append(svc.account.targets[rule], target)
+		}
+	}
+	return &cloudwatchevents.PutTargetsOutput{}, nil
+}
+
+func (svc *fakeEvents) ListTargetsByRule(input *cloudwatchevents.ListTargetsByRuleInput) (*cloudwatchevents.ListTargetsByRuleOutput, error) {
+	if err := svc.account.call("ListTargetsByRule"); err != nil {
+		return nil, err
+	}
+	return &cloudwatchevents.ListTargetsByRuleOutput{Targets: svc.account.targets[aws.StringValue(input.Rule)]}, nil
+}
+
+func (svc *fakeEvents) RemoveTargets(input *cloudwatchevents.RemoveTargetsInput) (*cloudwatchevents.RemoveTargetsOutput, error) {
+	if err := svc.account.call("RemoveTargets"); err != nil {
+		return nil, err
+	}
+	rule := aws.StringValue(input.Rule)
+	var kept []*cloudwatchevents.Target
+	for _, target := range svc.account.targets[rule] {
+		remove := false
+		for _, id := range input.Ids {
+			remove = remove || aws.StringValue(id) == aws.StringValue(target.Id)
+		}
+		if !remove {
+			kept = append(kept, target)
+		}
+	}
+	svc.account.targets[rule] = kept
+	return &cloudwatchevents.RemoveTargetsOutput{}, nil
+}
+
+func (svc *fakeEvents) DeleteRule(input *cloudwatchevents.DeleteRuleInput) (*cloudwatchevents.DeleteRuleOutput, error) {
+	if err := svc.account.call("DeleteRule"); err != nil {
+		return nil, err
+	}
+	delete(svc.account.rules, aws.StringValue(input.Name))
+	delete(svc.account.targets, aws.StringValue(input.Name))
+	return &cloudwatchevents.DeleteRuleOutput{}, nil
+}
+
+type fakeS3 struct {
+	s3iface.S3API
+	account *fakeAWS
+}
+
+func (svc *fakeS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	if err := svc.account.call("PutObject"); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	svc.account.objects[aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
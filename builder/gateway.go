@@ -4,16 +4,59 @@ import (
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/apigateway"
 )
 
-// CreateAPIGateway creates an API Gateway and attaches it to the GatewayBuilder
-func (builder *GatewayBuilder) CreateAPIGateway() error {
-	svc := apigateway.New(session.New(), &aws.Config{Region: builder.Settings.Region})
+// APIName returns the name Aqua uses for the API of the function
+func (builder *GatewayBuilder) APIName() string {
+	return fmt.Sprintf("%sLambda", builder.Settings.CleanName())
+}
+
+// EnsureAPIGateway retrieves the API Gateway for the function, or creates a
+// new one, and attaches it to the GatewayBuilder
+func (builder *GatewayBuilder) EnsureAPIGateway() error {
+	gateway, err := builder.findAPIGateway()
+	if err != nil {
+		return err
+	}
+	if gateway == nil {
+		return builder.createAPIGateway()
+	}
+	builder.APIGateway = gateway
+	return nil
+}
+
+// findAPIGateway returns the existing API Gateway for the function, or nil
+// if there is none. If an API ID was provided that API is used, otherwise it
+// looks for an API with the name Aqua gives it.
+func (builder *GatewayBuilder) findAPIGateway() (*apigateway.RestApi, error) {
+	if aws.StringValue(builder.Settings.APIID) != "" {
+		return builder.Clients.APIGateway.GetRestApi(&apigateway.GetRestApiInput{
+			RestApiId: builder.Settings.APIID,
+		})
+	}
+
+	apis, err := findRestAPIs(builder.Clients, builder.APIName())
+	if err != nil {
+		return nil, err
+	}
+	switch len(apis) {
+	case 0:
+		return nil, nil
+	case 1:
+		return apis[0], nil
+	default:
+		return nil, fmt.Errorf("There are %d APIs named %s, please select one by its API ID",
+			len(apis), builder.APIName())
+	}
+}
+
+// createAPIGateway creates an API Gateway and attaches it to the GatewayBuilder
+func (builder *GatewayBuilder) createAPIGateway() error {
+	svc := builder.Clients.APIGateway
 
 	params := &apigateway.CreateRestApiInput{
-		Name: aws.String(fmt.Sprintf("%sLambda", builder.Settings.CleanName())),
+		Name: aws.String(builder.APIName()),
 		Description: aws.String(fmt.Sprintf("API for Lambda function %s",
 			aws.StringValue(builder.Settings.FunctionName))),
 	}
@@ -23,57 +66,181 @@ func (builder *GatewayBuilder) CreateAPIGateway() error {
 	}
 
 	builder.APIGateway = gateway
+	builder.undoRestAPI(gateway)
 
 	return nil
 }
 
-// AddResources adds a Resource (endpoint) to the API Gateway and attaches it to the GatewayBuilder
+// AddResources adds a Resource (endpoint) to the API Gateway and attaches it
+// to the GatewayBuilder. Resources that already exist are reused.
 func (builder *GatewayBuilder) AddResources() error {
-	svc := apigateway.New(session.New(), &aws.Config{Region: builder.Settings.Region})
+	resources, err := getResources(builder.Clients, builder.APIGateway.Id)
 
-	params := &apigateway.GetResourcesInput{
-		RestApiId: builder.APIGateway.Id,
-		Limit:     aws.Int64(1),
+	if err != nil {
+		return err
+	}
+
+	root, ok := resources["/"]
+	if !ok {
+		return fmt.Errorf("Couldn't find the root resource of API %s", aws.StringValue(builder.APIGateway.Id))
 	}
-	resp, err := svc.GetResources(params)
+	builder.RootResource = root
 
+	path := "/" + builder.Settings.CleanName()
+	builder.Resource, err = builder.ensureResource(resources[path], builder.RootResource, builder.Settings.CleanName())
 	if err != nil {
 		return err
 	}
 
-	builder.RootResource = resp.Items[0]
+	if !aws.BoolValue(builder.Settings.Proxy) {
+		// Switching away from proxy mode leaves nothing to route to the old
+		// greedy path variable
+		if existing, ok := resources[path+"/{proxy+}"]; ok {
+			return builder.removeResource(existing)
+		}
+		return nil
+	}
+
+	// A greedy path variable passes every path below the resource to the function
+	builder.ProxyResource, err = builder.ensureResource(resources[path+"/{proxy+}"], builder.Resource, "{proxy+}")
+
+	return err
+}
 
+// getResources returns all the resources of the API, mapped by their path
+func getResources(clients *Clients, apiID *string) (map[string]*apigateway.Resource, error) {
+	resources := make(map[string]*apigateway.Resource)
+	params := &apigateway.GetResourcesInput{
+		RestApiId: apiID,
+		Limit:     aws.Int64(500),
+	}
+	err := clients.APIGateway.GetResourcesPages(params, func(page *apigateway.GetResourcesOutput, lastPage bool) bool {
+		for _, resource := range page.Items {
+			resources[aws.StringValue(resource.Path)] = resource
+		}
+		return true
+	})
+	return resources, err
+}
+
+// ensureResource returns the existing resource, or creates it below the
+// parent if it doesn't exist yet
+func (builder *GatewayBuilder) ensureResource(existing *apigateway.Resource, parent *apigateway.Resource, pathPart string) (*apigateway.Resource, error) {
+	if existing != nil {
+		return existing, nil
+	}
 	resourceParams := &apigateway.CreateResourceInput{
-		ParentId:  builder.RootResource.Id,
-		PathPart:  aws.String(builder.Settings.CleanName()),
+		ParentId:  parent.Id,
+		PathPart:  aws.String(pathPart),
 		RestApiId: builder.APIGateway.Id,
 	}
-	resource, err := svc.CreateResource(resourceParams)
+	resource, err := builder.Clients.APIGateway.CreateResource(resourceParams)
 
+	if err != nil {
+		return nil, err
+	}
+	builder.undoResource(resource)
+
+	return resource, nil
+}
+
+// removeResource deletes a resource that Aqua no longer uses, and registers
+// an undo that recreates it with its methods
+func (builder *GatewayBuilder) removeResource(resource *apigateway.Resource) error {
+	methods, err := builder.getMethods(resource)
+	if err != nil {
+		return err
+	}
+	_, err = builder.Clients.APIGateway.DeleteResource(&apigateway.DeleteResourceInput{
+		ResourceId: resource.Id,
+		RestApiId:  builder.APIGateway.Id,
+	})
 	if err != nil {
 		return err
 	}
+	builder.undoResourceRemoval(resource, methods)
+	return nil
+}
 
-	builder.Resource = resource
+// getMethods returns the full configuration of each method of the resource
+func (builder *GatewayBuilder) getMethods(resource *apigateway.Resource) ([]*apigateway.Method, error) {
+	var methods []*apigateway.Method
+	for method := range resource.ResourceMethods {
+		configuration, err := builder.Clients.APIGateway.GetMethod(&apigateway.GetMethodInput{
+			HttpMethod: aws.String(method),
+			ResourceId: resource.Id,
+			RestApiId:  builder.APIGateway.Id,
+		})
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, configuration)
+	}
+	return methods, nil
+}
 
+// clearMethods removes all methods from a reused resource, so they can be
+// configured from scratch. Each removed method is restored on rollback.
+func (builder *GatewayBuilder) clearMethods(resource *apigateway.Resource) error {
+	methods, err := builder.getMethods(resource)
+	if err != nil {
+		return err
+	}
+	for _, method := range methods {
+		_, err := builder.Clients.APIGateway.DeleteMethod(&apigateway.DeleteMethodInput{
+			HttpMethod: method.HttpMethod,
+			ResourceId: resource.Id,
+			RestApiId:  builder.APIGateway.Id,
+		})
+		if err != nil {
+			return err
+		}
+		builder.undoMethodRemoval(resource.Id, method)
+	}
+	resource.ResourceMethods = nil
 	return nil
 }
 
 // ConfigureResources configures the Resource in the GatewayBuilder to be set up
-// for receiving POST messages and translate them into simple JSON messages
+// for receiving messages for each of the requested HTTP methods and translate
+// them into simple JSON messages
 func (builder *GatewayBuilder) ConfigureResources() error {
-	svc := apigateway.New(session.New(), &aws.Config{Region: builder.Settings.Region})
+	if aws.BoolValue(builder.Settings.Proxy) {
+		return builder.configureProxyResources()
+	}
+	methods, err := builder.Settings.Methods()
+	if err != nil {
+		return err
+	}
+	if err = builder.clearMethods(builder.Resource); err != nil {
+		return err
+	}
+	for _, method := range methods {
+		if err = builder.configureMethod(method); err != nil {
+			return err
+		}
+	}
+	if builder.Settings.CORSEnabled() {
+		return builder.configureCORS(builder.Resource)
+	}
+	return nil
+}
+
+// configureMethod sets up the method, integration, integration response and
+// method response for a single HTTP method
+func (builder *GatewayBuilder) configureMethod(method string) error {
+	svc := builder.Clients.APIGateway
 
-	uriString := fmt.Sprintf("arn:aws:apigateway:%s:lambda:path/2015-03-31/functions/%s/invocations",
-		aws.StringValue(builder.Settings.Region),
-		aws.StringValue(builder.Lambda.FunctionArn))
+	uriString := builder.integrationURI()
 
 	methodParams := &apigateway.PutMethodInput{
-		AuthorizationType: builder.Settings.Authentication,
-		HttpMethod:        builder.Settings.HTTPMethod,
-		ResourceId:        builder.Resource.Id,
-		RestApiId:         builder.APIGateway.Id,
-		ApiKeyRequired:    builder.Settings.ApikeyRequired,
+		AuthorizationType:   builder.authorizationType(),
+		AuthorizerId:        builder.authorizerID(),
+		AuthorizationScopes: builder.authorizationScopes(),
+		HttpMethod:          aws.String(method),
+		ResourceId:          builder.Resource.Id,
+		RestApiId:           builder.APIGateway.Id,
+		ApiKeyRequired:      builder.Settings.ApikeyRequired,
 	}
 	_, err := svc.PutMethod(methodParams)
 
@@ -82,15 +249,14 @@ func (builder *GatewayBuilder) ConfigureResources() error {
 	}
 
 	params := &apigateway.PutIntegrationInput{
-		HttpMethod: builder.Settings.HTTPMethod,
+		HttpMethod: aws.String(method),
 		ResourceId: builder.Resource.Id,
 		RestApiId:  builder.APIGateway.Id,
 		Type:       aws.String("AWS"),
-		IntegrationHttpMethod: builder.Settings.HTTPMethod,
-		RequestTemplates: map[string]*string{
-			"application/x-www-form-urlencoded": aws.String(`{"body": $input.json("$")}`),
-		},
-		Uri: aws.String(uriString),
+		// Lambda functions can only be invoked using POST
+		IntegrationHttpMethod: aws.String("POST"),
+		RequestTemplates:      requestTemplates(method),
+		Uri:                   aws.String(uriString),
 	}
 	_, err = svc.PutIntegration(params)
 
@@ -98,40 +264,138 @@ func (builder *GatewayBuilder) ConfigureResources() error {
 		return err
 	}
 
+	// The headers have to be declared in the method response before the
+	// integration response can map them
+	methodResponsParams := &apigateway.PutMethodResponseInput{
+		HttpMethod:     aws.String(method),
+		ResourceId:     builder.Resource.Id,
+		RestApiId:      builder.APIGateway.Id,
+		StatusCode:     aws.String("200"),
+		ResponseModels: map[string]*string{},
+	}
 	integrationResponseParams := &apigateway.PutIntegrationResponseInput{
-		HttpMethod:       builder.Settings.HTTPMethod,
+		HttpMethod:       aws.String(method),
 		ResourceId:       builder.Resource.Id,
 		RestApiId:        builder.APIGateway.Id,
 		StatusCode:       aws.String("200"),
 		SelectionPattern: aws.String(".*"),
 	}
-	_, err = svc.PutIntegrationResponse(integrationResponseParams)
+	if builder.Settings.CORSEnabled() {
+		headers, err := builder.corsResponseHeaders(false)
+		if err != nil {
+			return err
+		}
+		methodResponsParams.ResponseParameters, integrationResponseParams.ResponseParameters = corsMappings(headers)
+		integrationResponseParams.ResponseTemplates = builder.corsOriginTemplate(`$input.json("$")`)
+	}
+
+	_, err = svc.PutMethodResponse(methodResponsParams)
 
 	if err != nil {
 		return err
 	}
 
-	methodResponsParams := &apigateway.PutMethodResponseInput{
-		HttpMethod:     builder.Settings.HTTPMethod,
-		ResourceId:     builder.Resource.Id,
-		RestApiId:      builder.APIGateway.Id,
-		StatusCode:     aws.String("200"),
-		ResponseModels: map[string]*string{},
-	}
-	_, err = svc.PutMethodResponse(methodResponsParams)
+	_, err = svc.PutIntegrationResponse(integrationResponseParams)
 
 	return err
 }
 
-// DeployAPI deploys the API attached to the GatewayBuilder
+// configureProxyResources sets up the ANY method with a Lambda proxy
+// integration on both the Resource and the ProxyResource. The function
+// receives the full request and is responsible for the entire response,
+// except for the answers to CORS preflight requests.
+func (builder *GatewayBuilder) configureProxyResources() error {
+	svc := builder.Clients.APIGateway
+
+	uriString := builder.integrationURI()
+
+	for _, resource := range []*apigateway.Resource{builder.Resource, builder.ProxyResource} {
+		if err := builder.clearMethods(resource); err != nil {
+			return err
+		}
+		methodParams := &apigateway.PutMethodInput{
+			AuthorizationType:   builder.authorizationType(),
+			AuthorizerId:        builder.authorizerID(),
+			AuthorizationScopes: builder.authorizationScopes(),
+			HttpMethod:          aws.String("ANY"),
+			ResourceId:          resource.Id,
+			RestApiId:           builder.APIGateway.Id,
+			ApiKeyRequired:      builder.Settings.ApikeyRequired,
+		}
+		_, err := svc.PutMethod(methodParams)
+
+		if err != nil {
+			return err
+		}
+
+		params := &apigateway.PutIntegrationInput{
+			HttpMethod:            aws.String("ANY"),
+			ResourceId:            resource.Id,
+			RestApiId:             builder.APIGateway.Id,
+			Type:                  aws.String("AWS_PROXY"),
+			IntegrationHttpMethod: aws.String("POST"),
+			Uri:                   aws.String(uriString),
+		}
+		_, err = svc.PutIntegration(params)
+
+		if err != nil {
+			return err
+		}
+
+		// The function has to add the CORS headers to its own responses
+		if builder.Settings.CORSEnabled() {
+			if err = builder.configureCORS(resource); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// queryTemplate maps all query string parameters into a JSON object
+const queryTemplate = `{"query": {
+#foreach($key in $input.params().querystring.keySet())
+  "$key": "$util.escapeJavaScript($input.params().querystring.get($key))"#if($foreach.hasNext),#end
+#end
+}}`
+
+// requestTemplates returns the request templates for the HTTP method. GET
+// requests don't have a body, so their query string parameters are passed on
+// instead of the form body.
+func requestTemplates(method string) map[string]*string {
+	if method == "GET" {
+		return map[string]*string{
+			"application/json": aws.String(queryTemplate),
+		}
+	}
+	return map[string]*string{
+		"application/x-www-form-urlencoded": aws.String(`{"body": $input.json("$")}`),
+	}
+}
+
+// DeployAPI deploys the API attached to the GatewayBuilder to its stage. When
+// an alias is used, the stage invokes that alias.
 func (builder *GatewayBuilder) DeployAPI() error {
-	svc := apigateway.New(session.New(), &aws.Config{Region: builder.Settings.Region})
+	svc := builder.Clients.APIGateway
 
+	previous, err := svc.GetStage(&apigateway.GetStageInput{
+		RestApiId: builder.APIGateway.Id,
+		StageName: aws.String(builder.StageName()),
+	})
+	if err != nil && !isNotFound(err) {
+		return err
+	}
 	params := &apigateway.CreateDeploymentInput{
 		RestApiId: builder.APIGateway.Id,
-		StageName: aws.String("prod"),
+		StageName: aws.String(builder.StageName()),
 	}
-	_, err := svc.CreateDeployment(params)
-
-	return err
+	if alias := aws.StringValue(builder.Settings.Alias); alias != "" {
+		params.Variables = map[string]*string{aliasVariable: aws.String(alias)}
+	}
+	if _, err = svc.CreateDeployment(params); err != nil {
+		return err
+	}
+	builder.undoDeployment(previous)
+	return nil
 }
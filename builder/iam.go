@@ -1,14 +1,17 @@
 package builder
 
 import (
+	"errors"
+	"io/ioutil"
+	"strings"
+
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/iam"
 )
 
 // GetRole retrieves the IAM role with the provided name
-func GetRole(name *string) (*iam.GetRoleOutput, error) {
-	svc := iam.New(session.New())
+func GetRole(clients *Clients, name *string) (*iam.GetRoleOutput, error) {
+	svc := clients.IAM
 
 	params := &iam.GetRoleInput{
 		RoleName: name,
@@ -17,17 +20,18 @@ func GetRole(name *string) (*iam.GetRoleOutput, error) {
 }
 
 // GetRoles returns all the roles the caller has access to
-func GetRoles() (*iam.ListRolesOutput, error) {
-	svc := iam.New(session.New())
-
-	params := &iam.ListRolesInput{}
-
-	return svc.ListRoles(params)
+func GetRoles(clients *Clients) ([]*iam.Role, error) {
+	var roles []*iam.Role
+	err := EachRole(clients, ListOptions{}, func(role *iam.Role) bool {
+		roles = append(roles, role)
+		return true
+	})
+	return roles, err
 }
 
 // CreateIAMRole creates an IAM Role based on the provided template
-func CreateIAMRole(roleTemplate string, roleName *string) error {
-	svc := iam.New(session.New())
+func CreateIAMRole(clients *Clients, roleTemplate string, roleName *string) error {
+	svc := clients.IAM
 
 	params := &iam.CreateRoleInput{
 		AssumeRolePolicyDocument: aws.String(TrustDocument),
@@ -39,15 +43,41 @@ func CreateIAMRole(roleTemplate string, roleName *string) error {
 		return err
 	}
 
+	return PutRolePolicy(clients, roleTemplate, roleName)
+}
+
+// rolePolicyName is the name of the inline policy Aqua adds to its roles
+const rolePolicyName = "policyNameType"
+
+// PutRolePolicy sets the inline policy of the IAM Role to the provided template
+func PutRolePolicy(clients *Clients, roleTemplate string, roleName *string) error {
 	putParams := &iam.PutRolePolicyInput{
 		PolicyDocument: aws.String(roleTemplate),
-		PolicyName:     aws.String("policyNameType"),
+		PolicyName:     aws.String(rolePolicyName),
 		RoleName:       roleName,
 	}
-	_, err = svc.PutRolePolicy(putParams)
+	_, err := clients.IAM.PutRolePolicy(putParams)
 
-	if err != nil {
-		return err
+	return err
+}
+
+// RoleTemplate returns the policy for the provided role type. For custom
+// roles the policy is read from the provided file.
+func RoleTemplate(roleType string, filename string) (string, error) {
+	switch strings.ToLower(roleType) {
+	case "basic":
+		return BasicRole, nil
+	case "s3":
+		return S3Role, nil
+	case "aqua":
+		return AquaRole, nil
+	case "custom":
+		roleContents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", err
+		}
+		return string(roleContents), nil
+	default:
+		return "", errors.New("I'm sorry, but I can't create that role for you.")
 	}
-	return nil
 }
@@ -4,70 +4,105 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"net/http"
-	"os"
+	"reflect"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/apigateway"
 	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
 	"github.com/aws/aws-sdk-go/service/lambda"
 )
 
-var lambdases *lambda.Lambda
-
-func lambdaSession(settings *Config) *lambda.Lambda {
-	if lambdases == nil {
-		lambdases = lambda.New(session.New(), &aws.Config{Region: settings.Region})
+// AddPermissions adds test and stage permissions for the Gateway to the
+// Lambda function, or its alias, for each of the configured HTTP methods
+func (builder *GatewayBuilder) AddPermissions() error {
+	methods, err := builder.Settings.Methods()
+	if err != nil {
+		return err
+	}
+	path := aws.StringValue(builder.Settings.FunctionName)
+	for _, method := range methods {
+		if err = builder.addMethodPermissions(builder.Resource, path, method); err != nil {
+			return err
+		}
+		if builder.ProxyResource != nil {
+			if err = builder.addMethodPermissions(builder.ProxyResource, path+"/*", method); err != nil {
+				return err
+			}
+		}
 	}
-	return lambdases
+	return nil
 }
 
-// AddPermissions adds test and production permissions for the Gateway to the Lambda function
-func (builder *GatewayBuilder) AddPermissions() error {
-	svc := lambdaSession(builder.Settings)
+// addMethodPermissions allows the Gateway to invoke the Lambda function for
+// the method on the resource, which is found at the provided path
+func (builder *GatewayBuilder) addMethodPermissions(resource *apigateway.Resource, path string, method string) error {
+	// The ANY method is matched by any HTTP verb in the source ARN
+	arnMethod := method
+	if method == "ANY" {
+		arnMethod = "*"
+	}
+
+	// With an alias, the permissions are needed on the alias that is invoked
+	var qualifier *string
+	if aws.StringValue(builder.Settings.Alias) != "" {
+		qualifier = builder.Settings.Alias
+	}
 
 	params := &lambda.AddPermissionInput{
 		Action:       aws.String("lambda:InvokeFunction"),
 		FunctionName: builder.Settings.FunctionName,
+		Qualifier:    qualifier,
 		Principal:    aws.String("apigateway.amazonaws.com"),
-		StatementId:  aws.String(fmt.Sprintf("apigateway-%s-test", aws.StringValue(builder.Resource.Id))),
+		StatementId: aws.String(fmt.Sprintf("apigateway-%s-%s-test",
+			aws.StringValue(resource.Id), strings.ToLower(method))),
 		SourceArn: aws.String(fmt.Sprintf("%s/*/%s/%s",
 			builder.APIARN(),
-			aws.StringValue(builder.Settings.HTTPMethod),
-			aws.StringValue(builder.Settings.FunctionName))),
+			arnMethod,
+			path)),
 	}
-	_, err := svc.AddPermission(params)
+	err := builder.addPermission(params)
 
 	if err != nil {
 		return err
 	}
 
-	params.SourceArn = aws.String(fmt.Sprintf("%s/prod/%s/%s",
+	params.SourceArn = aws.String(fmt.Sprintf("%s/%s/%s/%s",
 		builder.APIARN(),
-		aws.StringValue(builder.Settings.HTTPMethod),
-		aws.StringValue(builder.Settings.FunctionName)))
-	params.StatementId = aws.String(fmt.Sprintf("apigateway-%s-prod",
-		aws.StringValue(builder.Resource.Id)))
+		builder.StageName(),
+		arnMethod,
+		path))
+	params.StatementId = aws.String(fmt.Sprintf("apigateway-%s-%s-%s",
+		aws.StringValue(resource.Id), strings.ToLower(method), builder.StageName()))
 
-	_, err = svc.AddPermission(params)
+	return builder.addPermission(params)
+}
+
+// addPermission adds the permission to the Lambda function. If a permission
+// with the same statement ID already exists, it was added by an earlier run
+// and is left alone.
+func (builder *GatewayBuilder) addPermission(params *lambda.AddPermissionInput) error {
+	_, err := builder.Clients.Lambda.AddPermission(params)
 
 	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "ResourceConflictException" {
+			return nil
+		}
 		return err
 	}
+	builder.undoPermission(params.FunctionName, params.StatementId, params.Qualifier)
 
-	return err
+	return nil
 }
 
-// EnsureLambdaFunction retrieves an existing Lambda function or creates a new one
+// EnsureLambdaFunction retrieves an existing Lambda function or creates a new
+// one. If the settings ask for it, an existing function is updated.
 func (builder *GatewayBuilder) EnsureLambdaFunction() error {
-	svc := lambdaSession(builder.Settings)
+	svc := builder.Clients.Lambda
 	searchParams := &lambda.GetFunctionConfigurationInput{
 		FunctionName: builder.Settings.FunctionName,
 	}
@@ -77,9 +112,10 @@ func (builder *GatewayBuilder) EnsureLambdaFunction() error {
 		if awsErr, ok := err.(awserr.Error); ok {
 			// If it didn't find the function, we can create it
 			if awsErr.Code() == "ResourceNotFoundException" {
-				lambda, err = createLambdaFunction(builder.Settings)
-				if err == nil {
+				lambda, err = builder.createLambdaFunction()
+				if lambda != nil {
 					builder.Lambda = lambda
+					builder.undoLambdaFunction(lambda)
 				}
 				return err
 			}
@@ -87,94 +123,431 @@ func (builder *GatewayBuilder) EnsureLambdaFunction() error {
 		return err
 	}
 	builder.Lambda = lambda
-	return nil
+	if !aws.BoolValue(builder.Settings.Update) {
+		return nil
+	}
+	builder.FunctionUpdate, err = builder.updateLambdaFunction(lambda)
+	if err == nil {
+		builder.Lambda = builder.FunctionUpdate.After
+	}
+	return err
 }
 
-func createLambdaFunction(settings *Config) (*lambda.FunctionConfiguration, error) {
+func (builder *GatewayBuilder) createLambdaFunction() (*lambda.FunctionConfiguration, error) {
+	clients := builder.Clients
+	settings := builder.Settings
 	if aws.StringValue(settings.RoleName) == "" {
 		return nil, errors.New("When creating a Lambda function you have to provide a Role for it using the --role flag")
 	}
-	role, err := GetRole(settings.RoleName)
+	if err := deprecatedRuntime(aws.StringValue(settings.Runtime)); err != nil {
+		return nil, err
+	}
+	role, err := GetRole(clients, settings.RoleName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := builder.functionCode()
+	if err != nil {
+		return nil, err
+	}
+	if err = builder.stageCode(code); err != nil {
+		return nil, err
+	}
 
+	svc := clients.Lambda
+
+	params := &lambda.CreateFunctionInput{
+		Code:         code.functionCode(),
+		FunctionName: settings.FunctionName,
+		Handler:      aws.String(DefaultHandler),
+		Role:         role.Role.Arn,
+		Runtime:      aws.String(DefaultRuntime),
+		MemorySize:   settings.MemorySize,
+		Timeout:      settings.Timeout,
+	}
+	if aws.StringValue(settings.Handler) != "" {
+		params.Handler = settings.Handler
+	}
+	if aws.StringValue(settings.Runtime) != "" {
+		params.Runtime = settings.Runtime
+	}
+	if aws.StringValue(settings.Description) != "" {
+		params.Description = settings.Description
+	}
+	if aws.StringValue(settings.Architecture) != "" {
+		params.Architectures = []*string{settings.Architecture}
+	}
+	if aws.StringValue(settings.TracingMode) != "" {
+		params.TracingConfig = &lambda.TracingConfig{Mode: settings.TracingMode}
+	}
+	if aws.StringValue(settings.DeadLetterARN) != "" {
+		params.DeadLetterConfig = &lambda.DeadLetterConfig{TargetArn: settings.DeadLetterARN}
+	}
+	variables, err := settings.EnvironmentVariables()
+	if err != nil {
+		return nil, err
+	}
+	if variables != nil {
+		params.Environment = &lambda.Environment{Variables: variables}
+	}
+	params.Tags, err = settings.TagMap()
 	if err != nil {
 		return nil, err
 	}
+	function, err := svc.CreateFunction(params)
+	// A role that was just created can take a few seconds before Lambda is
+	// able to assume it
+	for attempt := 1; attempt < roleAttempts && rolePropagating(err); attempt++ {
+		time.Sleep(roleRetryDelay)
+		function, err = svc.CreateFunction(params)
+	}
 
-	var functionData []byte
+	if err != nil {
+		return nil, err
+	}
 
-	if aws.StringValue(settings.FilePath) == "" {
-		functionData, err = base64.StdEncoding.DecodeString(Helloworld64)
+	if concurrency := aws.Int64Value(settings.Concurrency); settings.Concurrency != nil && concurrency >= 0 {
+		_, err = svc.PutFunctionConcurrency(&lambda.PutFunctionConcurrencyInput{
+			FunctionName:                 function.FunctionName,
+			ReservedConcurrentExecutions: settings.Concurrency,
+		})
 		if err != nil {
-			return nil, err
+			// Return the function as well, so it can be rolled back
+			return function, err
 		}
-	} else {
-		if settings.IsWebPath() {
-			settings.FilePath, err = downloadFile(aws.StringValue(settings.FilePath))
-			if err != nil {
-				return nil, err
-			}
-		}
-		functionData, err = ioutil.ReadFile(aws.StringValue(settings.FilePath))
+	}
+
+	return function, nil
+}
+
+// roleAttempts is the number of times a function is created while its role
+// is refused, with roleRetryDelay in between
+const roleAttempts = 10
+
+var roleRetryDelay = 3 * time.Second
+
+// rolePropagating checks if Lambda refused the role of the function because
+// IAM hasn't finished propagating it yet
+func rolePropagating(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == "InvalidParameterValueException" &&
+		strings.Contains(awsErr.Message(), "role")
+}
+
+// functionCode returns the deployment package built from the source
+// directory or the zip file in the settings, or the sample code if neither
+// was provided, and attaches it to the GatewayBuilder. Large packages still
+// have to be staged with stageCode before Lambda can use them.
+func (builder *GatewayBuilder) functionCode() (*Package, error) {
+	settings := builder.Settings
+	if settings.IsS3Path() {
+		location, err := ParseS3Path(aws.StringValue(settings.FilePath))
 		if err != nil {
 			return nil, err
 		}
+		builder.Package = &Package{Location: location}
+		return builder.Package, nil
 	}
 
-	svc := lambdaSession(settings)
+	pkg, err := loadPackage(settings)
+	if err != nil {
+		return nil, err
+	}
+	builder.Package = pkg
+	return builder.Package, nil
+}
 
-	params := &lambda.CreateFunctionInput{
-		Code: &lambda.FunctionCode{
-			ZipFile: functionData,
-		},
-		FunctionName: settings.FunctionName,
-		Handler:      aws.String("index.handler"),
-		Role:         role.Role.Arn,
-		Runtime:      settings.Runtime,
+// stageCode uploads a package that is too large to send to Lambda directly
+// to the upload bucket in the settings
+func (builder *GatewayBuilder) stageCode(pkg *Package) error {
+	settings := builder.Settings
+	if pkg.Location != nil || len(pkg.Data) <= MaxZippedSize {
+		return nil
+	}
+	if aws.StringValue(settings.UploadBucket) == "" {
+		return fmt.Errorf("The package is %d bytes, which is larger than the %d MB Lambda allows for direct uploads. Please use --upload-bucket to upload it through S3",
+			len(pkg.Data), MaxZippedSize/1024/1024)
 	}
-	lambda, err := svc.CreateFunction(params)
+	return stagePackage(builder.Clients, aws.StringValue(settings.UploadBucket), settings.CleanName(), pkg)
+}
 
+// loadPackage reads, downloads, or builds the deployment package in the
+// settings, without uploading it anywhere
+func loadPackage(settings *Config) (*Package, error) {
+	var pkg *Package
+	var data []byte
+	var err error
+	switch {
+	case aws.StringValue(settings.Source) != "":
+		pkg, err = BuildPackage(aws.StringValue(settings.Source))
+	case aws.StringValue(settings.FilePath) == "":
+		data, err = base64.StdEncoding.DecodeString(Helloworld64)
+	default:
+		if settings.IsWebPath() {
+			data, err = downloadFile(aws.StringValue(settings.FilePath))
+		} else {
+			data, err = ioutil.ReadFile(aws.StringValue(settings.FilePath))
+		}
+		if err == nil && aws.StringValue(settings.SHA256) != "" {
+			err = VerifySHA256(data, aws.StringValue(settings.SHA256))
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	if pkg == nil {
+		pkg = NewPackage(data)
+	}
+	return pkg, nil
+}
 
-	return lambda, nil
+// codeChanged checks if the code in the settings is different from that of
+// the function. Packages in S3 don't have a checksum, so they always count as
+// changed. Without code in the settings, the code is left alone.
+func (builder *GatewayBuilder) codeChanged(current *lambda.FunctionConfiguration) (bool, error) {
+	settings := builder.Settings
+	if !settings.hasCode() {
+		return false, nil
+	}
+	if settings.IsS3Path() {
+		return true, nil
+	}
+	pkg, err := loadPackage(settings)
+	if err != nil {
+		return false, err
+	}
+	return pkg.SHA256 != aws.StringValue(current.CodeSha256), nil
 }
 
-func downloadFile(rawURL string) (*string, error) {
-	fileName := os.TempDir() + strconv.FormatInt(time.Now().Unix(), 10) + "aqua.zip"
-	file, err := os.Create(fileName)
+// FunctionUpdate contains the configuration of a Lambda function from before
+// and after it was updated
+type FunctionUpdate struct {
+	Before *lambda.FunctionConfiguration
+	After  *lambda.FunctionConfiguration
+}
+
+// updateLambdaFunction uploads the code from the settings to the existing
+// function if it is different, and updates any of its configuration that was
+// provided and is different. Settings that weren't provided are left alone.
+func (builder *GatewayBuilder) updateLambdaFunction(current *lambda.FunctionConfiguration) (*FunctionUpdate, error) {
+	clients := builder.Clients
+	settings := builder.Settings
+	svc := clients.Lambda
+	update := &FunctionUpdate{Before: current, After: current}
+	waitParams := &lambda.GetFunctionConfigurationInput{
+		FunctionName: settings.FunctionName,
+	}
 
+	architectureChanged := builder.architectureChanged(current)
+	if architectureChanged && !settings.hasCode() {
+		return nil, fmt.Errorf("The architecture of function %s can only be changed together with its code, please provide it with --file or --source",
+			aws.StringValue(settings.FunctionName))
+	}
+	if settings.hasCode() {
+		code, err := builder.functionCode()
+		if err != nil {
+			return nil, err
+		}
+		// Packages in S3 don't have a checksum, so they are always uploaded.
+		// Others are only staged in the upload bucket if they changed.
+		if code.SHA256 == "" || code.SHA256 != aws.StringValue(current.CodeSha256) || architectureChanged {
+			if err = builder.stageCode(code); err != nil {
+				return nil, err
+			}
+			location := code.functionCode()
+			params := &lambda.UpdateFunctionCodeInput{
+				FunctionName:    settings.FunctionName,
+				ZipFile:         location.ZipFile,
+				S3Bucket:        location.S3Bucket,
+				S3Key:           location.S3Key,
+				S3ObjectVersion: location.S3ObjectVersion,
+			}
+			if architectureChanged {
+				params.Architectures = []*string{settings.Architecture}
+			}
+			update.After, err = svc.UpdateFunctionCode(params)
+			if err != nil {
+				return nil, err
+			}
+			// The configuration can only be changed once the code update is done
+			err = svc.WaitUntilFunctionUpdated(waitParams)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	params, changed, err := builder.configurationUpdate(current)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	check := http.Client{
-		CheckRedirect: func(r *http.Request, via []*http.Request) error {
-			r.URL.Opaque = r.URL.Path
-			return nil
-		},
+	if changed {
+		update.After, err = svc.UpdateFunctionConfiguration(params)
+		if err != nil {
+			return nil, err
+		}
+		if err = svc.WaitUntilFunctionUpdated(waitParams); err != nil {
+			return nil, err
+		}
 	}
 
-	resp, err := check.Get(rawURL)
+	tags, err := builder.tagChanges(current)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) > 0 {
+		_, err = svc.TagResource(&lambda.TagResourceInput{
+			Resource: current.FunctionArn,
+			Tags:     tags,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
+	changed, err = builder.concurrencyChanged()
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	if changed {
+		_, err = svc.PutFunctionConcurrency(&lambda.PutFunctionConcurrencyInput{
+			FunctionName:                 settings.FunctionName,
+			ReservedConcurrentExecutions: settings.Concurrency,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return update, nil
+}
 
-	_, err = io.Copy(file, resp.Body)
+// architectureChanged checks if an architecture was provided that the
+// function doesn't have
+func (builder *GatewayBuilder) architectureChanged(current *lambda.FunctionConfiguration) bool {
+	architecture := aws.StringValue(builder.Settings.Architecture)
+	if architecture == "" {
+		return false
+	}
+	// Functions without a listed architecture run on x86_64
+	currentArchitecture := "x86_64"
+	if len(current.Architectures) > 0 {
+		currentArchitecture = aws.StringValue(current.Architectures[0])
+	}
+	return architecture != currentArchitecture
+}
 
+// tagChanges returns the provided tags that the function doesn't have yet,
+// or has with a different value. Other tags of the function are left alone.
+func (builder *GatewayBuilder) tagChanges(current *lambda.FunctionConfiguration) (map[string]*string, error) {
+	wanted, err := builder.Settings.TagMap()
+	if err != nil || len(wanted) == 0 {
+		return nil, err
+	}
+	resp, err := builder.Clients.Lambda.ListTags(&lambda.ListTagsInput{
+		Resource: current.FunctionArn,
+	})
 	if err != nil {
 		return nil, err
 	}
+	changes := make(map[string]*string)
+	for key, value := range wanted {
+		if existing, ok := resp.Tags[key]; !ok || aws.StringValue(existing) != aws.StringValue(value) {
+			changes[key] = value
+		}
+	}
+	return changes, nil
+}
 
-	return &fileName, nil
+// concurrencyChanged checks if a reserved concurrency was provided that the
+// function doesn't have
+func (builder *GatewayBuilder) concurrencyChanged() (bool, error) {
+	settings := builder.Settings
+	if settings.Concurrency == nil || aws.Int64Value(settings.Concurrency) < 0 {
+		return false, nil
+	}
+	resp, err := builder.Clients.Lambda.GetFunctionConcurrency(&lambda.GetFunctionConcurrencyInput{
+		FunctionName: settings.FunctionName,
+	})
+	if err != nil {
+		return false, err
+	}
+	return resp.ReservedConcurrentExecutions == nil ||
+		aws.Int64Value(resp.ReservedConcurrentExecutions) != aws.Int64Value(settings.Concurrency), nil
+}
+
+// configurationUpdate returns the changes needed to make the configuration of
+// the function match the settings, and whether there are any. Settings that
+// weren't provided are left alone.
+func (builder *GatewayBuilder) configurationUpdate(current *lambda.FunctionConfiguration) (*lambda.UpdateFunctionConfigurationInput, bool, error) {
+	settings := builder.Settings
+	params := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: settings.FunctionName,
+	}
+	changed := false
+	if aws.StringValue(settings.RoleName) != "" {
+		role, err := GetRole(builder.Clients, settings.RoleName)
+		if err != nil {
+			return nil, false, err
+		}
+		if aws.StringValue(role.Role.Arn) != aws.StringValue(current.Role) {
+			params.Role = role.Role.Arn
+			changed = true
+		}
+	}
+	if aws.StringValue(settings.Runtime) != "" && aws.StringValue(settings.Runtime) != aws.StringValue(current.Runtime) {
+		params.Runtime = settings.Runtime
+		changed = true
+	}
+	if aws.StringValue(settings.Handler) != "" && aws.StringValue(settings.Handler) != aws.StringValue(current.Handler) {
+		params.Handler = settings.Handler
+		changed = true
+	}
+	if aws.Int64Value(settings.MemorySize) != 0 && aws.Int64Value(settings.MemorySize) != aws.Int64Value(current.MemorySize) {
+		params.MemorySize = settings.MemorySize
+		changed = true
+	}
+	if aws.Int64Value(settings.Timeout) != 0 && aws.Int64Value(settings.Timeout) != aws.Int64Value(current.Timeout) {
+		params.Timeout = settings.Timeout
+		changed = true
+	}
+	if aws.StringValue(settings.Description) != "" && aws.StringValue(settings.Description) != aws.StringValue(current.Description) {
+		params.Description = settings.Description
+		changed = true
+	}
+	if mode := aws.StringValue(settings.TracingMode); mode != "" && (current.TracingConfig == nil || mode != aws.StringValue(current.TracingConfig.Mode)) {
+		params.TracingConfig = &lambda.TracingConfig{Mode: settings.TracingMode}
+		changed = true
+	}
+	if target := aws.StringValue(settings.DeadLetterARN); target != "" && (current.DeadLetterConfig == nil || target != aws.StringValue(current.DeadLetterConfig.TargetArn)) {
+		params.DeadLetterConfig = &lambda.DeadLetterConfig{TargetArn: settings.DeadLetterARN}
+		changed = true
+	}
+	// The provided environment variables replace all existing ones
+	variables, err := settings.EnvironmentVariables()
+	if err != nil {
+		return nil, false, err
+	}
+	if variables != nil {
+		var existing map[string]*string
+		if current.Environment != nil {
+			existing = current.Environment.Variables
+		}
+		if !reflect.DeepEqual(aws.StringValueMap(variables), aws.StringValueMap(existing)) {
+			params.Environment = &lambda.Environment{Variables: variables}
+			changed = true
+		}
+	}
+	return params, changed, nil
 }
 
 // CreateSchedule creates a schedule for a Lambda function
-func CreateSchedule(settings *Config, schedule string) error {
-	svc := lambdaSession(settings)
+func CreateSchedule(clients *Clients, settings *Config, schedule string) error {
+	if err := ValidateSchedule(schedule); err != nil {
+		return err
+	}
+	svc := clients.Lambda
 
 	// Check that function exists
 	searchParams := &lambda.GetFunctionConfigurationInput{
@@ -186,12 +559,12 @@ func CreateSchedule(settings *Config, schedule string) error {
 		return err
 	}
 
-	eventssvc := cloudwatchevents.New(session.New(), &aws.Config{Region: settings.Region})
+	eventssvc := clients.Events
 
-	cleanedName := cleanName(schedule)
+	ruleName := scheduleRuleName(settings, schedule)
 
 	putruleparams := &cloudwatchevents.PutRuleInput{
-		Name:               aws.String(cleanedName),
+		Name:               aws.String(ruleName),
 		ScheduleExpression: aws.String(schedule),
 	}
 
@@ -207,17 +580,33 @@ func CreateSchedule(settings *Config, schedule string) error {
 		StatementId:  aws.String(fmt.Sprintf("scheduler-%s", *settings.FunctionName)),
 		SourceArn:    ruleOutput.RuleArn,
 	}
+	var previousRule string
 	_, err = svc.AddPermission(params)
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "ResourceConflictException" {
+		// Replace the permission for an earlier schedule of the function
+		previousRule, err = scheduleRuleARN(clients, settings.FunctionName, aws.StringValue(params.StatementId))
+		if err != nil {
+			return err
+		}
+		_, err = svc.RemovePermission(&lambda.RemovePermissionInput{
+			FunctionName: settings.FunctionName,
+			StatementId:  params.StatementId,
+		})
+		if err == nil {
+			_, err = svc.AddPermission(params)
+		}
+	}
 	if err != nil {
 		return err
 	}
 
+	// Each function has its own target, so functions can share a rule
 	puttargetparams := &cloudwatchevents.PutTargetsInput{
-		Rule: aws.String(cleanedName),
+		Rule: aws.String(ruleName),
 		Targets: []*cloudwatchevents.Target{
 			{
 				Arn: lambdaInst.FunctionArn,
-				Id:  aws.String("1"),
+				Id:  settings.FunctionName,
 			},
 		},
 	}
@@ -226,9 +615,38 @@ func CreateSchedule(settings *Config, schedule string) error {
 	if err != nil {
 		return err
 	}
+	// The earlier schedule is no longer allowed to invoke the function
+	if previousRule != "" && previousRule != aws.StringValue(ruleOutput.RuleArn) {
+		return scheduleTeardown(clients, lambdaInst, previousRule).remove()
+	}
 	return nil
 }
 
+// scheduleRuleName returns the name of the rule that runs the function on the
+// schedule. Rule names can't be longer than 64 characters.
+func scheduleRuleName(settings *Config, schedule string) string {
+	name := fmt.Sprintf("%s-%s", settings.CleanName(), cleanName(schedule))
+	if len(name) > 64 {
+		return name[:64]
+	}
+	return name
+}
+
+// scheduleRuleARN returns the ARN of the rule the statement allows to invoke
+// the function, or an empty string if there is no such statement
+func scheduleRuleARN(clients *Clients, function *string, statement string) (string, error) {
+	policy, err := functionPolicy(clients, function, "")
+	if err != nil {
+		return "", err
+	}
+	for _, existing := range policy.Statement {
+		if existing.Sid == statement {
+			return existing.Condition["ArnLike"]["AWS:SourceArn"], nil
+		}
+	}
+	return "", nil
+}
+
 func createEventARN(lambdaInst *lambda.FunctionConfiguration) string {
 	eventArn := strings.Replace(aws.StringValue(lambdaInst.FunctionArn), "lambda", "events", 1)
 	return strings.Replace(eventArn, "function:", "rule/", 1)
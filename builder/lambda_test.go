@@ -0,0 +1,201 @@
+package builder
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+func TestUpdateLambdaFunction(t *testing.T) {
+	tests := map[string]struct {
+		settings func(*Config)
+		check    func(*testing.T, *fakeAWS)
+		invalid  bool
+	}{
+		"description": {
+			settings: func(settings *Config) { settings.Description = aws.String("updated") },
+			check: func(t *testing.T, account *fakeAWS) {
+				if got := aws.StringValue(account.functions["hello"].Description); got != "updated" {
+					t.Errorf("got description %q, want updated", got)
+				}
+			},
+		},
+		"environment": {
+			settings: func(settings *Config) { settings.Environment = &[]string{"STAGE=prod"} },
+			check: func(t *testing.T, account *fakeAWS) {
+				if got := aws.StringValue(account.functions["hello"].Environment.Variables["STAGE"]); got != "prod" {
+					t.Errorf("got STAGE=%q, want prod", got)
+				}
+			},
+		},
+		"tracing": {
+			settings: func(settings *Config) { settings.TracingMode = aws.String("Active") },
+			check: func(t *testing.T, account *fakeAWS) {
+				if got := aws.StringValue(account.functions["hello"].TracingConfig.Mode); got != "Active" {
+					t.Errorf("got tracing mode %q, want Active", got)
+				}
+			},
+		},
+		"dead letter": {
+			settings: func(settings *Config) {
+				settings.DeadLetterARN = aws.String("arn:aws-us-gov:sqs:us-gov-west-1:123456789012:failed")
+			},
+			check: func(t *testing.T, account *fakeAWS) {
+				if got := aws.StringValue(account.functions["hello"].DeadLetterConfig.TargetArn); got != "arn:aws-us-gov:sqs:us-gov-west-1:123456789012:failed" {
+					t.Errorf("got dead-letter target %q", got)
+				}
+			},
+		},
+		"tags": {
+			settings: func(settings *Config) { settings.Tags = &[]string{"team=web"} },
+			check: func(t *testing.T, account *fakeAWS) {
+				arn := aws.StringValue(account.functions["hello"].FunctionArn)
+				if got := aws.StringValue(account.tags[arn]["team"]); got != "web" {
+					t.Errorf("got tag team=%q, want web", got)
+				}
+			},
+		},
+		"concurrency": {
+			settings: func(settings *Config) { settings.Concurrency = aws.Int64(3) },
+			check: func(t *testing.T, account *fakeAWS) {
+				if got := aws.Int64Value(account.concurrency["hello"]); got != 3 {
+					t.Errorf("got reserved concurrency %d, want 3", got)
+				}
+			},
+		},
+		"architecture with code": {
+			settings: func(settings *Config) {
+				settings.Architecture = aws.String("arm64")
+				settings.FilePath = aws.String("s3://bucket/function.zip")
+			},
+			check: func(t *testing.T, account *fakeAWS) {
+				if got := aws.StringValueSlice(account.functions["hello"].Architectures); len(got) != 1 || got[0] != "arm64" {
+					t.Errorf("got architectures %v, want [arm64]", got)
+				}
+			},
+		},
+		"architecture without code": {
+			settings: func(settings *Config) { settings.Architecture = aws.String("arm64") },
+			invalid:  true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.addRole("lambda-basic")
+			settings := testSettings()
+			settings.NoGateway = aws.Bool(true)
+			if err := (&GatewayBuilder{Settings: settings, Clients: clients}).Build(); err != nil {
+				t.Fatalf("creating the function failed: %s", err)
+			}
+
+			settings = testSettings()
+			settings.NoGateway = aws.Bool(true)
+			settings.Update = aws.Bool(true)
+			test.settings(settings)
+			err := (&GatewayBuilder{Settings: settings, Clients: clients}).Build()
+			if test.invalid {
+				if err == nil {
+					t.Error("the update was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("updating the function failed: %s", err)
+			}
+			test.check(t, account)
+
+			changed, err := functionChanged(&GatewayBuilder{Settings: settings, Clients: clients}, account.functions["hello"])
+			if err != nil {
+				t.Fatalf("functionChanged failed: %s", err)
+			}
+			// Packages in S3 are always uploaded again
+			if changed && !settings.IsS3Path() {
+				t.Error("the function still differs from the settings after the update")
+			}
+		})
+	}
+}
+
+func TestCreateFunctionWaitsForRole(t *testing.T) {
+	roleRetryDelay = 0
+	propagating := awserr.New("InvalidParameterValueException", "The role defined for the function cannot be assumed by Lambda.", nil)
+	tests := map[string]struct {
+		err      error
+		failures int
+		calls    int
+		created  bool
+	}{
+		"propagated":          {err: propagating, failures: 2, calls: 3, created: true},
+		"never propagated":    {err: propagating, failures: roleAttempts, calls: roleAttempts},
+		"other invalid value": {err: awserr.New("InvalidParameterValueException", "Unsupported runtime", nil), failures: 2, calls: 1},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.addRole("lambda-basic")
+			account.fail["CreateFunction"] = test.err
+			account.failures["CreateFunction"] = test.failures
+
+			builder := &GatewayBuilder{Settings: testSettings(), Clients: clients}
+			err := builder.Build()
+			if test.created && err != nil {
+				t.Fatalf("Build failed: %s", err)
+			}
+			if !test.created && err == nil {
+				t.Fatal("Build didn't fail")
+			}
+			if got := account.called("CreateFunction"); got != test.calls {
+				t.Errorf("got %d attempts to create the function, want %d", got, test.calls)
+			}
+		})
+	}
+}
+
+func TestUpdateLambdaFunctionStagesChangedCode(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "function.zip")
+	write := func(last byte) {
+		t.Helper()
+		data := make([]byte, MaxZippedSize+1)
+		copy(data, zipSignature)
+		data[len(data)-1] = last
+		if err := ioutil.WriteFile(file, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clients, account := newFakeClients()
+	account.addRole("lambda-basic")
+	build := func(update bool) {
+		t.Helper()
+		settings := testSettings()
+		settings.NoGateway = aws.Bool(true)
+		settings.Update = aws.Bool(update)
+		settings.FilePath = aws.String(file)
+		settings.UploadBucket = aws.String("uploads")
+		if err := (&GatewayBuilder{Settings: settings, Clients: clients}).Build(); err != nil {
+			t.Fatalf("Build failed: %s", err)
+		}
+	}
+
+	write(1)
+	build(false)
+	build(true)
+	if got := account.called("PutObject"); got != 1 {
+		t.Errorf("got %d uploads for unchanged code, want 1", got)
+	}
+	if got := account.called("UpdateFunctionCode"); got != 0 {
+		t.Errorf("got %d code updates for unchanged code, want 0", got)
+	}
+
+	write(2)
+	build(true)
+	if got := account.called("PutObject"); got != 2 {
+		t.Errorf("got %d uploads after changing the code, want 2", got)
+	}
+	if got := account.called("UpdateFunctionCode"); got != 1 {
+		t.Errorf("got %d code updates after changing the code, want 1", got)
+	}
+}
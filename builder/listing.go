@@ -0,0 +1,201 @@
+package builder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/iam"
+)
+
+// ListOptions limit and filter the items of list operations. Zero values
+// don't filter anything.
+type ListOptions struct {
+	// Limit is the maximum number of items
+	Limit int
+	// NamePrefix matches the start of the names of the items, or the base
+	// path of base path mappings
+	NamePrefix string
+	// PathPrefix only applies to roles
+	PathPrefix string
+	// Enabled only applies to API keys
+	Enabled *bool
+}
+
+// ParseListFilters turns filters in the format name=prefix, path=prefix, or
+// enabled=true|false into ListOptions. Only the filters in allowed are
+// accepted, as not every list supports every filter.
+func ParseListFilters(filters []string, allowed []string, limit int) (ListOptions, error) {
+	options := ListOptions{Limit: limit}
+	if limit < 0 {
+		return options, fmt.Errorf("The limit can't be negative, not %d", limit)
+	}
+	for _, filter := range filters {
+		parts := strings.SplitN(filter, "=", 2)
+		if len(parts) != 2 {
+			return options, fmt.Errorf("%s is not a valid filter, please use filter=value with one of %s", filter, strings.Join(allowed, ", "))
+		}
+		supported := false
+		for _, name := range allowed {
+			supported = supported || name == parts[0]
+		}
+		if !supported {
+			return options, fmt.Errorf("%s is not a supported filter for this list, please use one of %s", parts[0], strings.Join(allowed, ", "))
+		}
+		switch parts[0] {
+		case "name":
+			options.NamePrefix = parts[1]
+		case "path":
+			options.PathPrefix = parts[1]
+		case "enabled":
+			enabled, err := strconv.ParseBool(parts[1])
+			if err != nil {
+				return options, fmt.Errorf("%s is not a valid value for the enabled filter, please use true or false", parts[1])
+			}
+			options.Enabled = aws.Bool(enabled)
+		default:
+			return options, fmt.Errorf("%s is not a supported filter, please use name, path, or enabled", parts[0])
+		}
+	}
+	return options, nil
+}
+
+// counter returns a function that reports whether another item fits in the
+// limit, counting every item it's called for
+func (options ListOptions) counter() func() bool {
+	count := 0
+	return func() bool {
+		count++
+		return options.Limit == 0 || count <= options.Limit
+	}
+}
+
+// EachAPIKey calls fn for every API key matching the options, page by page,
+// until fn returns false
+func EachAPIKey(clients *Clients, options ListOptions, fn func(*apigateway.ApiKey) bool) error {
+	params := &apigateway.GetApiKeysInput{
+		Limit: aws.Int64(500),
+	}
+	if options.NamePrefix != "" {
+		params.NameQuery = aws.String(options.NamePrefix)
+	}
+	fits := options.counter()
+	return clients.APIGateway.GetApiKeysPages(params, func(page *apigateway.GetApiKeysOutput, lastPage bool) bool {
+		for _, key := range page.Items {
+			if !strings.HasPrefix(aws.StringValue(key.Name), options.NamePrefix) {
+				continue
+			}
+			if options.Enabled != nil && aws.BoolValue(key.Enabled) != *options.Enabled {
+				continue
+			}
+			if !fits() || !fn(key) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// EachRole calls fn for every IAM role matching the options, page by page,
+// until fn returns false
+func EachRole(clients *Clients, options ListOptions, fn func(*iam.Role) bool) error {
+	params := &iam.ListRolesInput{}
+	if options.PathPrefix != "" {
+		params.PathPrefix = aws.String(options.PathPrefix)
+	}
+	fits := options.counter()
+	return clients.IAM.ListRolesPages(params, func(page *iam.ListRolesOutput, lastPage bool) bool {
+		for _, role := range page.Roles {
+			if !strings.HasPrefix(aws.StringValue(role.RoleName), options.NamePrefix) {
+				continue
+			}
+			if !fits() || !fn(role) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// EachRestAPI calls fn for every API matching the options, page by page,
+// until fn returns false
+func EachRestAPI(clients *Clients, options ListOptions, fn func(*apigateway.RestApi) bool) error {
+	params := &apigateway.GetRestApisInput{
+		Limit: aws.Int64(500),
+	}
+	fits := options.counter()
+	return clients.APIGateway.GetRestApisPages(params, func(page *apigateway.GetRestApisOutput, lastPage bool) bool {
+		for _, api := range page.Items {
+			if !strings.HasPrefix(aws.StringValue(api.Name), options.NamePrefix) {
+				continue
+			}
+			if !fits() || !fn(api) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// EachUsagePlan calls fn for every usage plan matching the options, page by
+// page, until fn returns false
+func EachUsagePlan(clients *Clients, options ListOptions, fn func(*apigateway.UsagePlan) bool) error {
+	params := &apigateway.GetUsagePlansInput{
+		Limit: aws.Int64(500),
+	}
+	fits := options.counter()
+	return clients.APIGateway.GetUsagePlansPages(params, func(page *apigateway.GetUsagePlansOutput, lastPage bool) bool {
+		for _, plan := range page.Items {
+			if !strings.HasPrefix(aws.StringValue(plan.Name), options.NamePrefix) {
+				continue
+			}
+			if !fits() || !fn(plan) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// EachStage calls fn for every stage of the API matching the options, sorted
+// by name, until fn returns false. The stages of an API aren't paged, so they
+// are all retrieved at once.
+func EachStage(clients *Clients, apiID *string, options ListOptions, fn func(*apigateway.Stage) bool) error {
+	stages, err := ListStages(clients, apiID)
+	if err != nil {
+		return err
+	}
+	fits := options.counter()
+	for _, stage := range stages {
+		if !strings.HasPrefix(aws.StringValue(stage.StageName), options.NamePrefix) {
+			continue
+		}
+		if !fits() || !fn(stage) {
+			break
+		}
+	}
+	return nil
+}
+
+// EachBasePathMapping calls fn for every base path mapping of the custom
+// domain name matching the options, page by page, until fn returns false
+func EachBasePathMapping(clients *Clients, domain string, options ListOptions, fn func(*apigateway.BasePathMapping) bool) error {
+	params := &apigateway.GetBasePathMappingsInput{
+		DomainName: aws.String(domain),
+		Limit:      aws.Int64(500),
+	}
+	fits := options.counter()
+	return clients.APIGateway.GetBasePathMappingsPages(params, func(page *apigateway.GetBasePathMappingsOutput, lastPage bool) bool {
+		for _, mapping := range page.Items {
+			if !strings.HasPrefix(aws.StringValue(mapping.BasePath), options.NamePrefix) {
+				continue
+			}
+			if !fits() || !fn(mapping) {
+				return false
+			}
+		}
+		return true
+	})
+}
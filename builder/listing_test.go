@@ -0,0 +1,124 @@
+package builder
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+func TestParseListFilters(t *testing.T) {
+	tests := map[string]struct {
+		filters  []string
+		allowed  []string
+		limit    int
+		expected ListOptions
+		invalid  bool
+	}{
+		"nothing":           {expected: ListOptions{}},
+		"limit":             {limit: 10, expected: ListOptions{Limit: 10}},
+		"name":              {filters: []string{"name=orders"}, expected: ListOptions{NamePrefix: "orders"}},
+		"empty name":        {filters: []string{"name="}, expected: ListOptions{}},
+		"value with equals": {filters: []string{"name=a=b"}, expected: ListOptions{NamePrefix: "a=b"}},
+		"path":              {filters: []string{"path=/service/"}, expected: ListOptions{PathPrefix: "/service/"}},
+		"enabled":           {filters: []string{"enabled=false"}, expected: ListOptions{Enabled: aws.Bool(false)}},
+		"combined":          {filters: []string{"name=a", "enabled=true"}, limit: 5, expected: ListOptions{Limit: 5, NamePrefix: "a", Enabled: aws.Bool(true)}},
+		"last one wins":     {filters: []string{"name=a", "name=b"}, expected: ListOptions{NamePrefix: "b"}},
+		"negative limit":    {limit: -1, invalid: true},
+		"missing value":     {filters: []string{"name"}, invalid: true},
+		"unknown filter":    {filters: []string{"owner=me"}, invalid: true},
+		"bad enabled":       {filters: []string{"enabled=maybe"}, invalid: true},
+		"allowed":           {filters: []string{"name=a"}, allowed: []string{"name"}, expected: ListOptions{NamePrefix: "a"}},
+		"not allowed":       {filters: []string{"enabled=true"}, allowed: []string{"name"}, invalid: true},
+		"path not allowed":  {filters: []string{"name=a", "path=/service/"}, allowed: []string{"name", "enabled"}, invalid: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			allowed := test.allowed
+			if allowed == nil {
+				allowed = []string{"name", "path", "enabled"}
+			}
+			options, err := ParseListFilters(test.filters, allowed, test.limit)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", options)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(options, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, options)
+			}
+		})
+	}
+}
+
+func TestEachAPIKey(t *testing.T) {
+	tests := map[string]struct {
+		options  ListOptions
+		stopAt   int
+		expected []string
+		pages    int
+	}{
+		"all pages":     {expected: []string{"key1", "key2", "key3", "key4", "key5"}, pages: 3},
+		"limit":         {options: ListOptions{Limit: 3}, expected: []string{"key1", "key2", "key3"}, pages: 2},
+		"limit of page": {options: ListOptions{Limit: 2}, expected: []string{"key1", "key2"}, pages: 2},
+		"name prefix":   {options: ListOptions{NamePrefix: "web"}, expected: []string{"key2", "key4"}, pages: 1},
+		"enabled":       {options: ListOptions{Enabled: aws.Bool(false)}, expected: []string{"key3", "key5"}, pages: 3},
+		"stopped":       {stopAt: 1, expected: []string{"key1"}, pages: 1},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			for index := 1; index <= 5; index++ {
+				name := "app"
+				if index%2 == 0 {
+					name = "web"
+				}
+				id := fmt.Sprintf("key%d", index)
+				account.apiKeys[id] = &apigateway.ApiKey{Id: aws.String(id), Name: aws.String(name), Enabled: aws.Bool(index < 3 || index == 4)}
+			}
+
+			var found []string
+			err := EachAPIKey(clients, test.options, func(key *apigateway.ApiKey) bool {
+				found = append(found, aws.StringValue(key.Id))
+				return test.stopAt == 0 || len(found) < test.stopAt
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(found, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, found)
+			}
+			if pages := account.called("GetApiKeys"); pages != test.pages {
+				t.Errorf("expected %d pages to be requested, got %d", test.pages, pages)
+			}
+		})
+	}
+}
+
+func TestEachUsagePlan(t *testing.T) {
+	clients, account := newFakeClients()
+	for _, name := range []string{"basic", "premium", "basic-eu"} {
+		account.addUsagePlan(name)
+	}
+
+	var found []string
+	err := EachUsagePlan(clients, ListOptions{NamePrefix: "basic"}, func(plan *apigateway.UsagePlan) bool {
+		found = append(found, aws.StringValue(plan.Name))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"basic", "basic-eu"}; !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v, got %v", expected, found)
+	}
+	if pages := account.called("GetUsagePlans"); pages != 2 {
+		t.Errorf("expected 2 pages to be requested, got %d", pages)
+	}
+}
@@ -0,0 +1,217 @@
+package builder
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// The size limits Lambda has for deployment packages that are uploaded directly
+const (
+	MaxZippedSize   = 50 * 1024 * 1024
+	MaxUnzippedSize = 250 * 1024 * 1024
+)
+
+// IgnoreFile is the name of the file with patterns for files that should be
+// left out of a deployment package
+const IgnoreFile = ".aquaignore"
+
+// defaultIgnores are never part of a deployment package
+var defaultIgnores = []string{IgnoreFile, ".git/"}
+
+// packageTime is used as the modification time of every file in a
+// deployment package, so the same files always result in the same package
+var packageTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Package is a Lambda deployment package
+type Package struct {
+	Data []byte
+	// SHA256 is calculated the same way as the CodeSha256 of a Lambda function.
+	// It is empty for packages that are only available in S3.
+	SHA256 string
+	// Location is where the package can be found in S3, if it isn't sent
+	// to Lambda directly
+	Location *S3Location
+}
+
+// NewPackage wraps the contents of a zip file in a Package
+func NewPackage(data []byte) *Package {
+	hash := sha256.Sum256(data)
+	return &Package{Data: data, SHA256: base64.StdEncoding.EncodeToString(hash[:])}
+}
+
+// functionCode returns the code for creating a Lambda function from the
+// package
+func (pkg *Package) functionCode() *lambda.FunctionCode {
+	if pkg.Location == nil {
+		return &lambda.FunctionCode{ZipFile: pkg.Data}
+	}
+	code := &lambda.FunctionCode{
+		S3Bucket: aws.String(pkg.Location.Bucket),
+		S3Key:    aws.String(pkg.Location.Key),
+	}
+	if pkg.Location.Version != "" {
+		code.S3ObjectVersion = aws.String(pkg.Location.Version)
+	}
+	return code
+}
+
+// BuildPackage creates a deployment package from the files in the directory.
+// Files matching the patterns in the directory's .aquaignore file are left
+// out. Timestamps and permissions are normalised, so unchanged files result
+// in the same package, but executable files stay executable.
+func BuildPackage(dir string) (*Package, error) {
+	ignores, err := readIgnores(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err = filepath.Walk(dir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		if relative == "." {
+			return nil
+		}
+		relative = filepath.ToSlash(relative)
+		ignored, err := isIgnored(relative, info.IsDir(), ignores)
+		if err != nil {
+			return err
+		}
+		if ignored {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			files = append(files, relative)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("There are no files to package in %s", dir)
+	}
+	sort.Strings(files)
+
+	buf := new(bytes.Buffer)
+	writer := zip.NewWriter(buf)
+	var unzippedSize int64
+	for _, file := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(file))
+		// Stat follows symlinks, so linked files are packaged as regular files
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		contents, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		unzippedSize += int64(len(contents))
+		if unzippedSize > MaxUnzippedSize {
+			return nil, fmt.Errorf("The files in %s are larger than the %d MB Lambda allows", dir, MaxUnzippedSize/1024/1024)
+		}
+
+		header := &zip.FileHeader{
+			Name:     file,
+			Method:   zip.Deflate,
+			Modified: packageTime,
+		}
+		mode := os.FileMode(0644)
+		if info.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		header.SetMode(mode)
+		entry, err := writer.CreateHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = entry.Write(contents); err != nil {
+			return nil, err
+		}
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return NewPackage(buf.Bytes()), nil
+}
+
+// readIgnores returns the patterns from the directory's .aquaignore file
+// together with the default ones
+func readIgnores(dir string) ([]string, error) {
+	ignores := append([]string{}, defaultIgnores...)
+	file, err := os.Open(filepath.Join(dir, IgnoreFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ignores, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Check the pattern here, as a bad pattern might otherwise only be
+		// found once a file gets far enough into it
+		if _, err := path.Match(strings.Trim(line, "/"), ""); err != nil {
+			return nil, fmt.Errorf("%s in %s is not a valid pattern", line, IgnoreFile)
+		}
+		ignores = append(ignores, line)
+	}
+	return ignores, scanner.Err()
+}
+
+// isIgnored checks if the path matches any of the patterns. Patterns ending
+// in a slash only match directories, patterns without a slash match the name
+// of a file or directory anywhere, and other patterns match the full path.
+func isIgnored(relative string, isDir bool, ignores []string) (bool, error) {
+	for _, pattern := range ignores {
+		if strings.HasSuffix(pattern, "/") {
+			if !isDir {
+				continue
+			}
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+		pattern = strings.TrimPrefix(pattern, "/")
+		target := relative
+		if !strings.Contains(pattern, "/") {
+			target = path.Base(relative)
+		}
+		matched, err := path.Match(pattern, target)
+		if err != nil {
+			return false, fmt.Errorf("%s in %s is not a valid pattern", pattern, IgnoreFile)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,187 @@
+package builder
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// writeFiles creates the files with their contents in a new directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filePath, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// packagedFiles returns the names and modes of the files in the package
+func packagedFiles(t *testing.T, pkg *Package) map[string]os.FileMode {
+	t.Helper()
+	reader, err := zip.NewReader(bytes.NewReader(pkg.Data), int64(len(pkg.Data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]os.FileMode)
+	for _, file := range reader.File {
+		files[file.Name] = file.Mode()
+	}
+	return files
+}
+
+func TestBuildPackageIsDeterministic(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"index.js":     "exports.handler = async () => 'hello'",
+		"lib/util.js":  "module.exports = {}",
+		"lib/data.txt": "data",
+	})
+	first, err := BuildPackage(dir)
+	if err != nil {
+		t.Fatalf("BuildPackage failed: %s", err)
+	}
+	// Neither the modification time nor the permissions beyond the executable
+	// bit end up in the package
+	later := time.Now().Add(time.Hour)
+	if err = os.Chtimes(filepath.Join(dir, "index.js"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chmod(filepath.Join(dir, "lib", "data.txt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	second, err := BuildPackage(dir)
+	if err != nil {
+		t.Fatalf("BuildPackage failed the second time: %s", err)
+	}
+	if !bytes.Equal(first.Data, second.Data) {
+		t.Error("building the same files twice resulted in different packages")
+	}
+	if first.SHA256 != second.SHA256 {
+		t.Errorf("got checksums %s and %s for the same files", first.SHA256, second.SHA256)
+	}
+}
+
+func TestBuildPackageExecutables(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"bootstrap": "#!/bin/sh",
+		"README":    "text",
+	})
+	if err := os.Chmod(filepath.Join(dir, "bootstrap"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := BuildPackage(dir)
+	if err != nil {
+		t.Fatalf("BuildPackage failed: %s", err)
+	}
+	want := map[string]os.FileMode{"bootstrap": 0755, "README": 0644}
+	if got := packagedFiles(t, pkg); !reflect.DeepEqual(got, want) {
+		t.Errorf("got files %v, want %v", got, want)
+	}
+}
+
+func TestBuildPackageIgnores(t *testing.T) {
+	files := map[string]string{
+		"index.js":             "code",
+		"notes.md":             "notes",
+		"docs/guide.md":        "guide",
+		"test/index_test.js":   "test",
+		"lib/test/fixture.js":  "fixture",
+		"lib/test.js":          "code",
+		"build/output.js":      "output",
+		".git/HEAD":            "ref",
+		"node_modules/a/a.js":  "module",
+		"node_modules/a/a.map": "map",
+	}
+	tests := map[string]struct {
+		ignores string
+		want    []string
+	}{
+		"defaults": {
+			want: []string{"build/output.js", "docs/guide.md", "index.js", "lib/test.js", "lib/test/fixture.js", "node_modules/a/a.js", "node_modules/a/a.map", "notes.md", "test/index_test.js"},
+		},
+		"name anywhere": {
+			ignores: "*.md\n*.map\n",
+			want:    []string{"build/output.js", "index.js", "lib/test.js", "lib/test/fixture.js", "node_modules/a/a.js", "test/index_test.js"},
+		},
+		"directories only": {
+			ignores: "test/\n",
+			want:    []string{"build/output.js", "docs/guide.md", "index.js", "lib/test.js", "node_modules/a/a.js", "node_modules/a/a.map", "notes.md"},
+		},
+		"full path": {
+			ignores: "# generated files\n/build/output.js\nnode_modules/*/a.map\n",
+			want:    []string{"docs/guide.md", "index.js", "lib/test.js", "lib/test/fixture.js", "node_modules/a/a.js", "notes.md", "test/index_test.js"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			withIgnores := make(map[string]string)
+			for file, contents := range files {
+				withIgnores[file] = contents
+			}
+			if test.ignores != "" {
+				withIgnores[IgnoreFile] = test.ignores
+			}
+			pkg, err := BuildPackage(writeFiles(t, withIgnores))
+			if err != nil {
+				t.Fatalf("BuildPackage failed: %s", err)
+			}
+			var got []string
+			for file := range packagedFiles(t, pkg) {
+				got = append(got, file)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got files %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestBuildPackageErrors(t *testing.T) {
+	tests := map[string]map[string]string{
+		"bad pattern":     {"index.js": "code", IgnoreFile: "[a-\n"},
+		"nested bad":      {"index.js": "code", IgnoreFile: "lib/[\n"},
+		"nothing left":    {"index.js": "code", IgnoreFile: "*.js\n"},
+		"empty directory": {},
+	}
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := BuildPackage(writeFiles(t, files)); err == nil {
+				t.Error("BuildPackage didn't fail")
+			}
+		})
+	}
+}
+
+func TestPackageSHA256(t *testing.T) {
+	first, err := BuildPackage(writeFiles(t, map[string]string{"index.js": "one"}))
+	if err != nil {
+		t.Fatalf("BuildPackage failed: %s", err)
+	}
+	// The checksum is calculated the same way as Lambda's CodeSha256
+	hash := sha256.Sum256(first.Data)
+	if want := base64.StdEncoding.EncodeToString(hash[:]); first.SHA256 != want {
+		t.Errorf("got checksum %s, want %s", first.SHA256, want)
+	}
+	second, err := BuildPackage(writeFiles(t, map[string]string{"index.js": "two"}))
+	if err != nil {
+		t.Fatalf("BuildPackage failed: %s", err)
+	}
+	if first.SHA256 == second.SHA256 {
+		t.Error("different files resulted in the same checksum")
+	}
+}
@@ -0,0 +1,651 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"gopkg.in/yaml.v2"
+)
+
+// The actions a PlanItem can take
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionNoOp   = "no-op"
+)
+
+// Project describes the roles, functions, gateways, schedules, and API keys
+// that should exist
+type Project struct {
+	Region    string               `yaml:"region"`
+	Roles     []RoleDefinition     `yaml:"roles"`
+	Functions []FunctionDefinition `yaml:"functions"`
+	APIKeys   []APIKeyDefinition   `yaml:"apikeys"`
+}
+
+// RoleDefinition describes an IAM Role, using the same types as aqua role create
+type RoleDefinition struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
+	Filename string `yaml:"filename"`
+}
+
+// FunctionDefinition describes a Lambda function, its Gateway, and its schedule
+type FunctionDefinition struct {
+	Name           string            `yaml:"name"`
+	Role           string            `yaml:"role"`
+	File           string            `yaml:"file"`
+	Source         string            `yaml:"source"`
+	UploadBucket   string            `yaml:"upload-bucket"`
+	Runtime        string            `yaml:"runtime"`
+	Handler        string            `yaml:"handler"`
+	Memory         int64             `yaml:"memory"`
+	Timeout        int64             `yaml:"timeout"`
+	Description    string            `yaml:"description"`
+	Environment    map[string]string `yaml:"env"`
+	EnvFile        string            `yaml:"env-file"`
+	Architecture   string            `yaml:"architecture"`
+	Tracing        string            `yaml:"tracing"`
+	Concurrency    *int64            `yaml:"concurrency"`
+	Publish        bool              `yaml:"publish"`
+	Alias          string            `yaml:"alias"`
+	Stage          string            `yaml:"stage"`
+	DeadLetter     string            `yaml:"dead-letter"`
+	Tags           map[string]string `yaml:"tags"`
+	Authentication string            `yaml:"authentication"`
+	Authorizer     string            `yaml:"authorizer-function"`
+	AuthorizerType string            `yaml:"authorizer-type"`
+	Identity       string            `yaml:"authorizer-identity"`
+	AuthorizerTTL  *int64            `yaml:"authorizer-ttl"`
+	UserPools      []string          `yaml:"cognito-user-pools"`
+	OAuthScopes    []string          `yaml:"oauth-scopes"`
+	APIKey         bool              `yaml:"apikey"`
+	Methods        []string          `yaml:"methods"`
+	Proxy          bool              `yaml:"proxy"`
+	CORS           bool              `yaml:"cors"`
+	CORSOrigins    []string          `yaml:"cors-origins"`
+	CORSHeaders    []string          `yaml:"cors-headers"`
+	CORSMethods    []string          `yaml:"cors-methods"`
+	CORSMaxAge     int64             `yaml:"cors-max-age"`
+	NoGateway      bool              `yaml:"nogateway"`
+	APIID          string            `yaml:"api-id"`
+	Schedule       string            `yaml:"schedule"`
+}
+
+// APIKeyDefinition describes an API key, optionally attached to the API of
+// one of the functions
+type APIKeyDefinition struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Enabled     *bool  `yaml:"enabled"`
+	Function    string `yaml:"function"`
+}
+
+// PlanItem is the action required to make a single resource match the Project
+type PlanItem struct {
+	Type   string
+	Name   string
+	Action string
+	apply  func() error
+}
+
+// Apply carries out the action of the PlanItem
+func (item PlanItem) Apply() error {
+	if item.Action == ActionNoOp {
+		return nil
+	}
+	return item.apply()
+}
+
+// LoadProject reads a Project from a YAML or JSON file. The region is used
+// when the Project doesn't set its own. Unknown keys are refused, so a typo
+// doesn't silently leave out a setting.
+func LoadProject(filename string, region string) (*Project, error) {
+	contents, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	project := new(Project)
+	if err = yaml.UnmarshalStrict(contents, project); err != nil {
+		return nil, err
+	}
+	if project.Region == "" {
+		project.Region = region
+	}
+	return project, project.validate()
+}
+
+func (project *Project) validate() error {
+	functions := make(map[string]bool)
+	for _, role := range project.Roles {
+		if role.Name == "" {
+			return errors.New("Every role needs a name")
+		}
+	}
+	for _, function := range project.Functions {
+		if function.Name == "" {
+			return errors.New("Every function needs a name")
+		}
+		if functions[function.Name] {
+			return fmt.Errorf("Function %s is defined more than once", function.Name)
+		}
+		functions[function.Name] = true
+		// The roles can be part of the project, so they are only looked up when
+		// the functions are built
+		if _, err := project.config(function).Validate(nil); err != nil {
+			return fmt.Errorf("Function %s: %s", function.Name, err.Error())
+		}
+	}
+	for _, key := range project.APIKeys {
+		if key.Name == "" {
+			return errors.New("Every API key needs a name")
+		}
+		if key.Function != "" && !functions[key.Function] {
+			return fmt.Errorf("API key %s refers to unknown function %s", key.Name, key.Function)
+		}
+	}
+	return nil
+}
+
+// config translates a FunctionDefinition into the same settings the flags
+// of the aqua command provide
+func (project *Project) config(function FunctionDefinition) *Config {
+	authentication := function.Authentication
+	if authentication == "" {
+		authentication = "NONE"
+	}
+	identity := function.Identity
+	if identity == "" {
+		identity = DefaultAuthorizerIdentity
+	}
+	methods := function.Methods
+	concurrency := int64(-1)
+	if function.Concurrency != nil {
+		concurrency = *function.Concurrency
+	}
+	return &Config{
+		FunctionName:       aws.String(function.Name),
+		RoleName:           aws.String(function.Role),
+		Region:             aws.String(project.Region),
+		FilePath:           aws.String(function.File),
+		Source:             aws.String(function.Source),
+		UploadBucket:       aws.String(function.UploadBucket),
+		Authentication:     aws.String(authentication),
+		AuthorizerFunction: aws.String(function.Authorizer),
+		AuthorizerType:     aws.String(function.AuthorizerType),
+		AuthorizerIdentity: aws.String(identity),
+		AuthorizerTTL:      function.AuthorizerTTL,
+		UserPools:          &function.UserPools,
+		OAuthScopes:        &function.OAuthScopes,
+		ApikeyRequired:     aws.Bool(function.APIKey),
+		Runtime:            aws.String(function.Runtime),
+		HTTPMethods:        &methods,
+		NoGateway:          aws.Bool(function.NoGateway),
+		KeepOnFailure:      aws.Bool(false),
+		Proxy:              aws.Bool(function.Proxy),
+		CORS:               aws.Bool(function.CORS),
+		CORSOriginList:     &function.CORSOrigins,
+		CORSHeaderList:     &function.CORSHeaders,
+		CORSMethodList:     &function.CORSMethods,
+		CORSMaxAge:         aws.Int64(function.CORSMaxAge),
+		APIID:              aws.String(function.APIID),
+		Handler:            aws.String(function.Handler),
+		MemorySize:         aws.Int64(function.Memory),
+		Timeout:            aws.Int64(function.Timeout),
+		Description:        aws.String(function.Description),
+		Environment:        pairs(function.Environment),
+		EnvFile:            aws.String(function.EnvFile),
+		Architecture:       aws.String(function.Architecture),
+		TracingMode:        aws.String(function.Tracing),
+		Concurrency:        aws.Int64(concurrency),
+		Publish:            aws.Bool(function.Publish),
+		Alias:              aws.String(function.Alias),
+		Stage:              aws.String(function.Stage),
+		DeadLetterARN:      aws.String(function.DeadLetter),
+		Tags:               pairs(function.Tags),
+		Schedule:           aws.String(function.Schedule),
+	}
+}
+
+// pairs turns a map into the KEY=VALUE strings used by the flags
+func pairs(values map[string]string) *[]string {
+	var result []string
+	for key, value := range values {
+		result = append(result, fmt.Sprintf("%s=%s", key, value))
+	}
+	return &result
+}
+
+// Plan compares the Project against what exists in the account, and returns
+// the actions required to make them match in the order they need to be applied
+func (project *Project) Plan(clients *Clients) ([]PlanItem, error) {
+	var items []PlanItem
+	for _, role := range project.Roles {
+		item, err := planRole(clients, role)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	for _, function := range project.Functions {
+		settings := project.config(function)
+		functionItems, err := planFunction(clients, settings)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, functionItems...)
+		if function.Schedule != "" {
+			item, err := planSchedule(clients, settings, function.Schedule)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+	}
+	for _, key := range project.APIKeys {
+		item, err := project.planAPIKey(clients, key)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func planRole(clients *Clients, role RoleDefinition) (PlanItem, error) {
+	item := PlanItem{Type: "Role", Name: role.Name}
+	template, err := RoleTemplate(role.Type, role.Filename)
+	if err != nil {
+		return item, fmt.Errorf("Role %s: %s", role.Name, err.Error())
+	}
+	_, err = GetRole(clients, aws.String(role.Name))
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NoSuchEntity" {
+			item.Action = ActionCreate
+			item.apply = func() error {
+				return CreateIAMRole(clients, template, aws.String(role.Name))
+			}
+			return item, nil
+		}
+		return item, err
+	}
+
+	item.Action = ActionNoOp
+	item.apply = func() error {
+		return PutRolePolicy(clients, template, aws.String(role.Name))
+	}
+	policy, err := clients.IAM.GetRolePolicy(&iam.GetRolePolicyInput{
+		PolicyName: aws.String(rolePolicyName),
+		RoleName:   aws.String(role.Name),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NoSuchEntity" {
+			item.Action = ActionUpdate
+			return item, nil
+		}
+		return item, err
+	}
+	// The policy document is returned URL encoded
+	document, err := url.QueryUnescape(aws.StringValue(policy.PolicyDocument))
+	if err != nil {
+		return item, err
+	}
+	if !sameJSON(document, template) {
+		item.Action = ActionUpdate
+	}
+	return item, nil
+}
+
+// planFunction returns the PlanItems for the Lambda function and its Gateway.
+// An existing function is updated when its code or configuration differs
+// from the settings.
+func planFunction(clients *Clients, settings *Config) ([]PlanItem, error) {
+	functionItem := PlanItem{
+		Type:   "Lambda function",
+		Name:   aws.StringValue(settings.FunctionName),
+		Action: ActionNoOp,
+	}
+	current, err := clients.Lambda.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: settings.FunctionName,
+	})
+	if err != nil {
+		if !isNotFound(err) {
+			return nil, err
+		}
+		functionItem.Action = ActionCreate
+	} else {
+		changed, err := functionChanged(&GatewayBuilder{Settings: settings, Clients: clients}, current)
+		if err != nil {
+			return nil, fmt.Errorf("Function %s: %s", aws.StringValue(settings.FunctionName), err.Error())
+		}
+		if changed {
+			functionItem.Action = ActionUpdate
+		}
+	}
+	update := functionItem.Action == ActionUpdate
+	functionItem.apply = func() error {
+		functionSettings := *settings
+		functionSettings.NoGateway = aws.Bool(true)
+		functionSettings.Update = aws.Bool(update)
+		return buildAndRollback(&GatewayBuilder{Settings: &functionSettings, Clients: clients})
+	}
+	items := []PlanItem{functionItem}
+
+	if aws.BoolValue(settings.NoGateway) {
+		return items, nil
+	}
+	gateway := &GatewayBuilder{Settings: settings, Clients: clients}
+	if functionItem.Action != ActionCreate {
+		gateway.Lambda = current
+	}
+	gatewayItem := PlanItem{
+		Type: "API",
+		Name: gateway.APIName(),
+		apply: func() error {
+			return buildAndRollback(&GatewayBuilder{Settings: settings, Clients: clients})
+		},
+	}
+	gatewayItem.Action, err = gatewayAction(gateway)
+	if err != nil {
+		return nil, err
+	}
+	return append(items, gatewayItem), nil
+}
+
+// functionChanged checks if the code, configuration, tags, or reserved
+// concurrency of the existing function differ from the settings
+func functionChanged(builder *GatewayBuilder, current *lambda.FunctionConfiguration) (bool, error) {
+	changed, err := builder.codeChanged(current)
+	if err != nil {
+		return false, err
+	}
+	if changed || builder.architectureChanged(current) {
+		return true, nil
+	}
+	if _, changed, err = builder.configurationUpdate(current); err != nil || changed {
+		return changed, err
+	}
+	tags, err := builder.tagChanges(current)
+	if err != nil || len(tags) > 0 {
+		return len(tags) > 0, err
+	}
+	return builder.concurrencyChanged()
+}
+
+// buildAndRollback builds the Gateway, and removes whatever was created if
+// that fails
+func buildAndRollback(builder *GatewayBuilder) error {
+	err := builder.Build()
+	if err != nil {
+		builder.Rollback()
+	}
+	return err
+}
+
+// gatewayAction determines whether the Gateway for the function needs to be
+// created or updated, by comparing the endpoint's methods, their
+// integrations, the authorizer, and the stage with the settings
+func gatewayAction(builder *GatewayBuilder) (string, error) {
+	api, err := builder.findAPIGateway()
+	if err != nil {
+		return "", err
+	}
+	if api == nil {
+		return ActionCreate, nil
+	}
+	// A new function needs new integrations and permissions
+	if builder.Lambda == nil {
+		return ActionUpdate, nil
+	}
+	builder.APIGateway = api
+	changed, err := gatewayChanged(builder)
+	if err != nil {
+		return "", err
+	}
+	if changed {
+		return ActionUpdate, nil
+	}
+	return ActionNoOp, nil
+}
+
+// gatewayChanged checks if the resources, methods, integrations, authorizer,
+// or stage of an existing API differ from the settings
+func gatewayChanged(builder *GatewayBuilder) (bool, error) {
+	settings := builder.Settings
+	resources, err := getResources(builder.Clients, builder.APIGateway.Id)
+	if err != nil {
+		return false, err
+	}
+	path := "/" + settings.CleanName()
+	builder.Resource = resources[path]
+	builder.ProxyResource = resources[path+"/{proxy+}"]
+	if builder.Resource == nil || (builder.ProxyResource != nil) != aws.BoolValue(settings.Proxy) {
+		return true, nil
+	}
+	changed, err := builder.authorizerChanged()
+	if err != nil || changed {
+		return changed, err
+	}
+	wanted, err := settings.Methods()
+	if err != nil {
+		return false, err
+	}
+	if settings.CORSEnabled() {
+		wanted = append(wanted, "OPTIONS")
+	}
+	checked := []*apigateway.Resource{builder.Resource}
+	if builder.ProxyResource != nil {
+		checked = append(checked, builder.ProxyResource)
+	}
+	for _, resource := range checked {
+		var existing []string
+		for method := range resource.ResourceMethods {
+			existing = append(existing, method)
+		}
+		if !sameStrings(wanted, existing) {
+			return true, nil
+		}
+		methods, err := builder.getMethods(resource)
+		if err != nil {
+			return false, err
+		}
+		for _, method := range methods {
+			changed, err := builder.methodChanged(method)
+			if err != nil || changed {
+				return changed, err
+			}
+		}
+	}
+	return builder.stageChanged()
+}
+
+// methodChanged checks if the authorization, integration, or CORS headers of
+// an existing method differ from what the settings would configure
+func (builder *GatewayBuilder) methodChanged(method *apigateway.Method) (bool, error) {
+	settings := builder.Settings
+	integration := method.MethodIntegration
+	if integration == nil {
+		return true, nil
+	}
+	if aws.StringValue(method.HttpMethod) == "OPTIONS" {
+		headers, err := builder.corsResponseHeaders(true)
+		if err != nil {
+			return false, err
+		}
+		return aws.StringValue(integration.Type) != "MOCK" ||
+			responseChanged(integration, headers, builder.corsOriginTemplate("")), nil
+	}
+	if aws.StringValue(method.AuthorizationType) != aws.StringValue(builder.authorizationType()) ||
+		aws.StringValue(method.AuthorizerId) != aws.StringValue(builder.authorizerID()) ||
+		!sameStrings(aws.StringValueSlice(method.AuthorizationScopes), aws.StringValueSlice(builder.authorizationScopes())) ||
+		aws.BoolValue(method.ApiKeyRequired) != aws.BoolValue(settings.ApikeyRequired) ||
+		aws.StringValue(integration.Uri) != builder.integrationURI() {
+		return true, nil
+	}
+	if aws.BoolValue(settings.Proxy) {
+		return aws.StringValue(integration.Type) != "AWS_PROXY", nil
+	}
+	if aws.StringValue(integration.Type) != "AWS" {
+		return true, nil
+	}
+	var headers map[string]string
+	var templates map[string]*string
+	if settings.CORSEnabled() {
+		var err error
+		if headers, err = builder.corsResponseHeaders(false); err != nil {
+			return false, err
+		}
+		templates = builder.corsOriginTemplate(`$input.json("$")`)
+	}
+	return responseChanged(integration, headers, templates), nil
+}
+
+// responseChanged checks if the headers and templates of the integration
+// response differ from the ones that would be configured
+func responseChanged(integration *apigateway.Integration, headers map[string]string, templates map[string]*string) bool {
+	response, ok := integration.IntegrationResponses["200"]
+	if !ok {
+		return true
+	}
+	_, parameters := corsMappings(headers)
+	return !reflect.DeepEqual(aws.StringValueMap(response.ResponseParameters), aws.StringValueMap(parameters)) ||
+		!reflect.DeepEqual(aws.StringValueMap(response.ResponseTemplates), aws.StringValueMap(templates))
+}
+
+// stageChanged checks if the stage of the function is missing, or doesn't
+// invoke the alias from the settings
+func (builder *GatewayBuilder) stageChanged() (bool, error) {
+	stage, err := builder.Clients.APIGateway.GetStage(&apigateway.GetStageInput{
+		RestApiId: builder.APIGateway.Id,
+		StageName: aws.String(builder.StageName()),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	alias := aws.StringValue(builder.Settings.Alias)
+	return alias != "" && aws.StringValue(stage.Variables[aliasVariable]) != alias, nil
+}
+
+// sameStrings checks if both slices contain the same values, in any order
+func sameStrings(first []string, second []string) bool {
+	if len(first) != len(second) {
+		return false
+	}
+	first = append([]string{}, first...)
+	second = append([]string{}, second...)
+	sort.Strings(first)
+	sort.Strings(second)
+	return reflect.DeepEqual(first, second)
+}
+
+func planSchedule(clients *Clients, settings *Config, schedule string) (PlanItem, error) {
+	item := PlanItem{
+		Type:   "Schedule",
+		Name:   fmt.Sprintf("%s (%s)", schedule, aws.StringValue(settings.FunctionName)),
+		Action: ActionNoOp,
+		apply: func() error {
+			return CreateSchedule(clients, settings, schedule)
+		},
+	}
+	rule, err := clients.Events.DescribeRule(&cloudwatchevents.DescribeRuleInput{
+		Name: aws.String(scheduleRuleName(settings, schedule)),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			item.Action = ActionCreate
+			return item, nil
+		}
+		return item, err
+	}
+	if aws.StringValue(rule.ScheduleExpression) != schedule {
+		item.Action = ActionUpdate
+		return item, nil
+	}
+	function, err := clients.Lambda.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: settings.FunctionName,
+	})
+	if err != nil {
+		if isNotFound(err) {
+			item.Action = ActionUpdate
+			return item, nil
+		}
+		return item, err
+	}
+	targets, err := clients.Events.ListTargetsByRule(&cloudwatchevents.ListTargetsByRuleInput{
+		Rule: rule.Name,
+	})
+	if err != nil {
+		return item, err
+	}
+	item.Action = ActionUpdate
+	for _, target := range targets.Targets {
+		if aws.StringValue(target.Arn) == aws.StringValue(function.FunctionArn) {
+			item.Action = ActionNoOp
+		}
+	}
+	return item, nil
+}
+
+func (project *Project) planAPIKey(clients *Clients, key APIKeyDefinition) (PlanItem, error) {
+	item := PlanItem{Type: "API key", Name: key.Name, Action: ActionNoOp}
+	exists := false
+	err := EachAPIKey(clients, ListOptions{NamePrefix: key.Name}, func(existing *apigateway.ApiKey) bool {
+		exists = aws.StringValue(existing.Name) == key.Name
+		return !exists
+	})
+	if err != nil || exists {
+		return item, err
+	}
+
+	item.Action = ActionCreate
+	item.apply = func() error {
+		enabled := key.Enabled == nil || *key.Enabled
+		var apiID, stage string
+		if key.Function != "" {
+			for _, function := range project.Functions {
+				if function.Name == key.Function {
+					gateway := &GatewayBuilder{Settings: project.config(function), Clients: clients}
+					api, err := gateway.findAPIGateway()
+					if err != nil {
+						return err
+					}
+					if api == nil {
+						return fmt.Errorf("Function %s doesn't have an API to attach API key %s to",
+							function.Name, key.Name)
+					}
+					apiID = aws.StringValue(api.Id)
+					stage = gateway.StageName()
+				}
+			}
+		}
+		_, _, err := CreateAPIKey(clients, key.Name, key.Description, enabled, apiID, stage, UsagePlanSettings{})
+		return err
+	}
+	return item, nil
+}
+
+// sameJSON checks if two JSON documents have the same contents
+func sameJSON(first string, second string) bool {
+	var firstValue, secondValue interface{}
+	if json.Unmarshal([]byte(first), &firstValue) != nil {
+		return false
+	}
+	if json.Unmarshal([]byte(second), &secondValue) != nil {
+		return false
+	}
+	return reflect.DeepEqual(firstValue, secondValue)
+}
@@ -0,0 +1,317 @@
+package builder
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// testProject returns a project with a role, a function with a Gateway and a
+// schedule, and an API key for it
+func testProject() *Project {
+	return &Project{
+		Region: "us-east-1",
+		Roles:  []RoleDefinition{{Name: "lambda-basic", Type: "basic"}},
+		Functions: []FunctionDefinition{{
+			Name:     "hello",
+			Role:     "lambda-basic",
+			Methods:  []string{"GET"},
+			Schedule: "rate(1 hour)",
+		}},
+	}
+}
+
+// actions returns the action of each PlanItem by its type
+func actions(items []PlanItem) map[string]string {
+	result := make(map[string]string)
+	for _, item := range items {
+		result[item.Type] = item.Action
+	}
+	return result
+}
+
+func TestPlan(t *testing.T) {
+	tests := map[string]struct {
+		setup  func(*Project)
+		change func(*Project)
+		drift  func(*fakeAWS)
+		want   map[string]string
+	}{
+		"unchanged": {
+			want: map[string]string{"Role": ActionNoOp, "Lambda function": ActionNoOp, "API": ActionNoOp, "Schedule": ActionNoOp},
+		},
+		"methods": {
+			change: func(project *Project) { project.Functions[0].Methods = []string{"GET", "POST"} },
+			want:   map[string]string{"Role": ActionNoOp, "Lambda function": ActionNoOp, "API": ActionUpdate, "Schedule": ActionNoOp},
+		},
+		"proxy": {
+			change: func(project *Project) { project.Functions[0].Proxy = true },
+			want:   map[string]string{"Role": ActionNoOp, "Lambda function": ActionNoOp, "API": ActionUpdate, "Schedule": ActionNoOp},
+		},
+		"memory": {
+			change: func(project *Project) { project.Functions[0].Memory = 512 },
+			want:   map[string]string{"Role": ActionNoOp, "Lambda function": ActionUpdate, "API": ActionNoOp, "Schedule": ActionNoOp},
+		},
+		"runtime": {
+			change: func(project *Project) { project.Functions[0].Runtime = "python3.13" },
+			want:   map[string]string{"Role": ActionNoOp, "Lambda function": ActionUpdate, "API": ActionNoOp, "Schedule": ActionNoOp},
+		},
+		"role": {
+			change: func(project *Project) { project.Roles[0].Type = "s3" },
+			want:   map[string]string{"Role": ActionUpdate, "Lambda function": ActionNoOp, "API": ActionNoOp, "Schedule": ActionNoOp},
+		},
+		"schedule": {
+			change: func(project *Project) { project.Functions[0].Schedule = "rate(2 hours)" },
+			want:   map[string]string{"Role": ActionNoOp, "Lambda function": ActionNoOp, "API": ActionNoOp, "Schedule": ActionCreate},
+		},
+		"unchanged proxy": {
+			setup: func(project *Project) {
+				project.Functions[0].Proxy = true
+				project.Functions[0].CORS = true
+			},
+			want: map[string]string{"API": ActionNoOp},
+		},
+		"authentication": {
+			change: func(project *Project) { project.Functions[0].Authentication = "AWS_IAM" },
+			want:   map[string]string{"API": ActionUpdate},
+		},
+		"api key": {
+			change: func(project *Project) { project.Functions[0].APIKey = true },
+			want:   map[string]string{"API": ActionUpdate},
+		},
+		"authorizer": {
+			change: func(project *Project) { project.Functions[0].Authorizer = "hello" },
+			want:   map[string]string{"API": ActionUpdate},
+		},
+		"authorizer ttl": {
+			setup:  func(project *Project) { project.Functions[0].Authorizer = "hello" },
+			change: func(project *Project) { project.Functions[0].AuthorizerTTL = aws.Int64(60) },
+			want:   map[string]string{"API": ActionUpdate},
+		},
+		"unchanged authorizer": {
+			setup: func(project *Project) { project.Functions[0].Authorizer = "hello" },
+			want:  map[string]string{"API": ActionNoOp},
+		},
+		"cors": {
+			change: func(project *Project) { project.Functions[0].CORS = true },
+			want:   map[string]string{"API": ActionUpdate},
+		},
+		"cors origins": {
+			setup: func(project *Project) { project.Functions[0].CORS = true },
+			change: func(project *Project) {
+				project.Functions[0].CORSOrigins = []string{"https://example.com", "https://example.org"}
+			},
+			want: map[string]string{"API": ActionUpdate},
+		},
+		"unchanged cors": {
+			setup: func(project *Project) {
+				project.Functions[0].CORS = true
+				project.Functions[0].CORSOrigins = []string{"https://example.com", "https://example.org"}
+			},
+			want: map[string]string{"API": ActionNoOp},
+		},
+		"alias": {
+			change: func(project *Project) {
+				project.Functions[0].Publish = true
+				project.Functions[0].Alias = "live"
+			},
+			want: map[string]string{"API": ActionUpdate},
+		},
+		"stage": {
+			change: func(project *Project) { project.Functions[0].Stage = "dev" },
+			want:   map[string]string{"API": ActionUpdate},
+		},
+		"integration uri": {
+			drift: func(account *fakeAWS) {
+				account.api().resource("/hello").ResourceMethods["GET"].MethodIntegration.Uri = aws.String("arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/other/invocations")
+			},
+			want: map[string]string{"API": ActionUpdate},
+		},
+		"integration type": {
+			drift: func(account *fakeAWS) {
+				account.api().resource("/hello").ResourceMethods["GET"].MethodIntegration.Type = aws.String("AWS_PROXY")
+			},
+			want: map[string]string{"API": ActionUpdate},
+		},
+		"deleted stage": {
+			drift: func(account *fakeAWS) { delete(account.api().stages, "prod") },
+			want:  map[string]string{"API": ActionUpdate},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			project := testProject()
+			if test.setup != nil {
+				test.setup(project)
+			}
+			items, err := project.Plan(clients)
+			if err != nil {
+				t.Fatalf("Plan failed: %s", err)
+			}
+			for kind, action := range actions(items) {
+				if action != ActionCreate {
+					t.Errorf("got %s for %s in an empty account, want %s", action, kind, ActionCreate)
+				}
+			}
+			for _, item := range items {
+				if err := item.Apply(); err != nil {
+					t.Fatalf("applying %s %s failed: %s", item.Type, item.Name, err)
+				}
+			}
+
+			if test.change != nil {
+				test.change(project)
+			}
+			if test.drift != nil {
+				test.drift(account)
+			}
+			items, err = project.Plan(clients)
+			if err != nil {
+				t.Fatalf("Plan after applying failed: %s", err)
+			}
+			got := actions(items)
+			for kind, action := range test.want {
+				if got[kind] != action {
+					t.Errorf("got %s for %s, want %s", got[kind], kind, action)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanSharedSchedule(t *testing.T) {
+	clients, account := newFakeClients()
+	project := testProject()
+	project.Functions[0].NoGateway = true
+	project.Functions = append(project.Functions, FunctionDefinition{
+		Name:      "world",
+		Role:      "lambda-basic",
+		NoGateway: true,
+		Schedule:  "rate(1 hour)",
+	})
+	apply := func() {
+		t.Helper()
+		items, err := project.Plan(clients)
+		if err != nil {
+			t.Fatalf("Plan failed: %s", err)
+		}
+		for _, item := range items {
+			if err := item.Apply(); err != nil {
+				t.Fatalf("applying %s %s failed: %s", item.Type, item.Name, err)
+			}
+		}
+	}
+	apply()
+
+	for _, function := range []string{"hello", "world"} {
+		rule := function + "-rate1hour"
+		targets := account.targets[rule]
+		if len(targets) != 1 || aws.StringValue(targets[0].Arn) != aws.StringValue(account.functions[function].FunctionArn) {
+			t.Errorf("rule %s doesn't only invoke %s", rule, function)
+		}
+	}
+	items, err := project.Plan(clients)
+	if err != nil {
+		t.Fatalf("Plan after applying failed: %s", err)
+	}
+	for _, item := range items {
+		if item.Action != ActionNoOp {
+			t.Errorf("got %s for %s %s, want %s", item.Action, item.Type, item.Name, ActionNoOp)
+		}
+	}
+
+	// A changed schedule replaces the earlier rule of the function
+	project.Functions[0].Schedule = "rate(2 hours)"
+	apply()
+	if _, ok := account.rules["hello-rate1hour"]; ok {
+		t.Error("the earlier schedule of hello was left behind")
+	}
+	if _, ok := account.rules["world-rate1hour"]; !ok {
+		t.Error("the schedule of world was removed")
+	}
+	if len(account.targets["hello-rate2hours"]) != 1 {
+		t.Error("the new schedule of hello doesn't invoke it")
+	}
+}
+
+func TestPlanFunctionChanges(t *testing.T) {
+	source := t.TempDir()
+	write := func(contents string) {
+		if err := ioutil.WriteFile(filepath.Join(source, "index.js"), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("exports.handler = async () => 'one'")
+	clients, account := newFakeClients()
+	project := testProject()
+	project.Functions[0].Source = source
+	project.Functions[0].Memory = 256
+
+	apply := func(want string) {
+		t.Helper()
+		items, err := project.Plan(clients)
+		if err != nil {
+			t.Fatalf("Plan failed: %s", err)
+		}
+		for _, item := range items {
+			if item.Type == "Lambda function" && item.Action != want {
+				t.Errorf("got %s for the function, want %s", item.Action, want)
+			}
+			if err := item.Apply(); err != nil {
+				t.Fatalf("applying %s %s failed: %s", item.Type, item.Name, err)
+			}
+		}
+	}
+	apply(ActionCreate)
+	apply(ActionNoOp)
+
+	write("exports.handler = async () => 'two'")
+	project.Functions[0].Memory = 512
+	apply(ActionUpdate)
+	if got := account.called("UpdateFunctionCode"); got != 1 {
+		t.Errorf("got %d code updates, want 1", got)
+	}
+	if got := *account.functions["hello"].MemorySize; got != 512 {
+		t.Errorf("got %d MB of memory after the update, want 512", got)
+	}
+	apply(ActionNoOp)
+}
+
+func TestLoadProjectRegion(t *testing.T) {
+	tests := map[string]struct {
+		contents string
+		want     string
+		invalid  bool
+	}{
+		"default":  {contents: "functions:\n  - name: hello\n", want: "eu-west-1"},
+		"project":  {contents: "region: us-west-2\nfunctions:\n  - name: hello\n", want: "us-west-2"},
+		"invalid":  {contents: "region: nowhere\nfunctions:\n  - name: hello\n", invalid: true},
+		"function": {contents: "functions:\n  - name: hello\n    memory: 1\n", invalid: true},
+		"unknown":  {contents: "functions:\n  - name: hello\n    memroy: 512\n", invalid: true},
+		"json":     {contents: `{"region": "us-west-2", "functions": [{"name": "hello"}]}`, want: "us-west-2"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "aqua.yaml")
+			if err := ioutil.WriteFile(filename, []byte(test.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			project, err := LoadProject(filename, "eu-west-1")
+			if test.invalid {
+				if err == nil {
+					t.Error("LoadProject accepted an invalid project")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadProject failed: %s", err)
+			}
+			if project.Region != test.want {
+				t.Errorf("got region %s, want %s", project.Region, test.want)
+			}
+		})
+	}
+}
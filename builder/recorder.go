@@ -0,0 +1,548 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/apigateway/apigatewayiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents/cloudwatcheventsiface"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// dryRunAccount is the account ID used in the ARNs of recorded resources
+const dryRunAccount = "123456789012"
+
+// Call is a single AWS API call that was recorded instead of executed
+type Call struct {
+	Service   string
+	Operation string
+	Input     interface{}
+}
+
+// Recorder keeps track of the calls made through recording clients, and of
+// the resources those calls would have created
+type Recorder struct {
+	Calls     []Call
+	region    string
+	counter   int
+	apis      map[string]bool
+	functions map[string]*lambda.FunctionConfiguration
+	roles     map[string]*iam.Role
+	stages    map[string]*apigateway.Stage
+}
+
+// NewRecordingClients wraps the provided clients so that every call that
+// would make a change is recorded in the Recorder instead of executed, and
+// returns synthetic IDs and ARNs. Calls that only read are passed on to the
+// provided clients, so the recorded calls reflect the current state of the
+// account. Any call Aqua makes that changes something has to be overridden
+// here.
+func NewRecordingClients(clients *Clients, region *string) (*Clients, *Recorder) {
+	recorder := &Recorder{
+		region:    aws.StringValue(region),
+		apis:      make(map[string]bool),
+		functions: make(map[string]*lambda.FunctionConfiguration),
+		roles:     make(map[string]*iam.Role),
+		stages:    make(map[string]*apigateway.Stage),
+	}
+	return &Clients{
+		APIGateway: &recordingAPIGateway{APIGatewayAPI: clients.APIGateway, recorder: recorder},
+		Lambda:     &recordingLambda{LambdaAPI: clients.Lambda, recorder: recorder},
+		IAM:        &recordingIAM{IAMAPI: clients.IAM, recorder: recorder},
+		Events:     &recordingEvents{CloudWatchEventsAPI: clients.Events, recorder: recorder},
+		S3:         &recordingS3{S3API: clients.S3, recorder: recorder},
+	}, recorder
+}
+
+func (recorder *Recorder) record(service string, operation string, input interface{}) {
+	recorder.Calls = append(recorder.Calls, Call{Service: service, Operation: operation, Input: input})
+}
+
+// newID returns a synthetic ID that is unique within the Recorder
+func (recorder *Recorder) newID() string {
+	recorder.counter++
+	return fmt.Sprintf("dryrun%d", recorder.counter)
+}
+
+func (recorder *Recorder) arn(service string, resource string) string {
+	return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, recorder.region, dryRunAccount, resource)
+}
+
+type recordingAPIGateway struct {
+	apigatewayiface.APIGatewayAPI
+	recorder *Recorder
+}
+
+func (svc *recordingAPIGateway) CreateRestApi(input *apigateway.CreateRestApiInput) (*apigateway.RestApi, error) {
+	svc.recorder.record("apigateway", "CreateRestApi", input)
+	id := svc.recorder.newID()
+	svc.recorder.apis[id] = true
+	return &apigateway.RestApi{Id: aws.String(id), Name: input.Name, Description: input.Description}, nil
+}
+
+// GetResourcesPages returns a root resource for APIs that were only recorded
+func (svc *recordingAPIGateway) GetResourcesPages(input *apigateway.GetResourcesInput, fn func(*apigateway.GetResourcesOutput, bool) bool) error {
+	if !svc.recorder.apis[aws.StringValue(input.RestApiId)] {
+		return svc.APIGatewayAPI.GetResourcesPages(input, fn)
+	}
+	fn(&apigateway.GetResourcesOutput{
+		Items: []*apigateway.Resource{{Id: aws.String(svc.recorder.newID()), Path: aws.String("/")}},
+	}, true)
+	return nil
+}
+
+func (svc *recordingAPIGateway) CreateResource(input *apigateway.CreateResourceInput) (*apigateway.Resource, error) {
+	svc.recorder.record("apigateway", "CreateResource", input)
+	return &apigateway.Resource{
+		Id:       aws.String(svc.recorder.newID()),
+		ParentId: input.ParentId,
+		PathPart: input.PathPart,
+	}, nil
+}
+
+func (svc *recordingAPIGateway) DeleteMethod(input *apigateway.DeleteMethodInput) (*apigateway.DeleteMethodOutput, error) {
+	svc.recorder.record("apigateway", "DeleteMethod", input)
+	return &apigateway.DeleteMethodOutput{}, nil
+}
+
+func (svc *recordingAPIGateway) PutMethod(input *apigateway.PutMethodInput) (*apigateway.Method, error) {
+	svc.recorder.record("apigateway", "PutMethod", input)
+	return &apigateway.Method{HttpMethod: input.HttpMethod}, nil
+}
+
+func (svc *recordingAPIGateway) PutIntegration(input *apigateway.PutIntegrationInput) (*apigateway.Integration, error) {
+	svc.recorder.record("apigateway", "PutIntegration", input)
+	return &apigateway.Integration{Type: input.Type, Uri: input.Uri}, nil
+}
+
+func (svc *recordingAPIGateway) PutIntegrationResponse(input *apigateway.PutIntegrationResponseInput) (*apigateway.IntegrationResponse, error) {
+	svc.recorder.record("apigateway", "PutIntegrationResponse", input)
+	return &apigateway.IntegrationResponse{StatusCode: input.StatusCode}, nil
+}
+
+func (svc *recordingAPIGateway) PutMethodResponse(input *apigateway.PutMethodResponseInput) (*apigateway.MethodResponse, error) {
+	svc.recorder.record("apigateway", "PutMethodResponse", input)
+	return &apigateway.MethodResponse{StatusCode: input.StatusCode}, nil
+}
+
+func (svc *recordingAPIGateway) CreateDeployment(input *apigateway.CreateDeploymentInput) (*apigateway.Deployment, error) {
+	svc.recorder.record("apigateway", "CreateDeployment", input)
+	deployment := &apigateway.Deployment{Id: aws.String(svc.recorder.newID())}
+	if input.StageName != nil {
+		svc.recorder.stages[stageKey(input.RestApiId, input.StageName)] = &apigateway.Stage{
+			StageName:    input.StageName,
+			DeploymentId: deployment.Id,
+			Description:  input.StageDescription,
+			Variables:    input.Variables,
+		}
+	}
+	return deployment, nil
+}
+
+// GetStage returns the stages that were only recorded
+func (svc *recordingAPIGateway) GetStage(input *apigateway.GetStageInput) (*apigateway.Stage, error) {
+	if stage, ok := svc.recorder.stages[stageKey(input.RestApiId, input.StageName)]; ok {
+		return stage, nil
+	}
+	return svc.APIGatewayAPI.GetStage(input)
+}
+
+// GetStages adds the stages that were only recorded to the existing ones
+func (svc *recordingAPIGateway) GetStages(input *apigateway.GetStagesInput) (*apigateway.GetStagesOutput, error) {
+	resp := &apigateway.GetStagesOutput{}
+	if !svc.recorder.apis[aws.StringValue(input.RestApiId)] {
+		var err error
+		if resp, err = svc.APIGatewayAPI.GetStages(input); err != nil {
+			return nil, err
+		}
+	}
+	var stages []*apigateway.Stage
+	for _, stage := range resp.Item {
+		if _, ok := svc.recorder.stages[stageKey(input.RestApiId, stage.StageName)]; !ok {
+			stages = append(stages, stage)
+		}
+	}
+	for key, stage := range svc.recorder.stages {
+		if strings.HasPrefix(key, aws.StringValue(input.RestApiId)+"/") {
+			stages = append(stages, stage)
+		}
+	}
+	return &apigateway.GetStagesOutput{Item: stages}, nil
+}
+
+func (svc *recordingAPIGateway) UpdateStage(input *apigateway.UpdateStageInput) (*apigateway.Stage, error) {
+	svc.recorder.record("apigateway", "UpdateStage", input)
+	return svc.GetStage(&apigateway.GetStageInput{RestApiId: input.RestApiId, StageName: input.StageName})
+}
+
+func (svc *recordingAPIGateway) DeleteStage(input *apigateway.DeleteStageInput) (*apigateway.DeleteStageOutput, error) {
+	svc.recorder.record("apigateway", "DeleteStage", input)
+	delete(svc.recorder.stages, stageKey(input.RestApiId, input.StageName))
+	return &apigateway.DeleteStageOutput{}, nil
+}
+
+func (svc *recordingAPIGateway) CreateDomainName(input *apigateway.CreateDomainNameInput) (*apigateway.DomainName, error) {
+	svc.recorder.record("apigateway", "CreateDomainName", input)
+	domain := &apigateway.DomainName{
+		DomainName:            input.DomainName,
+		EndpointConfiguration: input.EndpointConfiguration,
+	}
+	if input.RegionalCertificateArn != nil {
+		domain.RegionalDomainName = aws.String(fmt.Sprintf("d-%s.execute-api.%s.amazonaws.com", svc.recorder.newID(), svc.recorder.region))
+	} else {
+		domain.DistributionDomainName = aws.String(fmt.Sprintf("%s.cloudfront.net", svc.recorder.newID()))
+	}
+	return domain, nil
+}
+
+func (svc *recordingAPIGateway) CreateBasePathMapping(input *apigateway.CreateBasePathMappingInput) (*apigateway.BasePathMapping, error) {
+	svc.recorder.record("apigateway", "CreateBasePathMapping", input)
+	basePath := aws.StringValue(input.BasePath)
+	if basePath == "" {
+		basePath = rootBasePath
+	}
+	return &apigateway.BasePathMapping{
+		BasePath:  aws.String(basePath),
+		RestApiId: input.RestApiId,
+		Stage:     input.Stage,
+	}, nil
+}
+
+func (svc *recordingAPIGateway) DeleteBasePathMapping(input *apigateway.DeleteBasePathMappingInput) (*apigateway.DeleteBasePathMappingOutput, error) {
+	svc.recorder.record("apigateway", "DeleteBasePathMapping", input)
+	return &apigateway.DeleteBasePathMappingOutput{}, nil
+}
+
+func (svc *recordingAPIGateway) CreateUsagePlan(input *apigateway.CreateUsagePlanInput) (*apigateway.UsagePlan, error) {
+	svc.recorder.record("apigateway", "CreateUsagePlan", input)
+	return &apigateway.UsagePlan{
+		Id:          aws.String(svc.recorder.newID()),
+		Name:        input.Name,
+		Description: input.Description,
+		ApiStages:   input.ApiStages,
+		Throttle:    input.Throttle,
+		Quota:       input.Quota,
+	}, nil
+}
+
+func (svc *recordingAPIGateway) UpdateUsagePlan(input *apigateway.UpdateUsagePlanInput) (*apigateway.UsagePlan, error) {
+	svc.recorder.record("apigateway", "UpdateUsagePlan", input)
+	return &apigateway.UsagePlan{Id: input.UsagePlanId}, nil
+}
+
+func (svc *recordingAPIGateway) TagResource(input *apigateway.TagResourceInput) (*apigateway.TagResourceOutput, error) {
+	svc.recorder.record("apigateway", "TagResource", input)
+	return &apigateway.TagResourceOutput{}, nil
+}
+
+func (svc *recordingAPIGateway) UntagResource(input *apigateway.UntagResourceInput) (*apigateway.UntagResourceOutput, error) {
+	svc.recorder.record("apigateway", "UntagResource", input)
+	return &apigateway.UntagResourceOutput{}, nil
+}
+
+func (svc *recordingAPIGateway) DeleteUsagePlan(input *apigateway.DeleteUsagePlanInput) (*apigateway.DeleteUsagePlanOutput, error) {
+	svc.recorder.record("apigateway", "DeleteUsagePlan", input)
+	return &apigateway.DeleteUsagePlanOutput{}, nil
+}
+
+func (svc *recordingAPIGateway) CreateUsagePlanKey(input *apigateway.CreateUsagePlanKeyInput) (*apigateway.UsagePlanKey, error) {
+	svc.recorder.record("apigateway", "CreateUsagePlanKey", input)
+	return &apigateway.UsagePlanKey{Id: input.KeyId, Type: input.KeyType}, nil
+}
+
+func (svc *recordingAPIGateway) DeleteUsagePlanKey(input *apigateway.DeleteUsagePlanKeyInput) (*apigateway.DeleteUsagePlanKeyOutput, error) {
+	svc.recorder.record("apigateway", "DeleteUsagePlanKey", input)
+	return &apigateway.DeleteUsagePlanKeyOutput{}, nil
+}
+
+func (svc *recordingAPIGateway) UpdateApiKey(input *apigateway.UpdateApiKeyInput) (*apigateway.ApiKey, error) {
+	svc.recorder.record("apigateway", "UpdateApiKey", input)
+	return &apigateway.ApiKey{Id: input.ApiKey}, nil
+}
+
+func (svc *recordingAPIGateway) DeleteApiKey(input *apigateway.DeleteApiKeyInput) (*apigateway.DeleteApiKeyOutput, error) {
+	svc.recorder.record("apigateway", "DeleteApiKey", input)
+	return &apigateway.DeleteApiKeyOutput{}, nil
+}
+
+// GetAuthorizers only looks up the authorizers of APIs that exist
+func (svc *recordingAPIGateway) GetAuthorizers(input *apigateway.GetAuthorizersInput) (*apigateway.GetAuthorizersOutput, error) {
+	if svc.recorder.apis[aws.StringValue(input.RestApiId)] {
+		return &apigateway.GetAuthorizersOutput{}, nil
+	}
+	return svc.APIGatewayAPI.GetAuthorizers(input)
+}
+
+func (svc *recordingAPIGateway) CreateAuthorizer(input *apigateway.CreateAuthorizerInput) (*apigateway.Authorizer, error) {
+	svc.recorder.record("apigateway", "CreateAuthorizer", input)
+	return &apigateway.Authorizer{
+		Id:                           aws.String(svc.recorder.newID()),
+		Name:                         input.Name,
+		Type:                         input.Type,
+		AuthorizerUri:                input.AuthorizerUri,
+		IdentitySource:               input.IdentitySource,
+		AuthorizerResultTtlInSeconds: input.AuthorizerResultTtlInSeconds,
+	}, nil
+}
+
+func (svc *recordingAPIGateway) UpdateAuthorizer(input *apigateway.UpdateAuthorizerInput) (*apigateway.Authorizer, error) {
+	svc.recorder.record("apigateway", "UpdateAuthorizer", input)
+	return &apigateway.Authorizer{Id: input.AuthorizerId}, nil
+}
+
+func (svc *recordingAPIGateway) DeleteAuthorizer(input *apigateway.DeleteAuthorizerInput) (*apigateway.DeleteAuthorizerOutput, error) {
+	svc.recorder.record("apigateway", "DeleteAuthorizer", input)
+	return &apigateway.DeleteAuthorizerOutput{}, nil
+}
+
+func stageKey(apiID *string, stage *string) string {
+	return aws.StringValue(apiID) + "/" + aws.StringValue(stage)
+}
+
+func (svc *recordingAPIGateway) DeleteRestApi(input *apigateway.DeleteRestApiInput) (*apigateway.DeleteRestApiOutput, error) {
+	svc.recorder.record("apigateway", "DeleteRestApi", input)
+	return &apigateway.DeleteRestApiOutput{}, nil
+}
+
+func (svc *recordingAPIGateway) DeleteResource(input *apigateway.DeleteResourceInput) (*apigateway.DeleteResourceOutput, error) {
+	svc.recorder.record("apigateway", "DeleteResource", input)
+	return &apigateway.DeleteResourceOutput{}, nil
+}
+
+func (svc *recordingAPIGateway) CreateApiKey(input *apigateway.CreateApiKeyInput) (*apigateway.ApiKey, error) {
+	svc.recorder.record("apigateway", "CreateApiKey", input)
+	return &apigateway.ApiKey{
+		Id:          aws.String(svc.recorder.newID()),
+		Name:        input.Name,
+		Description: input.Description,
+		Enabled:     input.Enabled,
+	}, nil
+}
+
+type recordingLambda struct {
+	lambdaiface.LambdaAPI
+	recorder *Recorder
+}
+
+// GetFunctionConfiguration returns the configuration of functions that were
+// only recorded
+func (svc *recordingLambda) GetFunctionConfiguration(input *lambda.GetFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	if function, ok := svc.recorder.functions[aws.StringValue(input.FunctionName)]; ok {
+		return function, nil
+	}
+	return svc.LambdaAPI.GetFunctionConfiguration(input)
+}
+
+func (svc *recordingLambda) CreateFunction(input *lambda.CreateFunctionInput) (*lambda.FunctionConfiguration, error) {
+	// Don't keep the contents of the zip file around
+	recorded := *input
+	if input.Code != nil {
+		code := *input.Code
+		code.ZipFile = nil
+		recorded.Code = &code
+	}
+	svc.recorder.record("lambda", "CreateFunction", &recorded)
+	function := &lambda.FunctionConfiguration{
+		FunctionName: input.FunctionName,
+		FunctionArn:  aws.String(svc.recorder.arn("lambda", "function:"+aws.StringValue(input.FunctionName))),
+		Handler:      input.Handler,
+		Role:         input.Role,
+		Runtime:      input.Runtime,
+	}
+	svc.recorder.functions[aws.StringValue(input.FunctionName)] = function
+	return function, nil
+}
+
+func (svc *recordingLambda) UpdateFunctionCode(input *lambda.UpdateFunctionCodeInput) (*lambda.FunctionConfiguration, error) {
+	recorded := *input
+	recorded.ZipFile = nil
+	svc.recorder.record("lambda", "UpdateFunctionCode", &recorded)
+	current, err := svc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: input.FunctionName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	function := *current
+	if input.ZipFile != nil {
+		function.CodeSha256 = aws.String(NewPackage(input.ZipFile).SHA256)
+		function.CodeSize = aws.Int64(int64(len(input.ZipFile)))
+	}
+	return &function, nil
+}
+
+func (svc *recordingLambda) UpdateFunctionConfiguration(input *lambda.UpdateFunctionConfigurationInput) (*lambda.FunctionConfiguration, error) {
+	svc.recorder.record("lambda", "UpdateFunctionConfiguration", input)
+	current, err := svc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: input.FunctionName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	function := *current
+	if input.Role != nil {
+		function.Role = input.Role
+	}
+	if input.Runtime != nil {
+		function.Runtime = input.Runtime
+	}
+	if input.Handler != nil {
+		function.Handler = input.Handler
+	}
+	if input.MemorySize != nil {
+		function.MemorySize = input.MemorySize
+	}
+	if input.Timeout != nil {
+		function.Timeout = input.Timeout
+	}
+	return &function, nil
+}
+
+// WaitUntilFunctionUpdated doesn't wait, as nothing was actually updated
+func (svc *recordingLambda) WaitUntilFunctionUpdated(input *lambda.GetFunctionConfigurationInput) error {
+	return nil
+}
+
+// WaitUntilFunctionActive doesn't wait, as nothing was actually created
+func (svc *recordingLambda) WaitUntilFunctionActive(input *lambda.GetFunctionConfigurationInput) error {
+	return nil
+}
+
+func (svc *recordingLambda) PublishVersion(input *lambda.PublishVersionInput) (*lambda.FunctionConfiguration, error) {
+	svc.recorder.record("lambda", "PublishVersion", input)
+	current, err := svc.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: input.FunctionName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	version := *current
+	version.Version = aws.String(svc.recorder.newID())
+	version.FunctionArn = aws.String(aws.StringValue(current.FunctionArn) + ":" + aws.StringValue(version.Version))
+	return &version, nil
+}
+
+func (svc *recordingLambda) CreateAlias(input *lambda.CreateAliasInput) (*lambda.AliasConfiguration, error) {
+	svc.recorder.record("lambda", "CreateAlias", input)
+	return &lambda.AliasConfiguration{
+		Name:            input.Name,
+		FunctionVersion: input.FunctionVersion,
+		AliasArn:        aws.String(svc.recorder.arn("lambda", fmt.Sprintf("function:%s:%s", aws.StringValue(input.FunctionName), aws.StringValue(input.Name)))),
+	}, nil
+}
+
+func (svc *recordingLambda) UpdateAlias(input *lambda.UpdateAliasInput) (*lambda.AliasConfiguration, error) {
+	svc.recorder.record("lambda", "UpdateAlias", input)
+	return &lambda.AliasConfiguration{
+		Name:            input.Name,
+		FunctionVersion: input.FunctionVersion,
+		AliasArn:        aws.String(svc.recorder.arn("lambda", fmt.Sprintf("function:%s:%s", aws.StringValue(input.FunctionName), aws.StringValue(input.Name)))),
+	}, nil
+}
+
+func (svc *recordingLambda) DeleteAlias(input *lambda.DeleteAliasInput) (*lambda.DeleteAliasOutput, error) {
+	svc.recorder.record("lambda", "DeleteAlias", input)
+	return &lambda.DeleteAliasOutput{}, nil
+}
+
+func (svc *recordingLambda) PutFunctionConcurrency(input *lambda.PutFunctionConcurrencyInput) (*lambda.PutFunctionConcurrencyOutput, error) {
+	svc.recorder.record("lambda", "PutFunctionConcurrency", input)
+	return &lambda.PutFunctionConcurrencyOutput{ReservedConcurrentExecutions: input.ReservedConcurrentExecutions}, nil
+}
+
+func (svc *recordingLambda) TagResource(input *lambda.TagResourceInput) (*lambda.TagResourceOutput, error) {
+	svc.recorder.record("lambda", "TagResource", input)
+	return &lambda.TagResourceOutput{}, nil
+}
+
+func (svc *recordingLambda) AddPermission(input *lambda.AddPermissionInput) (*lambda.AddPermissionOutput, error) {
+	svc.recorder.record("lambda", "AddPermission", input)
+	return &lambda.AddPermissionOutput{}, nil
+}
+
+func (svc *recordingLambda) RemovePermission(input *lambda.RemovePermissionInput) (*lambda.RemovePermissionOutput, error) {
+	svc.recorder.record("lambda", "RemovePermission", input)
+	return &lambda.RemovePermissionOutput{}, nil
+}
+
+func (svc *recordingLambda) DeleteFunction(input *lambda.DeleteFunctionInput) (*lambda.DeleteFunctionOutput, error) {
+	svc.recorder.record("lambda", "DeleteFunction", input)
+	return &lambda.DeleteFunctionOutput{}, nil
+}
+
+type recordingIAM struct {
+	iamiface.IAMAPI
+	recorder *Recorder
+}
+
+// GetRole returns roles that were only recorded
+func (svc *recordingIAM) GetRole(input *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	if role, ok := svc.recorder.roles[aws.StringValue(input.RoleName)]; ok {
+		return &iam.GetRoleOutput{Role: role}, nil
+	}
+	return svc.IAMAPI.GetRole(input)
+}
+
+func (svc *recordingIAM) CreateRole(input *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	svc.recorder.record("iam", "CreateRole", input)
+	role := &iam.Role{
+		RoleName: input.RoleName,
+		RoleId:   aws.String(svc.recorder.newID()),
+		Arn:      aws.String(fmt.Sprintf("arn:aws:iam::%s:role/%s", dryRunAccount, aws.StringValue(input.RoleName))),
+	}
+	svc.recorder.roles[aws.StringValue(input.RoleName)] = role
+	return &iam.CreateRoleOutput{Role: role}, nil
+}
+
+func (svc *recordingIAM) PutRolePolicy(input *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	svc.recorder.record("iam", "PutRolePolicy", input)
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+type recordingEvents struct {
+	cloudwatcheventsiface.CloudWatchEventsAPI
+	recorder *Recorder
+}
+
+func (svc *recordingEvents) PutRule(input *cloudwatchevents.PutRuleInput) (*cloudwatchevents.PutRuleOutput, error) {
+	svc.recorder.record("events", "PutRule", input)
+	return &cloudwatchevents.PutRuleOutput{
+		RuleArn: aws.String(svc.recorder.arn("events", "rule/"+aws.StringValue(input.Name))),
+	}, nil
+}
+
+func (svc *recordingEvents) PutTargets(input *cloudwatchevents.PutTargetsInput) (*cloudwatchevents.PutTargetsOutput, error) {
+	svc.recorder.record("events", "PutTargets", input)
+	return &cloudwatchevents.PutTargetsOutput{FailedEntryCount: aws.Int64(0)}, nil
+}
+
+func (svc *recordingEvents) RemoveTargets(input *cloudwatchevents.RemoveTargetsInput) (*cloudwatchevents.RemoveTargetsOutput, error) {
+	svc.recorder.record("events", "RemoveTargets", input)
+	return &cloudwatchevents.RemoveTargetsOutput{FailedEntryCount: aws.Int64(0)}, nil
+}
+
+func (svc *recordingEvents) DeleteRule(input *cloudwatchevents.DeleteRuleInput) (*cloudwatchevents.DeleteRuleOutput, error) {
+	svc.recorder.record("events", "DeleteRule", input)
+	return &cloudwatchevents.DeleteRuleOutput{}, nil
+}
+
+type recordingS3 struct {
+	s3iface.S3API
+	recorder *Recorder
+}
+
+func (svc *recordingS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	// Don't keep the contents of the object around
+	recorded := *input
+	recorded.Body = nil
+	svc.recorder.record("s3", "PutObject", &recorded)
+	return &s3.PutObjectOutput{VersionId: aws.String(svc.recorder.newID())}, nil
+}
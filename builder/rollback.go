@@ -0,0 +1,281 @@
+package builder
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// undoAction reverts a single change made by the GatewayBuilder
+type undoAction struct {
+	description string
+	undo        func() error
+}
+
+// registerUndo adds an action to the list of things that need to be undone
+// when the build fails
+func (builder *GatewayBuilder) registerUndo(description string, undo func() error) {
+	builder.undo = append(builder.undo, undoAction{description: description, undo: undo})
+}
+
+// Rollback reverts everything the GatewayBuilder has created, in the reverse
+// order of creation. It returns a description of every action that was taken
+// and the errors for those that failed.
+func (builder *GatewayBuilder) Rollback() ([]string, []error) {
+	var done []string
+	var errs []error
+	for i := len(builder.undo) - 1; i >= 0; i-- {
+		action := builder.undo[i]
+		if err := action.undo(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", action.description, err.Error()))
+			continue
+		}
+		done = append(done, action.description)
+	}
+	builder.undo = nil
+	return done, errs
+}
+
+func (builder *GatewayBuilder) undoRestAPI(api *apigateway.RestApi) {
+	builder.registerUndo(fmt.Sprintf("Delete API %s", aws.StringValue(api.Id)), func() error {
+		_, err := builder.Clients.APIGateway.DeleteRestApi(&apigateway.DeleteRestApiInput{
+			RestApiId: api.Id,
+		})
+		return err
+	})
+}
+
+func (builder *GatewayBuilder) undoResource(resource *apigateway.Resource) {
+	builder.registerUndo(fmt.Sprintf("Delete resource %s", aws.StringValue(resource.Id)), func() error {
+		_, err := builder.Clients.APIGateway.DeleteResource(&apigateway.DeleteResourceInput{
+			ResourceId: resource.Id,
+			RestApiId:  builder.APIGateway.Id,
+		})
+		return err
+	})
+}
+
+// undoResourceRemoval recreates a removed resource below its parent, with
+// the methods it had
+func (builder *GatewayBuilder) undoResourceRemoval(resource *apigateway.Resource, methods []*apigateway.Method) {
+	builder.registerUndo(fmt.Sprintf("Restore resource %s", aws.StringValue(resource.Path)), func() error {
+		restored, err := builder.Clients.APIGateway.CreateResource(&apigateway.CreateResourceInput{
+			ParentId:  resource.ParentId,
+			PathPart:  resource.PathPart,
+			RestApiId: builder.APIGateway.Id,
+		})
+		if err != nil {
+			return err
+		}
+		for _, method := range methods {
+			if err = builder.restoreMethod(restored.Id, method); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// undoMethodRemoval puts back a method that was removed from a reused
+// resource, replacing whatever was configured in its place
+func (builder *GatewayBuilder) undoMethodRemoval(resourceID *string, method *apigateway.Method) {
+	builder.registerUndo(fmt.Sprintf("Restore method %s on resource %s",
+		aws.StringValue(method.HttpMethod), aws.StringValue(resourceID)), func() error {
+		_, err := builder.Clients.APIGateway.DeleteMethod(&apigateway.DeleteMethodInput{
+			HttpMethod: method.HttpMethod,
+			ResourceId: resourceID,
+			RestApiId:  builder.APIGateway.Id,
+		})
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+		return builder.restoreMethod(resourceID, method)
+	})
+}
+
+// restoreMethod recreates the method, its integration, and their responses
+// from the configuration returned by GetMethod
+func (builder *GatewayBuilder) restoreMethod(resourceID *string, method *apigateway.Method) error {
+	svc := builder.Clients.APIGateway
+	_, err := svc.PutMethod(&apigateway.PutMethodInput{
+		HttpMethod:          method.HttpMethod,
+		ResourceId:          resourceID,
+		RestApiId:           builder.APIGateway.Id,
+		AuthorizationType:   method.AuthorizationType,
+		AuthorizerId:        method.AuthorizerId,
+		AuthorizationScopes: method.AuthorizationScopes,
+		ApiKeyRequired:      method.ApiKeyRequired,
+		OperationName:       method.OperationName,
+		RequestModels:       method.RequestModels,
+		RequestParameters:   method.RequestParameters,
+		RequestValidatorId:  method.RequestValidatorId,
+	})
+	if err != nil {
+		return err
+	}
+	for status, response := range method.MethodResponses {
+		_, err = svc.PutMethodResponse(&apigateway.PutMethodResponseInput{
+			HttpMethod:         method.HttpMethod,
+			ResourceId:         resourceID,
+			RestApiId:          builder.APIGateway.Id,
+			StatusCode:         aws.String(status),
+			ResponseModels:     response.ResponseModels,
+			ResponseParameters: response.ResponseParameters,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	integration := method.MethodIntegration
+	if integration == nil {
+		return nil
+	}
+	_, err = svc.PutIntegration(&apigateway.PutIntegrationInput{
+		HttpMethod:            method.HttpMethod,
+		ResourceId:            resourceID,
+		RestApiId:             builder.APIGateway.Id,
+		Type:                  integration.Type,
+		Uri:                   integration.Uri,
+		IntegrationHttpMethod: integration.HttpMethod,
+		Credentials:           integration.Credentials,
+		RequestParameters:     integration.RequestParameters,
+		RequestTemplates:      integration.RequestTemplates,
+		PassthroughBehavior:   integration.PassthroughBehavior,
+		ContentHandling:       integration.ContentHandling,
+		CacheKeyParameters:    integration.CacheKeyParameters,
+		CacheNamespace:        integration.CacheNamespace,
+		ConnectionId:          integration.ConnectionId,
+		ConnectionType:        integration.ConnectionType,
+		TimeoutInMillis:       integration.TimeoutInMillis,
+	})
+	if err != nil {
+		return err
+	}
+	for status, response := range integration.IntegrationResponses {
+		_, err = svc.PutIntegrationResponse(&apigateway.PutIntegrationResponseInput{
+			HttpMethod:         method.HttpMethod,
+			ResourceId:         resourceID,
+			RestApiId:          builder.APIGateway.Id,
+			StatusCode:         aws.String(status),
+			SelectionPattern:   response.SelectionPattern,
+			ResponseParameters: response.ResponseParameters,
+			ResponseTemplates:  response.ResponseTemplates,
+			ContentHandling:    response.ContentHandling,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// undoDeployment points the stage back at the deployment it served before,
+// with the stage variables it had, or removes the stage if it didn't exist.
+// This doesn't depend on the order in which the methods are restored.
+func (builder *GatewayBuilder) undoDeployment(previous *apigateway.Stage) {
+	stage := aws.String(builder.StageName())
+	if previous == nil {
+		builder.registerUndo(fmt.Sprintf("Delete stage %s", aws.StringValue(stage)), func() error {
+			_, err := builder.Clients.APIGateway.DeleteStage(&apigateway.DeleteStageInput{
+				RestApiId: builder.APIGateway.Id,
+				StageName: stage,
+			})
+			if isNotFound(err) {
+				return nil
+			}
+			return err
+		})
+		return
+	}
+	builder.registerUndo(fmt.Sprintf("Restore deployment %s of stage %s",
+		aws.StringValue(previous.DeploymentId), aws.StringValue(stage)), func() error {
+		svc := builder.Clients.APIGateway
+		current, err := svc.GetStage(&apigateway.GetStageInput{
+			RestApiId: builder.APIGateway.Id,
+			StageName: stage,
+		})
+		if err != nil {
+			return err
+		}
+		operations := []*apigateway.PatchOperation{{
+			Op:    aws.String("replace"),
+			Path:  aws.String("/deploymentId"),
+			Value: previous.DeploymentId,
+		}}
+		for key := range current.Variables {
+			if _, ok := previous.Variables[key]; !ok {
+				operations = append(operations, &apigateway.PatchOperation{
+					Op:   aws.String("remove"),
+					Path: aws.String("/variables/" + key),
+				})
+			}
+		}
+		for key, value := range previous.Variables {
+			operations = append(operations, &apigateway.PatchOperation{
+				Op:    aws.String("replace"),
+				Path:  aws.String("/variables/" + key),
+				Value: value,
+			})
+		}
+		_, err = svc.UpdateStage(&apigateway.UpdateStageInput{
+			RestApiId:       builder.APIGateway.Id,
+			StageName:       stage,
+			PatchOperations: operations,
+		})
+		return err
+	})
+}
+
+func (builder *GatewayBuilder) undoPermission(function *string, statementID *string, qualifier *string) {
+	builder.registerUndo(fmt.Sprintf("Remove permission %s", aws.StringValue(statementID)), func() error {
+		_, err := builder.Clients.Lambda.RemovePermission(&lambda.RemovePermissionInput{
+			FunctionName: function,
+			StatementId:  statementID,
+			Qualifier:    qualifier,
+		})
+		return err
+	})
+}
+
+func (builder *GatewayBuilder) undoAuthorizer(authorizer *apigateway.Authorizer) {
+	builder.registerUndo(fmt.Sprintf("Delete authorizer %s", aws.StringValue(authorizer.Id)), func() error {
+		_, err := builder.Clients.APIGateway.DeleteAuthorizer(&apigateway.DeleteAuthorizerInput{
+			AuthorizerId: authorizer.Id,
+			RestApiId:    builder.APIGateway.Id,
+		})
+		return err
+	})
+}
+
+func (builder *GatewayBuilder) undoAlias(alias *lambda.AliasConfiguration) {
+	builder.registerUndo(fmt.Sprintf("Delete alias %s", aws.StringValue(alias.Name)), func() error {
+		_, err := builder.Clients.Lambda.DeleteAlias(&lambda.DeleteAliasInput{
+			FunctionName: builder.Settings.FunctionName,
+			Name:         alias.Name,
+		})
+		return err
+	})
+}
+
+func (builder *GatewayBuilder) undoAliasUpdate(previous *lambda.AliasConfiguration) {
+	builder.registerUndo(fmt.Sprintf("Move alias %s back to version %s",
+		aws.StringValue(previous.Name), aws.StringValue(previous.FunctionVersion)), func() error {
+		_, err := builder.Clients.Lambda.UpdateAlias(&lambda.UpdateAliasInput{
+			FunctionName:    builder.Settings.FunctionName,
+			Name:            previous.Name,
+			FunctionVersion: previous.FunctionVersion,
+		})
+		return err
+	})
+}
+
+func (builder *GatewayBuilder) undoLambdaFunction(function *lambda.FunctionConfiguration) {
+	builder.registerUndo(fmt.Sprintf("Delete Lambda function %s", aws.StringValue(function.FunctionName)), func() error {
+		_, err := builder.Clients.Lambda.DeleteFunction(&lambda.DeleteFunctionInput{
+			FunctionName: function.FunctionName,
+		})
+		return err
+	})
+}
@@ -0,0 +1,68 @@
+package builder
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3Location is the location of a deployment package in S3
+type S3Location struct {
+	Bucket  string
+	Key     string
+	Version string
+}
+
+// ParseS3Path turns an s3://bucket/key?versionId=version path into an
+// S3Location. The version is optional.
+func ParseS3Path(path string) (*S3Location, error) {
+	parsed, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	location := &S3Location{
+		Bucket:  parsed.Host,
+		Key:     strings.TrimPrefix(parsed.Path, "/"),
+		Version: parsed.Query().Get("versionId"),
+	}
+	if parsed.Scheme != "s3" || location.Bucket == "" || location.Key == "" {
+		return nil, fmt.Errorf("%s is not an S3 path in the format s3://bucket/key", path)
+	}
+	return location, nil
+}
+
+func (location *S3Location) String() string {
+	path := fmt.Sprintf("s3://%s/%s", location.Bucket, location.Key)
+	if location.Version != "" {
+		path += "?versionId=" + url.QueryEscape(location.Version)
+	}
+	return path
+}
+
+// stagePackage uploads the package to the bucket, so Lambda can get packages
+// that are too large to send directly from there
+func stagePackage(clients *Clients, bucket string, functionName string, pkg *Package) error {
+	hash := sha256.Sum256(pkg.Data)
+	location := &S3Location{
+		Bucket: bucket,
+		Key:    fmt.Sprintf("aqua/%s/%s.zip", functionName, hex.EncodeToString(hash[:])),
+	}
+	resp, err := clients.S3.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(location.Bucket),
+		Key:    aws.String(location.Key),
+		Body:   bytes.NewReader(pkg.Data),
+	})
+	if err != nil {
+		return err
+	}
+	// Use exactly this upload if the bucket is versioned
+	location.Version = aws.StringValue(resp.VersionId)
+	pkg.Location = location
+	return nil
+}
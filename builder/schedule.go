@@ -0,0 +1,363 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed CloudWatch Events schedule expression
+type Schedule struct {
+	Expression string
+	rate       time.Duration
+	cron       *cronSchedule
+}
+
+// cronSchedule contains the values each field of a cron expression allows.
+// The days are either selected by the day of the month or the day of the
+// week, the other one is ?.
+type cronSchedule struct {
+	minutes    []bool
+	hours      []bool
+	days       []bool
+	lastDay    bool
+	nearestTo  int
+	months     []bool
+	weekdays   []bool
+	lastOf     int
+	nthOf      int
+	nth        int
+	years      []bool
+	byWeekdays bool
+}
+
+// The first and last year a cron expression can select
+const (
+	firstYear = 1970
+	lastYear  = 2199
+)
+
+var rateExpression = regexp.MustCompile(`^rate\((.*)\)$`)
+
+var cronExpression = regexp.MustCompile(`^cron\((.*)\)$`)
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+// Days of the week are numbered from 1 for Sunday to 7 for Saturday
+var weekdayNames = map[string]int{
+	"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7,
+}
+
+// ValidateSchedule checks that the schedule is a rate or cron expression
+// that CloudWatch Events accepts
+func ValidateSchedule(expression string) error {
+	_, err := ParseSchedule(expression)
+	return err
+}
+
+// ParseSchedule parses a rate(value unit) or cron(minutes hours
+// day-of-month month day-of-week year) expression
+func ParseSchedule(expression string) (*Schedule, error) {
+	if parts := rateExpression.FindStringSubmatch(expression); parts != nil {
+		rate, err := parseRate(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid rate expression, %s", expression, err.Error())
+		}
+		return &Schedule{Expression: expression, rate: rate}, nil
+	}
+	if parts := cronExpression.FindStringSubmatch(expression); parts != nil {
+		cron, err := parseCron(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid cron expression, %s", expression, err.Error())
+		}
+		return &Schedule{Expression: expression, cron: cron}, nil
+	}
+	return nil, fmt.Errorf("%s is not a valid schedule, please use a rate(...) or cron(...) expression", expression)
+}
+
+// parseRate returns the interval of a rate expression
+func parseRate(rate string) (time.Duration, error) {
+	parts := strings.Fields(rate)
+	if len(parts) != 2 {
+		return 0, errors.New("please use rate(value unit)")
+	}
+	value, err := strconv.Atoi(parts[0])
+	if err != nil || value < 1 {
+		return 0, fmt.Errorf("the value has to be a positive whole number, not %s", parts[0])
+	}
+	units := map[string]time.Duration{"minute": time.Minute, "hour": time.Hour, "day": 24 * time.Hour}
+	unit := strings.TrimSuffix(parts[1], "s")
+	duration, ok := units[unit]
+	if !ok {
+		return 0, fmt.Errorf("%s is not a valid unit, please use minutes, hours, or days", parts[1])
+	}
+	// A value of 1 requires the singular unit, and other values the plural
+	if value == 1 && parts[1] != unit {
+		return 0, fmt.Errorf("a value of 1 needs the singular unit %s", unit)
+	}
+	if value != 1 && parts[1] == unit {
+		return 0, fmt.Errorf("a value of %d needs the plural unit %ss", value, unit)
+	}
+	return time.Duration(value) * duration, nil
+}
+
+// parseCron parses the six fields of a cron expression
+func parseCron(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("it needs 6 fields (minutes, hours, day-of-month, month, day-of-week, year) instead of %d", len(fields))
+	}
+	cron := &cronSchedule{}
+	var err error
+	if cron.minutes, err = parseField(fields[0], "minutes", 0, 59, nil, false); err != nil {
+		return nil, err
+	}
+	if cron.hours, err = parseField(fields[1], "hours", 0, 23, nil, false); err != nil {
+		return nil, err
+	}
+	if cron.months, err = parseField(fields[3], "month", 1, 12, monthNames, false); err != nil {
+		return nil, err
+	}
+	if cron.years, err = parseField(fields[5], "year", firstYear, lastYear, nil, false); err != nil {
+		return nil, err
+	}
+	switch {
+	case fields[2] == "?" && fields[4] == "?":
+		return nil, errors.New("only one of the day-of-month and day-of-week fields can be ?")
+	case fields[2] != "?" && fields[4] != "?":
+		return nil, errors.New("one of the day-of-month and day-of-week fields has to be ?")
+	case fields[2] != "?":
+		err = cron.parseDays(fields[2])
+	default:
+		cron.byWeekdays = true
+		err = cron.parseWeekdays(fields[4])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cron, nil
+}
+
+// parseDays parses the day-of-month field, which besides the usual values
+// can be L for the last day of the month or nW for the weekday nearest to
+// day n
+func (cron *cronSchedule) parseDays(field string) error {
+	switch {
+	case field == "L":
+		cron.lastDay = true
+		return nil
+	case strings.HasSuffix(field, "W"):
+		day, err := strconv.Atoi(strings.TrimSuffix(field, "W"))
+		if err != nil || day < 1 || day > 31 {
+			return fmt.Errorf("the day-of-month field %s needs a day from 1 to 31 before W", field)
+		}
+		cron.nearestTo = day
+		return nil
+	}
+	var err error
+	cron.days, err = parseField(field, "day-of-month", 1, 31, nil, false)
+	return err
+}
+
+// parseWeekdays parses the day-of-week field, which besides the usual values
+// can be L for the last day of the week, nL for the last day n of the month,
+// or n#k for the kth day n of the month
+func (cron *cronSchedule) parseWeekdays(field string) error {
+	weekday := func(value string) (int, error) {
+		day, err := parseValue(value, 1, 7, weekdayNames)
+		if err != nil {
+			return 0, fmt.Errorf("the day-of-week field %s", err.Error())
+		}
+		return day, nil
+	}
+	var err error
+	switch {
+	case field == "L":
+		cron.weekdays = make([]bool, 8)
+		cron.weekdays[7] = true
+	case strings.HasSuffix(field, "L"):
+		cron.lastOf, err = weekday(strings.TrimSuffix(field, "L"))
+	case strings.Contains(field, "#"):
+		parts := strings.SplitN(field, "#", 2)
+		if cron.nthOf, err = weekday(parts[0]); err != nil {
+			return err
+		}
+		cron.nth, err = strconv.Atoi(parts[1])
+		if err != nil || cron.nth < 1 || cron.nth > 5 {
+			return fmt.Errorf("the day-of-week field %s needs a number from 1 to 5 after #", field)
+		}
+	default:
+		cron.weekdays, err = parseField(field, "day-of-week", 1, 7, weekdayNames, true)
+	}
+	return err
+}
+
+// parseField returns which values from min to max the field selects. A field
+// is a comma separated list of *, single values, and ranges, each optionally
+// followed by /step. If the field wraps, a range can end before it starts and
+// continues from min after max, like FRI-MON for the days of the week.
+func parseField(field string, name string, min int, max int, names map[string]int, wraps bool) ([]bool, error) {
+	selected := make([]bool, max+1)
+	for _, item := range strings.Split(field, ",") {
+		start, end, step := min, max, 1
+		rangePart := item
+		if parts := strings.SplitN(item, "/", 2); len(parts) == 2 {
+			rangePart = parts[0]
+			var err error
+			step, err = strconv.Atoi(parts[1])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("the %s field %s needs a positive step after /", name, item)
+			}
+		}
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = parseValue(bounds[0], min, max, names); err != nil {
+				return nil, fmt.Errorf("the %s field %s", name, err.Error())
+			}
+			end = start
+			if len(bounds) == 2 {
+				if end, err = parseValue(bounds[1], min, max, names); err != nil {
+					return nil, fmt.Errorf("the %s field %s", name, err.Error())
+				}
+				if end < start && !wraps {
+					return nil, fmt.Errorf("the %s field %s has a range that ends before it starts", name, item)
+				}
+			} else if rangePart != item {
+				// A single value with a step repeats until the maximum
+				end = max
+			}
+		}
+		length := end - start
+		if length < 0 {
+			length += max - min + 1
+		}
+		for offset := 0; offset <= length; offset += step {
+			value := start + offset
+			if value > max {
+				value -= max - min + 1
+			}
+			selected[value] = true
+		}
+	}
+	return selected, nil
+}
+
+// parseValue returns a single number or name of a field
+func parseValue(value string, min int, max int, names map[string]int) (int, error) {
+	if number, ok := names[strings.ToUpper(value)]; ok {
+		return number, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("has %q, which is not a number", value)
+	}
+	if number < min || number > max {
+		return 0, fmt.Errorf("has %d, which is not between %d and %d", number, min, max)
+	}
+	return number, nil
+}
+
+// Next returns the first time after the provided time the schedule runs, in
+// UTC. Rate expressions run every interval starting from the provided time.
+// It returns false if the schedule doesn't run anymore.
+func (schedule *Schedule) Next(after time.Time) (time.Time, bool) {
+	after = after.UTC()
+	if schedule.cron == nil {
+		return after.Add(schedule.rate), true
+	}
+	return schedule.cron.next(after)
+}
+
+// Upcoming returns the next count times the schedule runs after the provided
+// time
+func (schedule *Schedule) Upcoming(after time.Time, count int) []time.Time {
+	var times []time.Time
+	for len(times) < count {
+		next, ok := schedule.Next(after)
+		if !ok {
+			break
+		}
+		times = append(times, next)
+		after = next
+	}
+	return times
+}
+
+// next walks through the days after the provided time, and returns the first
+// minute on a matching day
+func (cron *cronSchedule) next(after time.Time) (time.Time, bool) {
+	start := after.Truncate(time.Minute).Add(time.Minute)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	for ; day.Year() <= lastYear; day = day.AddDate(0, 0, 1) {
+		if !cron.years[day.Year()] || !cron.months[day.Month()] {
+			// Skip to the first day of the next month
+			day = time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !cron.matchesDay(day) {
+			continue
+		}
+		for hour := 0; hour < 24; hour++ {
+			for minute := 0; minute < 60; minute++ {
+				if !cron.hours[hour] || !cron.minutes[minute] {
+					continue
+				}
+				if candidate := day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute); !candidate.Before(start) {
+					return candidate, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// matchesDay checks if the schedule runs on the day
+func (cron *cronSchedule) matchesDay(day time.Time) bool {
+	lastOfMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	weekday := int(day.Weekday()) + 1
+	switch {
+	case cron.byWeekdays && cron.lastOf > 0:
+		return weekday == cron.lastOf && day.Day()+7 > lastOfMonth
+	case cron.byWeekdays && cron.nthOf > 0:
+		return weekday == cron.nthOf && (day.Day()-1)/7+1 == cron.nth
+	case cron.byWeekdays:
+		return cron.weekdays[weekday]
+	case cron.lastDay:
+		return day.Day() == lastOfMonth
+	case cron.nearestTo > 0:
+		return day.Day() == nearestWeekday(day.Year(), day.Month(), cron.nearestTo)
+	default:
+		return cron.days[day.Day()]
+	}
+}
+
+// nearestWeekday returns the weekday closest to the day of the month,
+// without moving into another month. Days that don't exist in the month are
+// never selected.
+func nearestWeekday(year int, month time.Month, day int) int {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if date.Month() != month {
+		return 0
+	}
+	lastOfMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	switch date.Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return 3
+		}
+		return day - 1
+	case time.Sunday:
+		if day == lastOfMonth {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
@@ -0,0 +1,180 @@
+package builder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleUpcoming(t *testing.T) {
+	// A Wednesday
+	start := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		expression string
+		after      time.Time
+		count      int
+		expected   []string
+	}{
+		"rate": {
+			expression: "rate(5 minutes)",
+			expected:   []string{"2025-01-01T00:05", "2025-01-01T00:10", "2025-01-01T00:15"},
+		},
+		"every day": {
+			expression: "cron(0 12 * * ? *)",
+			expected:   []string{"2025-01-01T12:00", "2025-01-02T12:00", "2025-01-03T12:00"},
+		},
+		"step": {
+			expression: "cron(0/15 * * * ? *)",
+			expected:   []string{"2025-01-01T00:15", "2025-01-01T00:30", "2025-01-01T00:45"},
+		},
+		"range with step": {
+			expression: "cron(0 8-12/2 * * ? *)",
+			count:      4,
+			expected:   []string{"2025-01-01T08:00", "2025-01-01T10:00", "2025-01-01T12:00", "2025-01-02T08:00"},
+		},
+		"list": {
+			expression: "cron(30 9 1,15 * ? *)",
+			expected:   []string{"2025-01-01T09:30", "2025-01-15T09:30", "2025-02-01T09:30"},
+		},
+		"last day of the month": {
+			expression: "cron(0 0 L * ? *)",
+			expected:   []string{"2025-01-31T00:00", "2025-02-28T00:00", "2025-03-31T00:00"},
+		},
+		"nearest weekday": {
+			// February and March 2025 start on a Saturday
+			expression: "cron(0 0 1W * ? *)",
+			expected:   []string{"2025-02-03T00:00", "2025-03-03T00:00", "2025-04-01T00:00"},
+		},
+		"nearest weekday at the end of the month": {
+			// August 31 2025 is a Sunday
+			expression: "cron(0 0 31W 8 ? *)",
+			count:      1,
+			expected:   []string{"2025-08-29T00:00"},
+		},
+		"nth weekday": {
+			expression: "cron(0 0 ? * MON#1 *)",
+			expected:   []string{"2025-01-06T00:00", "2025-02-03T00:00", "2025-03-03T00:00"},
+		},
+		"last weekday of the month": {
+			expression: "cron(0 0 ? * 6L *)",
+			expected:   []string{"2025-01-31T00:00", "2025-02-28T00:00", "2025-03-28T00:00"},
+		},
+		"last day of the week": {
+			expression: "cron(0 0 ? * L *)",
+			expected:   []string{"2025-01-04T00:00", "2025-01-11T00:00", "2025-01-18T00:00"},
+		},
+		"weekdays": {
+			expression: "cron(0 12 ? * MON-FRI *)",
+			expected:   []string{"2025-01-01T12:00", "2025-01-02T12:00", "2025-01-03T12:00"},
+		},
+		"wrapping weekdays": {
+			expression: "cron(0 0 ? * FRI-MON *)",
+			count:      5,
+			expected:   []string{"2025-01-03T00:00", "2025-01-04T00:00", "2025-01-05T00:00", "2025-01-06T00:00", "2025-01-10T00:00"},
+		},
+		"wrapping weekday numbers": {
+			expression: "cron(0 0 ? * 7-1 *)",
+			expected:   []string{"2025-01-04T00:00", "2025-01-05T00:00", "2025-01-11T00:00"},
+		},
+		"wrapping weekdays with step": {
+			expression: "cron(0 0 ? * THU-TUE/2 *)",
+			expected:   []string{"2025-01-02T00:00", "2025-01-04T00:00", "2025-01-06T00:00"},
+		},
+		"month names": {
+			expression: "cron(0 0 1 JAN,JUL ? *)",
+			expected:   []string{"2025-07-01T00:00", "2026-01-01T00:00", "2026-07-01T00:00"},
+		},
+		"lower case names": {
+			expression: "cron(0 0 ? jan sun#1 *)",
+			count:      2,
+			expected:   []string{"2025-01-05T00:00", "2026-01-04T00:00"},
+		},
+		"years": {
+			expression: "cron(0 0 1 1 ? 2026-2027)",
+			expected:   []string{"2026-01-01T00:00", "2027-01-01T00:00"},
+		},
+		"last year": {
+			expression: "cron(0 0 1 1 ? *)",
+			after:      time.Date(2198, time.June, 1, 0, 0, 0, 0, time.UTC),
+			expected:   []string{"2199-01-01T00:00"},
+		},
+		"past year": {
+			expression: "cron(0 0 1 1 ? 2020)",
+		},
+		"february 31": {
+			expression: "cron(0 0 31 2 ? *)",
+		},
+		"february 30 nearest weekday": {
+			expression: "cron(0 0 30W 2 ? *)",
+		},
+		"april 31": {
+			expression: "cron(0 0 31 4 ? 2025-2030)",
+		},
+		"fifth monday of a short february": {
+			expression: "cron(0 0 ? 2 MON#5 2025-2027)",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			schedule, err := ParseSchedule(test.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after := test.after
+			if after.IsZero() {
+				after = start
+			}
+			count := test.count
+			if count == 0 {
+				count = 3
+			}
+			var got []string
+			for _, runTime := range schedule.Upcoming(after, count) {
+				got = append(got, runTime.Format("2006-01-02T15:04"))
+			}
+			if len(got) != len(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, got)
+			}
+			for index := range got {
+				if got[index] != test.expected[index] {
+					t.Errorf("expected %v, got %v", test.expected, got)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	tests := map[string]string{
+		"not a schedule":         "every day",
+		"rate without unit":      "rate(5)",
+		"rate of zero":           "rate(0 minutes)",
+		"rate with plural of 1":  "rate(1 minutes)",
+		"rate with singular":     "rate(5 minute)",
+		"rate in seconds":        "rate(5 seconds)",
+		"five fields":            "cron(0 12 * * ?)",
+		"both days":              "cron(0 12 * * * *)",
+		"neither day":            "cron(0 12 ? * ? *)",
+		"minute out of range":    "cron(60 12 * * ? *)",
+		"hour out of range":      "cron(0 24 * * ? *)",
+		"day out of range":       "cron(0 0 32 * ? *)",
+		"month out of range":     "cron(0 0 1 13 ? *)",
+		"unknown month":          "cron(0 0 1 JANUARY ? *)",
+		"weekday out of range":   "cron(0 0 ? * 8 *)",
+		"year before range":      "cron(0 0 1 1 ? 1969)",
+		"year after range":       "cron(0 0 1 1 ? 2200)",
+		"backwards hours":        "cron(0 17-9 * * ? *)",
+		"backwards months":       "cron(0 0 1 DEC-FEB ? *)",
+		"zero step":              "cron(0/0 * * * ? *)",
+		"nearest weekday of 32":  "cron(0 0 32W * ? *)",
+		"sixth weekday":          "cron(0 0 ? * MON#6 *)",
+		"unknown weekday before": "cron(0 0 ? * XL *)",
+	}
+	for name, expression := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidateSchedule(expression); err == nil {
+				t.Errorf("%s was accepted", expression)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package builder
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+// StageSettings contains the settings of a stage. Empty values are left
+// alone.
+type StageSettings struct {
+	Description string
+	Variables   map[string]*string
+	// RateLimit is the number of requests per second allowed for every method
+	RateLimit float64
+	// BurstLimit is the number of concurrent requests allowed for every method
+	BurstLimit int64
+}
+
+// StageVariables turns a list of KEY=VALUE strings into stage variables
+func StageVariables(pairs []string) (map[string]*string, error) {
+	return keyValues(pairs, "stage variable")
+}
+
+// FindAPI returns the API with the API ID in the settings, or the API Aqua
+// created for the function
+func FindAPI(clients *Clients, settings *Config) (*apigateway.RestApi, error) {
+	builder := GatewayBuilder{Settings: settings, Clients: clients}
+	api, err := builder.findAPIGateway()
+	if err != nil {
+		return nil, err
+	}
+	if api == nil {
+		return nil, fmt.Errorf("There is no API for %s, please provide its API ID with --api-id",
+			aws.StringValue(settings.FunctionName))
+	}
+	return api, nil
+}
+
+// ListStages returns the stages of the API, sorted by name
+func ListStages(clients *Clients, apiID *string) ([]*apigateway.Stage, error) {
+	resp, err := clients.APIGateway.GetStages(&apigateway.GetStagesInput{
+		RestApiId: apiID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	stages := resp.Item
+	sort.Slice(stages, func(i, j int) bool {
+		return aws.StringValue(stages[i].StageName) < aws.StringValue(stages[j].StageName)
+	})
+	return stages, nil
+}
+
+// StageURL returns the URL of the stage of the API
+func StageURL(apiID string, region string, stage string) string {
+	return fmt.Sprintf("https://%s.execute-api.%s.amazonaws.com/%s", apiID, region, stage)
+}
+
+// CreateStage deploys the API to a stage that doesn't exist yet
+func CreateStage(clients *Clients, apiID *string, stage string, settings StageSettings) (*apigateway.Stage, error) {
+	_, err := clients.APIGateway.GetStage(&apigateway.GetStageInput{
+		RestApiId: apiID,
+		StageName: aws.String(stage),
+	})
+	if err == nil {
+		return nil, fmt.Errorf("The stage %s already exists, please use aqua stage redeploy to update it", stage)
+	}
+	if !isNotFound(err) {
+		return nil, err
+	}
+	return deployStage(clients, apiID, stage, settings)
+}
+
+// RedeployStage deploys the current state of the API to an existing stage
+func RedeployStage(clients *Clients, apiID *string, stage string, settings StageSettings) (*apigateway.Stage, error) {
+	_, err := clients.APIGateway.GetStage(&apigateway.GetStageInput{
+		RestApiId: apiID,
+		StageName: aws.String(stage),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deployStage(clients, apiID, stage, settings)
+}
+
+// DeleteStage deletes the stage from the API
+func DeleteStage(clients *Clients, apiID *string, stage string) error {
+	_, err := clients.APIGateway.DeleteStage(&apigateway.DeleteStageInput{
+		RestApiId: apiID,
+		StageName: aws.String(stage),
+	})
+	return err
+}
+
+// deployStage deploys the API to the stage and applies the settings to it
+func deployStage(clients *Clients, apiID *string, stage string, settings StageSettings) (*apigateway.Stage, error) {
+	_, err := clients.APIGateway.CreateDeployment(&apigateway.CreateDeploymentInput{
+		RestApiId: apiID,
+		StageName: aws.String(stage),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updateStage(clients, apiID, stage, settings)
+}
+
+// updateStage applies the settings to the stage
+func updateStage(clients *Clients, apiID *string, stage string, settings StageSettings) (*apigateway.Stage, error) {
+	var operations []*apigateway.PatchOperation
+	replace := func(path string, value string) {
+		operations = append(operations, &apigateway.PatchOperation{
+			Op:    aws.String("replace"),
+			Path:  aws.String(path),
+			Value: aws.String(value),
+		})
+	}
+	if settings.Description != "" {
+		replace("/description", settings.Description)
+	}
+	for key, value := range settings.Variables {
+		replace("/variables/"+key, aws.StringValue(value))
+	}
+	// The throttling of /*/* applies to every method in the stage
+	if settings.RateLimit != 0 {
+		replace("/*/*/throttling/rateLimit", strconv.FormatFloat(settings.RateLimit, 'f', -1, 64))
+	}
+	if settings.BurstLimit != 0 {
+		replace("/*/*/throttling/burstLimit", strconv.FormatInt(settings.BurstLimit, 10))
+	}
+	if len(operations) == 0 {
+		return clients.APIGateway.GetStage(&apigateway.GetStageInput{
+			RestApiId: apiID,
+			StageName: aws.String(stage),
+		})
+	}
+	return clients.APIGateway.UpdateStage(&apigateway.UpdateStageInput{
+		RestApiId:       apiID,
+		StageName:       aws.String(stage),
+		PatchOperations: operations,
+	})
+}
@@ -0,0 +1,185 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+// testAPI builds the Gateway of the test settings and returns its ID
+func testAPI(t *testing.T) (*Clients, *fakeAWS, *string) {
+	t.Helper()
+	clients, account := newFakeClients()
+	account.addRole("lambda-basic")
+	if err := (&GatewayBuilder{Settings: testSettings(), Clients: clients}).Build(); err != nil {
+		t.Fatalf("Build failed: %s", err)
+	}
+	return clients, account, account.api().api.Id
+}
+
+func TestCreateStage(t *testing.T) {
+	tests := map[string]struct {
+		stage      string
+		settings   StageSettings
+		want       *apigateway.Stage
+		throttling *apigateway.MethodSetting
+		patched    bool
+		invalid    bool
+	}{
+		"deploy only": {
+			stage: "dev",
+			want:  &apigateway.Stage{StageName: aws.String("dev")},
+		},
+		"description and variables": {
+			stage: "dev",
+			settings: StageSettings{
+				Description: "Development",
+				Variables:   map[string]*string{"table": aws.String("dev-table")},
+			},
+			want: &apigateway.Stage{
+				StageName:   aws.String("dev"),
+				Description: aws.String("Development"),
+				Variables:   map[string]*string{"table": aws.String("dev-table")},
+			},
+			patched: true,
+		},
+		"throttling": {
+			stage:      "dev",
+			settings:   StageSettings{RateLimit: 2.5, BurstLimit: 5},
+			want:       &apigateway.Stage{StageName: aws.String("dev")},
+			throttling: &apigateway.MethodSetting{ThrottlingRateLimit: aws.Float64(2.5), ThrottlingBurstLimit: aws.Int64(5)},
+			patched:    true,
+		},
+		"existing stage": {
+			stage:   DefaultStage,
+			invalid: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account, apiID := testAPI(t)
+			stage, err := CreateStage(clients, apiID, test.stage, test.settings)
+			if test.invalid {
+				if err == nil {
+					t.Error("CreateStage accepted an existing stage")
+				}
+				if got := account.api().deployments; got != 1 {
+					t.Errorf("got %d deployments, want 1", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateStage failed: %s", err)
+			}
+			if got := account.called("UpdateStage") > 0; got != test.patched {
+				t.Errorf("got stage updated %t, want %t", got, test.patched)
+			}
+			if aws.StringValue(stage.DeploymentId) == "" {
+				t.Error("the stage doesn't have a deployment")
+			}
+			if aws.StringValue(stage.StageName) != aws.StringValue(test.want.StageName) ||
+				aws.StringValue(stage.Description) != aws.StringValue(test.want.Description) ||
+				!reflect.DeepEqual(aws.StringValueMap(stage.Variables), aws.StringValueMap(test.want.Variables)) {
+				t.Errorf("got stage %v, want %v", stage, test.want)
+			}
+			if test.throttling != nil && !reflect.DeepEqual(stage.MethodSettings["*/*"], test.throttling) {
+				t.Errorf("got throttling %v, want %v", stage.MethodSettings["*/*"], test.throttling)
+			}
+		})
+	}
+}
+
+func TestRedeployStage(t *testing.T) {
+	tests := map[string]struct {
+		stage   string
+		want    map[string]string
+		invalid bool
+	}{
+		"existing stage": {stage: DefaultStage, want: map[string]string{"table": "prod-table", "version": "2"}},
+		"missing stage":  {stage: "dev", invalid: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account, apiID := testAPI(t)
+			first, err := updateStage(clients, apiID, DefaultStage, StageSettings{
+				Description: "Production",
+				Variables:   map[string]*string{"table": aws.String("prod-table"), "version": aws.String("1")},
+			})
+			if err != nil {
+				t.Fatalf("updating the stage failed: %s", err)
+			}
+			deployment := aws.StringValue(first.DeploymentId)
+
+			stage, err := RedeployStage(clients, apiID, test.stage, StageSettings{
+				Variables: map[string]*string{"version": aws.String("2")},
+			})
+			if test.invalid {
+				if err == nil {
+					t.Error("RedeployStage accepted a missing stage")
+				}
+				if _, ok := account.api().stages[test.stage]; ok {
+					t.Errorf("RedeployStage created stage %s", test.stage)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RedeployStage failed: %s", err)
+			}
+			if aws.StringValue(stage.DeploymentId) == deployment {
+				t.Error("the stage still uses the earlier deployment")
+			}
+			if got := aws.StringValueMap(stage.Variables); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got variables %v, want %v", got, test.want)
+			}
+			if got := aws.StringValue(stage.Description); got != "Production" {
+				t.Errorf("got description %q, want the earlier Production", got)
+			}
+		})
+	}
+}
+
+func TestDeleteStage(t *testing.T) {
+	clients, account, apiID := testAPI(t)
+	if err := DeleteStage(clients, apiID, DefaultStage); err != nil {
+		t.Fatalf("DeleteStage failed: %s", err)
+	}
+	if len(account.api().stages) != 0 {
+		t.Error("the stage wasn't deleted")
+	}
+	if err := DeleteStage(clients, apiID, DefaultStage); !isNotFound(err) {
+		t.Errorf("got %v deleting a missing stage, want a not found error", err)
+	}
+}
+
+func TestStageVariables(t *testing.T) {
+	tests := map[string]struct {
+		pairs   []string
+		want    map[string]string
+		invalid bool
+	}{
+		"none":         {want: map[string]string{}},
+		"values":       {pairs: []string{"table=users", "url=https://example.com/?a=b"}, want: map[string]string{"table": "users", "url": "https://example.com/?a=b"}},
+		"empty value":  {pairs: []string{"table="}, want: map[string]string{"table": ""}},
+		"missing =":    {pairs: []string{"table"}, invalid: true},
+		"missing name": {pairs: []string{"=users"}, invalid: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			variables, err := StageVariables(test.pairs)
+			if test.invalid {
+				if err == nil {
+					t.Error("StageVariables accepted an invalid variable")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("StageVariables failed: %s", err)
+			}
+			if got := aws.StringValueMap(variables); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
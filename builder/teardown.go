@@ -0,0 +1,436 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/aws/aws-sdk-go/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// TeardownItem is a single resource that Aqua created for a Lambda function
+type TeardownItem struct {
+	Type   string
+	Name   string
+	remove func() error
+}
+
+// Remove deletes the resource
+func (item TeardownItem) Remove() error {
+	return item.remove()
+}
+
+// policyDocument is the part of a Lambda function policy needed to find the
+// permissions Aqua added
+type policyDocument struct {
+	Statement []struct {
+		Sid       string
+		Condition map[string]map[string]string
+	}
+}
+
+// PlanTeardown finds everything Aqua created for the function in the
+// settings, based on the names Aqua gives its resources. The items are
+// returned in the order they need to be removed.
+func PlanTeardown(clients *Clients, settings *Config, keepFunction bool) ([]TeardownItem, error) {
+	var items []TeardownItem
+
+	function, err := clients.Lambda.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{
+		FunctionName: settings.FunctionName,
+	})
+	if err != nil {
+		if !isNotFound(err) {
+			return nil, err
+		}
+		function = nil
+	}
+
+	var permissions []aquaPermission
+	var ruleArns []string
+	if function != nil {
+		permissions, ruleArns, err = aquaPermissions(clients, settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, ruleArn := range ruleArns {
+		items = append(items, scheduleTeardown(clients, function, ruleArn))
+	}
+
+	// Deleting the function also removes its permissions
+	if keepFunction {
+		for _, permission := range permissions {
+			items = append(items, permissionTeardown(clients, settings, permission))
+		}
+	}
+
+	apis, err := teardownAPIs(clients, settings)
+	if err != nil {
+		return nil, err
+	}
+	for _, api := range apis {
+		// The API can only be deleted once nothing refers to it anymore
+		dependencies, err := apiDependencies(clients, api)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, dependencies...)
+		items = append(items, restAPITeardown(clients, api))
+	}
+
+	if function != nil && !keepFunction {
+		items = append(items, TeardownItem{
+			Type: "Lambda function",
+			Name: aws.StringValue(function.FunctionName),
+			remove: func() error {
+				_, err := clients.Lambda.DeleteFunction(&lambda.DeleteFunctionInput{
+					FunctionName: function.FunctionName,
+				})
+				return err
+			},
+		})
+	}
+
+	return items, nil
+}
+
+// teardownAPIs returns the API with the API ID in the settings, or the APIs
+// Aqua created for the function
+func teardownAPIs(clients *Clients, settings *Config) ([]*apigateway.RestApi, error) {
+	if aws.StringValue(settings.APIID) != "" {
+		api, err := clients.APIGateway.GetRestApi(&apigateway.GetRestApiInput{
+			RestApiId: settings.APIID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []*apigateway.RestApi{api}, nil
+	}
+	gateway := GatewayBuilder{Settings: settings}
+	return findRestAPIs(clients, gateway.APIName())
+}
+
+// apiDependencies returns the resources outside of the API that refer to it:
+// the permissions of its Lambda authorizers, the base path mappings of custom
+// domain names, and the stages in usage plans
+func apiDependencies(clients *Clients, api *apigateway.RestApi) ([]TeardownItem, error) {
+	var items []TeardownItem
+	permissions, err := authorizerPermissions(clients, api)
+	if err != nil {
+		return nil, err
+	}
+	items = append(items, permissions...)
+
+	err = clients.APIGateway.GetDomainNamesPages(&apigateway.GetDomainNamesInput{
+		Limit: aws.Int64(500),
+	}, func(page *apigateway.GetDomainNamesOutput, lastPage bool) bool {
+		for _, domain := range page.Items {
+			name := aws.StringValue(domain.DomainName)
+			err = EachBasePathMapping(clients, name, ListOptions{}, func(mapping *apigateway.BasePathMapping) bool {
+				if aws.StringValue(mapping.RestApiId) == aws.StringValue(api.Id) {
+					items = append(items, basePathTeardown(clients, name, aws.StringValue(mapping.BasePath)))
+				}
+				return true
+			})
+			if err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = EachUsagePlan(clients, ListOptions{}, func(plan *apigateway.UsagePlan) bool {
+		for _, stage := range plan.ApiStages {
+			if aws.StringValue(stage.ApiId) == aws.StringValue(api.Id) {
+				items = append(items, planStageTeardown(clients, plan, api.Id, aws.StringValue(stage.Stage)))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// authorizerPermissions returns the permissions Aqua gave the API to invoke
+// the functions of its Lambda authorizers
+func authorizerPermissions(clients *Clients, api *apigateway.RestApi) ([]TeardownItem, error) {
+	var items []TeardownItem
+	params := &apigateway.GetAuthorizersInput{
+		RestApiId: api.Id,
+	}
+	for {
+		resp, err := clients.APIGateway.GetAuthorizers(params)
+		if err != nil {
+			return nil, err
+		}
+		for _, authorizer := range resp.Items {
+			function := authorizerFunction(aws.StringValue(authorizer.AuthorizerUri))
+			if function == "" {
+				continue
+			}
+			statement := fmt.Sprintf("apigateway-authorizer-%s-%s", aws.StringValue(api.Id), aws.StringValue(authorizer.Id))
+			policy, err := functionPolicy(clients, aws.String(function), "")
+			if err != nil {
+				// The authorizer function may already be gone
+				if isNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+			for _, existing := range policy.Statement {
+				if existing.Sid == statement {
+					items = append(items, authorizerPermissionTeardown(clients, function, statement))
+				}
+			}
+		}
+		if aws.StringValue(resp.Position) == "" {
+			return items, nil
+		}
+		params.Position = resp.Position
+	}
+}
+
+// authorizerFunction returns the ARN of the Lambda function the authorizer
+// invokes, without a qualifier, or an empty string if it doesn't invoke one
+func authorizerFunction(uri string) string {
+	start := strings.Index(uri, "/functions/")
+	end := strings.LastIndex(uri, "/invocations")
+	if start < 0 || end < start {
+		return ""
+	}
+	arn := uri[start+len("/functions/") : end]
+	// Qualified ARNs have the alias or version after the function name
+	if parts := strings.Split(arn, ":"); len(parts) == 8 {
+		arn = strings.Join(parts[:7], ":")
+	}
+	return arn
+}
+
+// aquaPermission is a statement Aqua added to the policy of the function, or
+// to that of one of its aliases or versions
+type aquaPermission struct {
+	statement string
+	qualifier string
+}
+
+// aquaPermissions returns the statements Aqua added to the policies of the
+// function and its aliases and versions, and the ARNs of the schedule rules
+// that are allowed to invoke it
+func aquaPermissions(clients *Clients, settings *Config) ([]aquaPermission, []string, error) {
+	qualifiers, err := functionQualifiers(clients, settings.FunctionName)
+	if err != nil {
+		return nil, nil, err
+	}
+	var permissions []aquaPermission
+	var ruleArns []string
+	scheduler := fmt.Sprintf("scheduler-%s", aws.StringValue(settings.FunctionName))
+	for _, qualifier := range qualifiers {
+		policy, err := functionPolicy(clients, settings.FunctionName, qualifier)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, statement := range policy.Statement {
+			switch {
+			case strings.HasPrefix(statement.Sid, "apigateway-"):
+				permissions = append(permissions, aquaPermission{statement: statement.Sid, qualifier: qualifier})
+			case statement.Sid == scheduler:
+				permissions = append(permissions, aquaPermission{statement: statement.Sid, qualifier: qualifier})
+				if arn := statement.Condition["ArnLike"]["AWS:SourceArn"]; arn != "" {
+					ruleArns = append(ruleArns, arn)
+				}
+			}
+		}
+	}
+	return permissions, ruleArns, nil
+}
+
+// functionQualifiers returns the aliases and published versions of the
+// function, preceded by an empty qualifier for $LATEST
+func functionQualifiers(clients *Clients, function *string) ([]string, error) {
+	qualifiers := []string{""}
+	err := clients.Lambda.ListAliasesPages(&lambda.ListAliasesInput{
+		FunctionName: function,
+	}, func(page *lambda.ListAliasesOutput, lastPage bool) bool {
+		for _, alias := range page.Aliases {
+			qualifiers = append(qualifiers, aws.StringValue(alias.Name))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = clients.Lambda.ListVersionsByFunctionPages(&lambda.ListVersionsByFunctionInput{
+		FunctionName: function,
+	}, func(page *lambda.ListVersionsByFunctionOutput, lastPage bool) bool {
+		for _, version := range page.Versions {
+			if aws.StringValue(version.Version) != "$LATEST" {
+				qualifiers = append(qualifiers, aws.StringValue(version.Version))
+			}
+		}
+		return true
+	})
+	return qualifiers, err
+}
+
+// functionPolicy returns the policy of the function, or of its alias or
+// version if a qualifier is provided
+func functionPolicy(clients *Clients, function *string, qualifier string) (policyDocument, error) {
+	var policy policyDocument
+	params := &lambda.GetPolicyInput{
+		FunctionName: function,
+	}
+	if qualifier != "" {
+		params.Qualifier = aws.String(qualifier)
+	}
+	resp, err := clients.Lambda.GetPolicy(params)
+	if err != nil {
+		// A function without any permissions doesn't have a policy
+		if isNotFound(err) {
+			return policy, nil
+		}
+		return policy, err
+	}
+	err = json.Unmarshal([]byte(aws.StringValue(resp.Policy)), &policy)
+	return policy, err
+}
+
+// findRestAPIs returns all APIs with the provided name
+func findRestAPIs(clients *Clients, name string) ([]*apigateway.RestApi, error) {
+	var apis []*apigateway.RestApi
+	err := EachRestAPI(clients, ListOptions{NamePrefix: name}, func(api *apigateway.RestApi) bool {
+		if aws.StringValue(api.Name) == name {
+			apis = append(apis, api)
+		}
+		return true
+	})
+	return apis, err
+}
+
+func scheduleTeardown(clients *Clients, function *lambda.FunctionConfiguration, ruleArn string) TeardownItem {
+	ruleName := ruleArn[strings.LastIndex(ruleArn, "/")+1:]
+	return TeardownItem{
+		Type: "Schedule rule",
+		Name: ruleName,
+		remove: func() error {
+			targets, err := clients.Events.ListTargetsByRule(&cloudwatchevents.ListTargetsByRuleInput{
+				Rule: aws.String(ruleName),
+			})
+			if err != nil {
+				return err
+			}
+			var ids []*string
+			for _, target := range targets.Targets {
+				if aws.StringValue(target.Arn) == aws.StringValue(function.FunctionArn) {
+					ids = append(ids, target.Id)
+				}
+			}
+			if len(ids) > 0 {
+				_, err = clients.Events.RemoveTargets(&cloudwatchevents.RemoveTargetsInput{
+					Rule: aws.String(ruleName),
+					Ids:  ids,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			// Leave the rule in place if something else depends on it
+			if len(ids) < len(targets.Targets) {
+				return nil
+			}
+			_, err = clients.Events.DeleteRule(&cloudwatchevents.DeleteRuleInput{
+				Name: aws.String(ruleName),
+			})
+			return err
+		},
+	}
+}
+
+func permissionTeardown(clients *Clients, settings *Config, permission aquaPermission) TeardownItem {
+	name := permission.statement
+	params := &lambda.RemovePermissionInput{
+		FunctionName: settings.FunctionName,
+		StatementId:  aws.String(permission.statement),
+	}
+	if permission.qualifier != "" {
+		name = fmt.Sprintf("%s (%s)", permission.statement, permission.qualifier)
+		params.Qualifier = aws.String(permission.qualifier)
+	}
+	return TeardownItem{
+		Type: "Lambda permission",
+		Name: name,
+		remove: func() error {
+			_, err := clients.Lambda.RemovePermission(params)
+			return err
+		},
+	}
+}
+
+func authorizerPermissionTeardown(clients *Clients, function string, statement string) TeardownItem {
+	return TeardownItem{
+		Type: "Authorizer permission",
+		Name: fmt.Sprintf("%s (%s)", statement, function[strings.LastIndex(function, ":")+1:]),
+		remove: func() error {
+			_, err := clients.Lambda.RemovePermission(&lambda.RemovePermissionInput{
+				FunctionName: aws.String(function),
+				StatementId:  aws.String(statement),
+			})
+			return err
+		},
+	}
+}
+
+func basePathTeardown(clients *Clients, domain string, basePath string) TeardownItem {
+	return TeardownItem{
+		Type: "Base path mapping",
+		Name: fmt.Sprintf("%s/%s", domain, basePath),
+		remove: func() error {
+			_, err := clients.APIGateway.DeleteBasePathMapping(&apigateway.DeleteBasePathMappingInput{
+				DomainName: aws.String(domain),
+				BasePath:   aws.String(basePath),
+			})
+			return err
+		},
+	}
+}
+
+func planStageTeardown(clients *Clients, plan *apigateway.UsagePlan, apiID *string, stage string) TeardownItem {
+	return TeardownItem{
+		Type: "Usage plan stage",
+		Name: fmt.Sprintf("%s (%s:%s)", aws.StringValue(plan.Name), aws.StringValue(apiID), stage),
+		remove: func() error {
+			return DetachStage(clients, plan.Id, apiID, stage)
+		},
+	}
+}
+
+func restAPITeardown(clients *Clients, api *apigateway.RestApi) TeardownItem {
+	return TeardownItem{
+		Type: "API",
+		Name: fmt.Sprintf("%s (%s)", aws.StringValue(api.Name), aws.StringValue(api.Id)),
+		remove: func() error {
+			_, err := clients.APIGateway.DeleteRestApi(&apigateway.DeleteRestApiInput{
+				RestApiId: api.Id,
+			})
+			return err
+		},
+	}
+}
+
+// isNotFound checks if the error is caused by a resource not existing
+func isNotFound(err error) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		// API Gateway uses a different code than the other services
+		return awsErr.Code() == "ResourceNotFoundException" || awsErr.Code() == "NotFoundException"
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+func TestPlanTeardown(t *testing.T) {
+	tests := map[string]struct {
+		keepFunction bool
+		alias        bool
+		schedule     bool
+		types        []string
+	}{
+		"everything": {
+			types: []string{"API", "Lambda function"},
+		},
+		"keep function": {
+			keepFunction: true,
+			types:        []string{"Lambda permission", "Lambda permission", "Lambda permission", "Lambda permission", "API"},
+		},
+		"alias": {
+			keepFunction: true,
+			alias:        true,
+			types:        []string{"Lambda permission", "Lambda permission", "Lambda permission", "Lambda permission", "API"},
+		},
+		"alias and schedule": {
+			keepFunction: true,
+			alias:        true,
+			schedule:     true,
+			types:        []string{"Schedule rule", "Lambda permission", "Lambda permission", "Lambda permission", "Lambda permission", "Lambda permission", "API"},
+		},
+		"schedule": {
+			schedule: true,
+			types:    []string{"Schedule rule", "API", "Lambda function"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.addRole("lambda-basic")
+			settings := testSettings()
+			if test.alias {
+				settings.Publish = aws.Bool(true)
+				settings.Alias = aws.String("live")
+			}
+			builder := &GatewayBuilder{Settings: settings, Clients: clients}
+			if err := builder.Build(); err != nil {
+				t.Fatalf("Build failed: %s", err)
+			}
+			if test.schedule {
+				if err := CreateSchedule(clients, settings, "rate(1 hour)"); err != nil {
+					t.Fatalf("CreateSchedule failed: %s", err)
+				}
+			}
+
+			items, err := PlanTeardown(clients, settings, test.keepFunction)
+			if err != nil {
+				t.Fatalf("PlanTeardown failed: %s", err)
+			}
+			var types []string
+			for _, item := range items {
+				types = append(types, item.Type)
+			}
+			if !reflect.DeepEqual(types, test.types) {
+				t.Fatalf("got items %v, want %v", types, test.types)
+			}
+			for _, item := range items {
+				if err := item.Remove(); err != nil {
+					t.Fatalf("removing %s %s failed: %s", item.Type, item.Name, err)
+				}
+			}
+
+			if len(account.apis) != 0 || len(account.rules) != 0 || len(account.policies) != 0 {
+				t.Error("the API, schedule, or permissions were left behind")
+			}
+			if _, ok := account.functions["hello"]; ok != test.keepFunction {
+				t.Errorf("got function %t, want %t", ok, test.keepFunction)
+			}
+		})
+	}
+}
+
+func TestPlanTeardownWithoutFunction(t *testing.T) {
+	clients, _ := newFakeClients()
+	items, err := PlanTeardown(clients, &Config{FunctionName: aws.String("missing")}, false)
+	if err != nil {
+		t.Fatalf("PlanTeardown failed: %s", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("got %d items for a missing function, want none", len(items))
+	}
+}
+
+func TestPlanTeardownDependencies(t *testing.T) {
+	tests := map[string]struct {
+		authorizer bool
+		domains    []string
+		plan       bool
+		types      []string
+	}{
+		"authorizer": {
+			authorizer: true,
+			types:      []string{"Authorizer permission", "API", "Lambda function"},
+		},
+		"base path mappings": {
+			domains: []string{"api.example.com", "other.example.com"},
+			types:   []string{"Base path mapping", "Base path mapping", "API", "Lambda function"},
+		},
+		"usage plan": {
+			plan:  true,
+			types: []string{"Usage plan stage", "API", "Lambda function"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.addRole("lambda-basic")
+			auth := testSettings()
+			auth.FunctionName = aws.String("auth")
+			auth.NoGateway = aws.Bool(true)
+			if err := (&GatewayBuilder{Settings: auth, Clients: clients}).Build(); err != nil {
+				t.Fatalf("creating the authorizer function failed: %s", err)
+			}
+			settings := testSettings()
+			if test.authorizer {
+				settings.AuthorizerFunction = aws.String("auth")
+				settings.AuthorizerIdentity = aws.String("Authorization")
+			}
+			builder := &GatewayBuilder{Settings: settings, Clients: clients}
+			if err := builder.Build(); err != nil {
+				t.Fatalf("Build failed: %s", err)
+			}
+			for _, domain := range test.domains {
+				if _, err := CreateDomain(clients, domain, "arn:aws:acm:us-east-1:123456789012:certificate/abc", "REGIONAL"); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := MapBasePath(clients, domain, "", builder.APIGateway.Id, builder.StageName()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.plan {
+				if _, err := CreateUsagePlan(clients, "basic", "", UsagePlanSettings{}, []*apigateway.ApiStage{{
+					ApiId: builder.APIGateway.Id,
+					Stage: aws.String(builder.StageName()),
+				}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			items, err := PlanTeardown(clients, settings, false)
+			if err != nil {
+				t.Fatalf("PlanTeardown failed: %s", err)
+			}
+			var types []string
+			for _, item := range items {
+				types = append(types, item.Type)
+			}
+			if !reflect.DeepEqual(types, test.types) {
+				t.Fatalf("got items %v, want %v", types, test.types)
+			}
+			for _, item := range items {
+				if err := item.Remove(); err != nil {
+					t.Fatalf("removing %s %s failed: %s", item.Type, item.Name, err)
+				}
+			}
+			if len(account.apis) != 0 {
+				t.Error("the API was left behind")
+			}
+			if len(account.policies["auth"]) != 0 {
+				t.Errorf("the authorizer function still has permissions %v", account.statements("auth"))
+			}
+			for domain, mappings := range account.mappings {
+				if len(mappings) != 0 {
+					t.Errorf("%s still has base path mappings", domain)
+				}
+			}
+			for _, plan := range account.usagePlans {
+				if len(plan.ApiStages) != 0 {
+					t.Errorf("usage plan %s still has stages", aws.StringValue(plan.Name))
+				}
+			}
+			if _, ok := account.functions["auth"]; !ok {
+				t.Error("the authorizer function was removed")
+			}
+		})
+	}
+}
+
+func TestPlanTeardownAPIID(t *testing.T) {
+	clients, account := newFakeClients()
+	account.addRole("lambda-basic")
+	if err := (&GatewayBuilder{Settings: testSettings(), Clients: clients}).Build(); err != nil {
+		t.Fatalf("Build failed: %s", err)
+	}
+	other, err := clients.APIGateway.CreateRestApi(&apigateway.CreateRestApiInput{Name: aws.String("shared")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := testSettings()
+	settings.APIID = other.Id
+	settings.NoGateway = aws.Bool(true)
+	if err = (&GatewayBuilder{Settings: settings, Clients: clients}).Build(); err != nil {
+		t.Fatalf("Build failed: %s", err)
+	}
+
+	items, err := PlanTeardown(clients, settings, true)
+	if err != nil {
+		t.Fatalf("PlanTeardown failed: %s", err)
+	}
+	var names []string
+	for _, item := range items {
+		if item.Type == "API" {
+			names = append(names, item.Name)
+		}
+	}
+	if want := []string{"shared (" + aws.StringValue(other.Id) + ")"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got APIs %v, want %v", names, want)
+	}
+
+	settings.APIID = aws.String("missing")
+	if _, err = PlanTeardown(clients, settings, true); err == nil {
+		t.Error("PlanTeardown accepted an API ID that doesn't exist")
+	}
+}
@@ -0,0 +1,210 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+)
+
+// SupportedQuotaPeriods are the periods a usage plan's quota can be counted over
+var SupportedQuotaPeriods = []string{"DAY", "WEEK", "MONTH"}
+
+// UsagePlanSettings contains the throttling and quota of a usage plan. Zero
+// values aren't set.
+type UsagePlanSettings struct {
+	// RateLimit is the number of requests per second allowed for each key
+	RateLimit float64
+	// BurstLimit is the number of concurrent requests allowed for each key
+	BurstLimit int64
+	// QuotaLimit is the number of requests allowed for each key per period
+	QuotaLimit  int64
+	QuotaPeriod string
+}
+
+// Validate checks the usage plan settings, and returns all the problems it
+// finds
+func (settings UsagePlanSettings) Validate() error {
+	var problems []string
+	if settings.RateLimit < 0 || settings.BurstLimit < 0 || settings.QuotaLimit < 0 {
+		problems = append(problems, "The throttling and quota limits can't be negative")
+	}
+	if settings.QuotaLimit > 0 && !contains(SupportedQuotaPeriods, settings.QuotaPeriod) {
+		problems = append(problems, fmt.Sprintf("%s is not a supported quota period, please use one of %s",
+			settings.QuotaPeriod, strings.Join(SupportedQuotaPeriods, ", ")))
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// CreateUsagePlan creates a usage plan for the stages of APIs
+func CreateUsagePlan(clients *Clients, name string, description string, settings UsagePlanSettings, stages []*apigateway.ApiStage) (*apigateway.UsagePlan, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	params := &apigateway.CreateUsagePlanInput{
+		Name:      aws.String(name),
+		ApiStages: stages,
+	}
+	if description != "" {
+		params.Description = aws.String(description)
+	}
+	if settings.RateLimit != 0 || settings.BurstLimit != 0 {
+		params.Throttle = &apigateway.ThrottleSettings{}
+		if settings.RateLimit != 0 {
+			params.Throttle.RateLimit = aws.Float64(settings.RateLimit)
+		}
+		if settings.BurstLimit != 0 {
+			params.Throttle.BurstLimit = aws.Int64(settings.BurstLimit)
+		}
+	}
+	if settings.QuotaLimit != 0 {
+		params.Quota = &apigateway.QuotaSettings{
+			Limit:  aws.Int64(settings.QuotaLimit),
+			Period: aws.String(settings.QuotaPeriod),
+		}
+	}
+	return clients.APIGateway.CreateUsagePlan(params)
+}
+
+// ListUsagePlans returns all usage plans
+func ListUsagePlans(clients *Clients) ([]*apigateway.UsagePlan, error) {
+	var plans []*apigateway.UsagePlan
+	err := EachUsagePlan(clients, ListOptions{}, func(plan *apigateway.UsagePlan) bool {
+		plans = append(plans, plan)
+		return true
+	})
+	return plans, err
+}
+
+// FindUsagePlan returns the usage plan with the ID or name
+func FindUsagePlan(clients *Clients, plan string) (*apigateway.UsagePlan, error) {
+	plans, err := ListUsagePlans(clients)
+	if err != nil {
+		return nil, err
+	}
+	var found []*apigateway.UsagePlan
+	for _, existing := range plans {
+		if aws.StringValue(existing.Id) == plan {
+			return existing, nil
+		}
+		if aws.StringValue(existing.Name) == plan {
+			found = append(found, existing)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("There is no usage plan %s", plan)
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("There are %d usage plans named %s, please select one by its ID", len(found), plan)
+	}
+}
+
+// AttachAPIKey adds the API key to the usage plan
+func AttachAPIKey(clients *Clients, planID *string, keyID string) error {
+	_, err := clients.APIGateway.CreateUsagePlanKey(&apigateway.CreateUsagePlanKeyInput{
+		UsagePlanId: planID,
+		KeyId:       aws.String(keyID),
+		KeyType:     aws.String("API_KEY"),
+	})
+	return err
+}
+
+// DetachAPIKey removes the API key from the usage plan
+func DetachAPIKey(clients *Clients, planID *string, keyID string) error {
+	_, err := clients.APIGateway.DeleteUsagePlanKey(&apigateway.DeleteUsagePlanKeyInput{
+		UsagePlanId: planID,
+		KeyId:       aws.String(keyID),
+	})
+	return err
+}
+
+// AttachStage adds the stage of the API to the usage plan
+func AttachStage(clients *Clients, planID *string, apiID *string, stage string) error {
+	return patchStages(clients, planID, "add", apiID, stage)
+}
+
+// DetachStage removes the stage of the API from the usage plan
+func DetachStage(clients *Clients, planID *string, apiID *string, stage string) error {
+	return patchStages(clients, planID, "remove", apiID, stage)
+}
+
+func patchStages(clients *Clients, planID *string, operation string, apiID *string, stage string) error {
+	_, err := clients.APIGateway.UpdateUsagePlan(&apigateway.UpdateUsagePlanInput{
+		UsagePlanId: planID,
+		PatchOperations: []*apigateway.PatchOperation{{
+			Op:    aws.String(operation),
+			Path:  aws.String("/apiStages"),
+			Value: aws.String(fmt.Sprintf("%s:%s", aws.StringValue(apiID), stage)),
+		}},
+	})
+	return err
+}
+
+// CreateKeyWithPlan creates an API key for the stage of the API, together with
+// a usage plan for that stage that the key is added to
+func CreateKeyWithPlan(clients *Clients, name string, apiID *string, stage string, settings UsagePlanSettings) (*apigateway.ApiKey, *apigateway.UsagePlan, error) {
+	return createKeyWithPlan(clients, &apigateway.CreateApiKeyInput{
+		Name:    aws.String(name),
+		Enabled: aws.Bool(true),
+	}, apiID, stage, settings)
+}
+
+// createKeyWithPlan creates the API key and a usage plan with the same name for
+// the stage of the API, and adds the key to the plan. If a step fails, the key
+// and plan are removed again, and when that fails as well the error names them
+// so they can be cleaned up.
+func createKeyWithPlan(clients *Clients, params *apigateway.CreateApiKeyInput, apiID *string, stage string, settings UsagePlanSettings) (*apigateway.ApiKey, *apigateway.UsagePlan, error) {
+	plan, err := CreateUsagePlan(clients, aws.StringValue(params.Name), "", settings, []*apigateway.ApiStage{{
+		ApiId: apiID,
+		Stage: aws.String(stage),
+	}})
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := clients.APIGateway.CreateApiKey(params)
+	if err != nil {
+		return nil, nil, removeKeyWithPlan(clients, err, nil, plan)
+	}
+	if err = AttachAPIKey(clients, plan.Id, aws.StringValue(key.Id)); err != nil {
+		err = fmt.Errorf("Unable to add the API key to usage plan %s: %s", aws.StringValue(plan.Name), err.Error())
+		return nil, nil, removeKeyWithPlan(clients, err, key, plan)
+	}
+	return key, plan, nil
+}
+
+// removeKeyWithPlan deletes the API key, if there is one, and the usage plan
+// after err occurred. Whatever can't be deleted is added to the error.
+func removeKeyWithPlan(clients *Clients, err error, key *apigateway.ApiKey, plan *apigateway.UsagePlan) error {
+	if key != nil {
+		if removeErr := DeleteAPIKey(clients, aws.StringValue(key.Id)); removeErr != nil {
+			err = fmt.Errorf("%s\nRemoving the new API key %s failed as well, please delete it yourself: %s",
+				err.Error(), aws.StringValue(key.Id), removeErr.Error())
+		}
+	}
+	if removeErr := deleteUsagePlan(clients, plan); removeErr != nil {
+		err = fmt.Errorf("%s\nRemoving the new usage plan %s failed as well, please delete it yourself: %s",
+			err.Error(), aws.StringValue(plan.Id), removeErr.Error())
+	}
+	return err
+}
+
+// deleteUsagePlan removes the stages from the usage plan, as a plan with
+// stages can't be deleted, and then deletes it
+func deleteUsagePlan(clients *Clients, plan *apigateway.UsagePlan) error {
+	for _, stage := range plan.ApiStages {
+		if err := DetachStage(clients, plan.Id, stage.ApiId, aws.StringValue(stage.Stage)); err != nil {
+			return err
+		}
+	}
+	_, err := clients.APIGateway.DeleteUsagePlan(&apigateway.DeleteUsagePlanInput{
+		UsagePlanId: plan.Id,
+	})
+	return err
+}
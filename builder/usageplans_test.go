@@ -0,0 +1,71 @@
+package builder
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestCreateKeyWithPlan(t *testing.T) {
+	tests := map[string]struct {
+		fail     map[string]error
+		created  bool
+		leftOver bool
+	}{
+		"created":             {created: true},
+		"creating plan fails": {fail: map[string]error{"CreateUsagePlan": errors.New("throttled")}},
+		"creating key fails":  {fail: map[string]error{"CreateApiKey": errors.New("throttled")}},
+		"attach fails":        {fail: map[string]error{"CreateUsagePlanKey": errors.New("limit exceeded")}},
+		"removing fails": {
+			fail:     map[string]error{"CreateUsagePlanKey": errors.New("limit exceeded"), "DeleteApiKey": errors.New("throttled"), "DeleteUsagePlan": errors.New("throttled")},
+			leftOver: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.fail = test.fail
+
+			key, plan, err := CreateKeyWithPlan(clients, "client", aws.String("api"), DefaultStage, UsagePlanSettings{RateLimit: 10, BurstLimit: 20})
+			if !test.created {
+				if err == nil {
+					t.Fatal("expected the creation to fail")
+				}
+				if key != nil || plan != nil {
+					t.Error("expected no key or plan when the creation fails")
+				}
+				if test.leftOver {
+					for id := range account.apiKeys {
+						if !strings.Contains(err.Error(), id) {
+							t.Errorf("expected the error to name the left over key %s: %s", id, err)
+						}
+					}
+					for id := range account.usagePlans {
+						if !strings.Contains(err.Error(), id) {
+							t.Errorf("expected the error to name the left over usage plan %s: %s", id, err)
+						}
+					}
+					return
+				}
+				if len(account.apiKeys) != 0 || len(account.usagePlans) != 0 {
+					t.Errorf("expected the key and plan to be removed, found %d keys and %d plans", len(account.apiKeys), len(account.usagePlans))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(plan.ApiStages) != 1 || aws.StringValue(plan.ApiStages[0].ApiId) != "api" || aws.StringValue(plan.ApiStages[0].Stage) != DefaultStage {
+				t.Errorf("expected the plan to be for the %s stage of api, got %v", DefaultStage, plan.ApiStages)
+			}
+			if aws.Float64Value(plan.Throttle.RateLimit) != 10 {
+				t.Errorf("expected the plan to have the settings, got %v", plan.Throttle)
+			}
+			if !contains(account.planKeys[aws.StringValue(plan.Id)], aws.StringValue(key.Id)) {
+				t.Error("expected the key to be added to the plan")
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// SupportedAuthentications are the authorization types of the methods
+var SupportedAuthentications = []string{"NONE", "AWS_IAM", "CUSTOM", cognitoAuthorizerType}
+
+// Runtime describes a Lambda runtime. Deprecated runtimes can no longer be
+// used for new functions, and are replaced by the Successor.
+type Runtime struct {
+	Deprecated bool
+	Successor  string
+}
+
+// Runtimes are the Lambda runtimes Aqua knows about. Keep this list up to
+// date with https://docs.aws.amazon.com/lambda/latest/dg/lambda-runtimes.html
+var Runtimes = map[string]Runtime{
+	"nodejs24.x":      {},
+	"nodejs22.x":      {},
+	"nodejs20.x":      {Deprecated: true, Successor: "nodejs22.x"},
+	"nodejs18.x":      {Deprecated: true, Successor: "nodejs22.x"},
+	"nodejs16.x":      {Deprecated: true, Successor: "nodejs22.x"},
+	"nodejs14.x":      {Deprecated: true, Successor: "nodejs22.x"},
+	"nodejs12.x":      {Deprecated: true, Successor: "nodejs22.x"},
+	"nodejs10.x":      {Deprecated: true, Successor: "nodejs22.x"},
+	"nodejs8.10":      {Deprecated: true, Successor: "nodejs22.x"},
+	"nodejs6.10":      {Deprecated: true, Successor: "nodejs22.x"},
+	"nodejs4.3":       {Deprecated: true, Successor: "nodejs22.x"},
+	"nodejs":          {Deprecated: true, Successor: "nodejs22.x"},
+	"python3.14":      {},
+	"python3.13":      {},
+	"python3.12":      {},
+	"python3.11":      {},
+	"python3.10":      {},
+	"python3.9":       {Deprecated: true, Successor: "python3.13"},
+	"python3.8":       {Deprecated: true, Successor: "python3.13"},
+	"python3.7":       {Deprecated: true, Successor: "python3.13"},
+	"python3.6":       {Deprecated: true, Successor: "python3.13"},
+	"python2.7":       {Deprecated: true, Successor: "python3.13"},
+	"java21":          {},
+	"java17":          {},
+	"java11":          {},
+	"java8.al2":       {},
+	"java8":           {Deprecated: true, Successor: "java21"},
+	"dotnet8":         {},
+	"dotnet6":         {Deprecated: true, Successor: "dotnet8"},
+	"dotnetcore3.1":   {Deprecated: true, Successor: "dotnet8"},
+	"ruby3.4":         {},
+	"ruby3.3":         {},
+	"ruby3.2":         {Deprecated: true, Successor: "ruby3.4"},
+	"ruby2.7":         {Deprecated: true, Successor: "ruby3.4"},
+	"provided.al2023": {},
+	"provided.al2":    {},
+	"provided":        {Deprecated: true, Successor: "provided.al2023"},
+	"go1.x":           {Deprecated: true, Successor: "provided.al2023"},
+}
+
+var regionName = regexp.MustCompile(`^[a-z]{2,4}(-[a-z]+)+-\d+$`)
+
+var functionName = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+var functionARN = regexp.MustCompile(`^arn:aws[a-z-]*:lambda:[a-z0-9-]+:[0-9]{12}:function:([a-zA-Z0-9_-]{1,64})(:[a-zA-Z0-9$_-]+)?$`)
+
+// Validate checks all the settings before any changes are made, and returns
+// every problem it finds at once. Deprecated runtimes only cause a warning, as
+// they can still be used for existing functions; creating a function with one
+// fails. The role is only looked up when clients are provided.
+func (config Config) Validate(clients *Clients) ([]string, error) {
+	var problems, warnings []string
+	add := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if _, err := config.Methods(); err != nil {
+		add(err)
+	}
+	add(config.ValidateFunction())
+	add(config.ValidateCORS())
+	add(config.ValidateAuthorizer())
+
+	if authentication := aws.StringValue(config.Authentication); authentication != "" && !contains(SupportedAuthentications, authentication) {
+		problems = append(problems, fmt.Sprintf("%s is not a supported authentication method, please use one of %s",
+			authentication, strings.Join(SupportedAuthentications, ", ")))
+	}
+	if name := aws.StringValue(config.FunctionName); name == "" {
+		problems = append(problems, "Please provide the name of the Lambda function with --name")
+	} else if match := functionARN.FindStringSubmatch(name); match != nil {
+		problems = append(problems, fmt.Sprintf("%s is the ARN of a function, please provide only its name (%s) with --name and its region with --region", name, match[1]))
+	} else if !functionName.MatchString(name) {
+		problems = append(problems, fmt.Sprintf("%s is not a valid function name, it can only contain letters, numbers, - and _ and can be at most 64 characters long", name))
+	}
+	if region := aws.StringValue(config.Region); region != "" && !regionName.MatchString(region) {
+		problems = append(problems, fmt.Sprintf("%s is not a valid region, please use a region like us-east-1", region))
+	}
+	if name := aws.StringValue(config.Runtime); name != "" {
+		if runtime, ok := Runtimes[name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s is not a known runtime", name))
+		} else if runtime.Deprecated {
+			warnings = append(warnings, deprecatedRuntime(name).Error())
+		}
+	}
+	if expression := aws.StringValue(config.Schedule); expression != "" {
+		add(ValidateSchedule(expression))
+	}
+	if role := aws.StringValue(config.RoleName); role != "" && clients != nil {
+		if _, err := GetRole(clients, config.RoleName); err != nil {
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "NoSuchEntity" {
+				problems = append(problems, fmt.Sprintf("The role %s doesn't exist, you can create it with aqua role create", role))
+			} else {
+				add(err)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return warnings, errors.New(strings.Join(problems, "\n"))
+	}
+	return warnings, nil
+}
+
+// deprecatedRuntime returns an error for runtimes that can't be used to create
+// new functions anymore
+func deprecatedRuntime(name string) error {
+	if runtime := Runtimes[name]; runtime.Deprecated {
+		return fmt.Errorf("The %s runtime is deprecated and can't be used for new functions, please use %s instead", name, runtime.Successor)
+	}
+	return nil
+}
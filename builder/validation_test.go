@@ -0,0 +1,111 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		settings func(*Config)
+		problem  string
+		warning  string
+	}{
+		"valid": {
+			settings: func(settings *Config) {},
+		},
+		"function ARN": {
+			settings: func(settings *Config) {
+				settings.FunctionName = aws.String("arn:aws:lambda:us-east-1:123456789012:function:hello")
+			},
+			problem: "please provide only its name (hello)",
+		},
+		"qualified function ARN": {
+			settings: func(settings *Config) {
+				settings.FunctionName = aws.String("arn:aws-cn:lambda:cn-north-1:123456789012:function:hello:live")
+			},
+			problem: "please provide only its name (hello)",
+		},
+		"invalid name": {
+			settings: func(settings *Config) { settings.FunctionName = aws.String("hello world") },
+			problem:  "is not a valid function name",
+		},
+		"unknown runtime": {
+			settings: func(settings *Config) { settings.Runtime = aws.String("cobol") },
+			problem:  "cobol is not a known runtime",
+		},
+		"GovCloud region": {
+			settings: func(settings *Config) { settings.Region = aws.String("us-gov-west-1") },
+		},
+		"sovereign cloud region": {
+			settings: func(settings *Config) { settings.Region = aws.String("eusc-de-east-1") },
+		},
+		"invalid region": {
+			settings: func(settings *Config) { settings.Region = aws.String("us_east_1") },
+			problem:  "us_east_1 is not a valid region",
+		},
+		"deprecated runtime": {
+			settings: func(settings *Config) { settings.Runtime = aws.String("nodejs4.3") },
+			warning:  "please use nodejs22.x instead",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			settings := testSettings()
+			test.settings(settings)
+			warnings, err := settings.Validate(nil)
+			if test.problem == "" && err != nil {
+				t.Errorf("unexpected problem: %s", err)
+			}
+			if test.problem != "" && (err == nil || !strings.Contains(err.Error(), test.problem)) {
+				t.Errorf("expected a problem containing %q, got %v", test.problem, err)
+			}
+			if got := strings.Join(warnings, "\n"); !strings.Contains(got, test.warning) || (test.warning == "" && got != "") {
+				t.Errorf("expected a warning containing %q, got %q", test.warning, got)
+			}
+		})
+	}
+}
+
+func TestDeprecatedRuntime(t *testing.T) {
+	tests := map[string]struct {
+		update bool
+		failed bool
+	}{
+		"create": {failed: true},
+		"update": {update: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			clients, account := newFakeClients()
+			account.addRole("lambda-basic")
+			if test.update {
+				if err := (&GatewayBuilder{Settings: testSettings(), Clients: clients}).Build(); err != nil {
+					t.Fatalf("creating the function failed: %s", err)
+				}
+			}
+			settings := testSettings()
+			settings.Runtime = aws.String("nodejs4.3")
+			settings.Update = aws.Bool(test.update)
+			builder := &GatewayBuilder{Settings: settings, Clients: clients}
+			err := builder.Build()
+			if test.failed {
+				if err == nil {
+					t.Fatal("the function was created with a deprecated runtime")
+				}
+				if account.called("CreateFunction") != 0 {
+					t.Error("CreateFunction was called")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("updating the function failed: %s", err)
+			}
+			if len(builder.Warnings) != 1 {
+				t.Errorf("expected a warning for the deprecated runtime, got %v", builder.Warnings)
+			}
+		})
+	}
+}
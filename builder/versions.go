@@ -0,0 +1,107 @@
+package builder
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// aliasVariable is the stage variable that holds the alias of the Lambda
+// function a stage invokes
+const aliasVariable = "lambdaAlias"
+
+// PublishAndAlias publishes a version of the Lambda function and points the
+// alias at it, if the settings ask for either
+func (builder *GatewayBuilder) PublishAndAlias() error {
+	if aws.BoolValue(builder.Settings.Publish) {
+		if err := builder.publishVersion(); err != nil {
+			return err
+		}
+	}
+	if aws.StringValue(builder.Settings.Alias) != "" {
+		return builder.ensureAlias()
+	}
+	return nil
+}
+
+// publishVersion publishes the current code and configuration of the Lambda
+// function as a new version. Lambda returns the latest version instead if
+// nothing changed since it was published.
+func (builder *GatewayBuilder) publishVersion() error {
+	svc := builder.Clients.Lambda
+	waitParams := &lambda.GetFunctionConfigurationInput{
+		FunctionName: builder.Settings.FunctionName,
+	}
+	// New functions have to be active, and updated ones done updating,
+	// before they can be published
+	if err := svc.WaitUntilFunctionActive(waitParams); err != nil {
+		return err
+	}
+	if err := svc.WaitUntilFunctionUpdated(waitParams); err != nil {
+		return err
+	}
+	version, err := svc.PublishVersion(&lambda.PublishVersionInput{
+		FunctionName: builder.Settings.FunctionName,
+		CodeSha256:   builder.Lambda.CodeSha256,
+	})
+	if err != nil {
+		return err
+	}
+	builder.Version = version
+	return nil
+}
+
+// ensureAlias creates the alias or moves it to the published version. Without
+// a published version, the alias points at $LATEST.
+func (builder *GatewayBuilder) ensureAlias() error {
+	svc := builder.Clients.Lambda
+	version := "$LATEST"
+	if builder.Version != nil {
+		version = aws.StringValue(builder.Version.Version)
+	}
+
+	current, err := svc.GetAlias(&lambda.GetAliasInput{
+		FunctionName: builder.Settings.FunctionName,
+		Name:         builder.Settings.Alias,
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == "ResourceNotFoundException" {
+			builder.Alias, err = svc.CreateAlias(&lambda.CreateAliasInput{
+				FunctionName:    builder.Settings.FunctionName,
+				Name:            builder.Settings.Alias,
+				FunctionVersion: aws.String(version),
+			})
+			if err == nil {
+				builder.undoAlias(builder.Alias)
+			}
+		}
+		return err
+	}
+	if aws.StringValue(current.FunctionVersion) == version {
+		builder.Alias = current
+		return nil
+	}
+	builder.Alias, err = svc.UpdateAlias(&lambda.UpdateAliasInput{
+		FunctionName:    builder.Settings.FunctionName,
+		Name:            builder.Settings.Alias,
+		FunctionVersion: aws.String(version),
+	})
+	if err == nil {
+		builder.undoAliasUpdate(current)
+	}
+	return err
+}
+
+// integrationURI returns the URI API Gateway uses to invoke the Lambda
+// function. When an alias is used, the alias is taken from the stage
+// variable, so every stage can invoke a different alias.
+func (builder *GatewayBuilder) integrationURI() string {
+	function := aws.StringValue(builder.Lambda.FunctionArn)
+	if aws.StringValue(builder.Settings.Alias) != "" {
+		function = fmt.Sprintf("%s:${stageVariables.%s}", function, aliasVariable)
+	}
+	return fmt.Sprintf("arn:aws:apigateway:%s:lambda:path/2015-03-31/functions/%s/invocations",
+		aws.StringValue(builder.Settings.Region), function)
+}
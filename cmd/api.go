@@ -0,0 +1,73 @@
+// Copyright © 2016 Arjen Schwarz <developer@arjen.eu>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/spf13/cobra"
+
+	"github.com/ArjenSchwarz/aqua/builder"
+)
+
+// apiCmd represents the api command
+var apiCmd = &cobra.Command{
+	Use:   "api",
+	Short: "List the APIs in the region",
+	Long: `Running aqua api will display all the APIs in the region, including the ones
+Aqua created. Large lists can be limited, filtered, and sorted.
+
+Example: aqua api --filter name=orders --sort created --limit 10
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		printer, options, err := newListPrinter(apiFilters, apiFields)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		err = builder.EachRestAPI(awsClients(), options, func(api *apigateway.RestApi) bool {
+			printer.add(map[string]string{
+				"name":        aws.StringValue(api.Name),
+				"api":         aws.StringValue(api.Id),
+				"description": aws.StringValue(api.Description),
+				"created":     aws.TimeValue(api.CreatedDate).Format(time.RFC3339),
+			})
+			return true
+		})
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printer.done("No APIs have been found.")
+	},
+}
+
+// apiFilters are the filters APIs can be listed with
+var apiFilters = []string{"name"}
+
+// apiFields are the fields APIs can be sorted by
+var apiFields = []string{"name", "api", "created"}
+
+func init() {
+	RootCmd.AddCommand(apiCmd)
+	addListFlags(apiCmd, "name=prefix", apiFields)
+}
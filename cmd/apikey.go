@@ -22,7 +22,7 @@ package cmd
 
 import (
 	"github.com/ArjenSchwarz/aqua/builder"
-	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
 
 	"github.com/spf13/cobra"
 )
@@ -30,26 +30,39 @@ import (
 // apikeyCmd represents the apikey command
 var apikeyCmd = &cobra.Command{
 	Use:   "apikey",
-	Short: "List and create API keys",
-	Long:  `List your available API keys`,
+	Short: "List and manage API keys",
+	Long: `List your available API keys. Large lists can be limited, filtered, and
+sorted.
+
+Example: aqua apikey --filter name=web --filter enabled=true --sort created --limit 20
+
+The subcommands create, delete, enable, disable, expire, rotate, and show API
+keys, and show how many requests were made with them.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		keys, err := builder.ListAPIKeys(settings)
+		printer, options, err := newListPrinter(apikeyFilters, apikeyFields)
 		if err != nil {
 			printFailure(err.Error())
 			return
 		}
-		values := make([]map[string]string, len(keys.Items))
-		for index, key := range keys.Items {
-			keydef := make(map[string]string)
-			keydef["name"] = aws.StringValue(key.Name)
-			keydef["description"] = aws.StringValue(key.Description)
-			keydef["apikey"] = aws.StringValue(key.Id)
-			values[index] = keydef
+		err = builder.EachAPIKey(awsClients(), options, func(key *apigateway.ApiKey) bool {
+			printer.add(keyValues(key))
+			return true
+		})
+		if err != nil {
+			printFailure(err.Error())
+			return
 		}
-		printSliceMaps(values)
+		printer.done("No API keys have been found.")
 	},
 }
 
+// apikeyFilters are the filters API keys can be listed with
+var apikeyFilters = []string{"name", "enabled"}
+
+// apikeyFields are the fields API keys can be sorted by
+var apikeyFields = []string{"name", "apikey", "created"}
+
 func init() {
 	RootCmd.AddCommand(apikeyCmd)
+	addListFlags(apikeyCmd, "name=prefix or enabled=true|false", apikeyFields)
 }
@@ -0,0 +1,299 @@
+// Copyright © 2016 Arjen Schwarz <developer@arjen.eu>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/spf13/cobra"
+
+	"github.com/ArjenSchwarz/aqua/builder"
+)
+
+var (
+	keyID         string
+	keyReveal     bool
+	keyDisableOld bool
+	keyGrace      time.Duration
+	keyWait       bool
+	usageDays     int
+)
+
+// deleteapikeyCmd represents the apikey delete command
+var deleteapikeyCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete an API key",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !requireKey() {
+			return
+		}
+		if err := builder.DeleteAPIKey(awsClients(), keyID); err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printSuccess(fmt.Sprintf("Deleted API key %s", keyID))
+	},
+}
+
+// enableapikeyCmd represents the apikey enable command
+var enableapikeyCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Enable an API key",
+	Run: func(cmd *cobra.Command, args []string) {
+		setKeyEnabled(true)
+	},
+}
+
+// disableapikeyCmd represents the apikey disable command
+var disableapikeyCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Disable an API key",
+	Run: func(cmd *cobra.Command, args []string) {
+		setKeyEnabled(false)
+	},
+}
+
+// rotateapikeyCmd represents the apikey rotate command
+var rotateapikeyCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Replace an API key with a new one",
+	Long: `Creates a new API key with the same name and description as the old one, and
+adds it to the same stages and usage plans. The old key keeps working, which
+gives the users of the key time to switch to the new one. Once they have, you
+disable it with "aqua apikey disable", for example from a scheduled job. Use
+--disable-old to disable the old key straight away instead.
+
+With --grace-period the old key is disabled once that period has passed. By
+default this is recorded in the aqua:disable-after tag of the old key, and
+"aqua apikey expire" disables the keys whose grace period is over, for example
+from a scheduled job. With --wait the command waits for the grace period and
+disables the old key itself.
+
+If the new key can't be added to one of the usage plans, it is removed again.
+
+Example: aqua apikey rotate --key keyID --grace-period 24h
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !requireKey() {
+			return
+		}
+		if keyGrace < 0 {
+			printFailure("The grace period can't be negative")
+			return
+		}
+		if keyDisableOld && keyGrace > 0 {
+			printFailure("Please use either --disable-old or --grace-period, not both")
+			return
+		}
+		if keyWait && keyGrace == 0 {
+			printFailure("Please provide the time to wait using the --grace-period flag")
+			return
+		}
+		key, plans, err := builder.RotateAPIKey(awsClients(), keyID)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		var planNames []string
+		for _, plan := range plans {
+			planNames = append(planNames, aws.StringValue(plan.Name))
+		}
+		result := map[string]string{
+			"apikey":      aws.StringValue(key.Id),
+			"value":       aws.StringValue(key.Value),
+			"old_apikey":  keyID,
+			"usage_plans": strings.Join(planNames, ", "),
+		}
+		if keyDisableOld {
+			if _, err = builder.SetAPIKeyEnabled(awsClients(), keyID, false); err != nil {
+				printFailure(fmt.Sprintf("Created API key %s, but disabling %s failed: %s", aws.StringValue(key.Id), keyID, err.Error()))
+				return
+			}
+			result["old_apikey_enabled"] = "false"
+		} else if keyWait {
+			if _, err = builder.DisableAPIKeyAfter(awsClients(), keyID, keyGrace); err != nil {
+				printFailure(fmt.Sprintf("Created API key %s, but disabling %s after %s failed: %s", aws.StringValue(key.Id), keyID, keyGrace, err.Error()))
+				return
+			}
+			result["old_apikey_enabled"] = "false"
+		} else if keyGrace > 0 {
+			expires, err := builder.ScheduleAPIKeyDisable(awsClients(), aws.StringValue(settings.Region), keyID, keyGrace)
+			if err != nil {
+				printFailure(fmt.Sprintf("Created API key %s, but scheduling %s to be disabled failed: %s", aws.StringValue(key.Id), keyID, err.Error()))
+				return
+			}
+			result["old_apikey_enabled"] = "true"
+			result["old_apikey_disable_after"] = expires.Format(time.RFC3339)
+			result["note"] = "Run \"aqua apikey expire\" after this time to disable the old key"
+		} else {
+			result["old_apikey_enabled"] = "true"
+			result["note"] = fmt.Sprintf("Disable the old key with \"aqua apikey disable --key %s\" once everyone uses the new one", keyID)
+		}
+		printMap(result)
+	},
+}
+
+// expireapikeyCmd represents the apikey expire command
+var expireapikeyCmd = &cobra.Command{
+	Use:   "expire",
+	Short: "Disable the rotated API keys whose grace period is over",
+	Long: `Disables the API keys that "aqua apikey rotate --grace-period" scheduled to be
+disabled, once their grace period has passed. Run it from a scheduled job to
+disable old keys on time.
+
+Example: aqua apikey expire
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		disabled, err := builder.DisableExpiredAPIKeys(awsClients(), aws.StringValue(settings.Region), time.Now())
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		if len(disabled) == 0 {
+			printSuccess("No API keys had to be disabled.")
+			return
+		}
+		values := make([]map[string]string, len(disabled))
+		for index, key := range disabled {
+			values[index] = keyValues(key)
+		}
+		printSliceMaps(values)
+	},
+}
+
+// showapikeyCmd represents the apikey show command
+var showapikeyCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the details of an API key",
+	Long: `Shows the details of an API key. The value of the key is only shown when you
+ask for it with --reveal.
+
+Example: aqua apikey show --key keyID --reveal
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !requireKey() {
+			return
+		}
+		key, err := builder.GetAPIKey(awsClients(), keyID, keyReveal)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		plans, err := builder.APIKeyPlans(awsClients(), keyID)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		values := keyValues(key)
+		var planNames []string
+		for _, plan := range plans {
+			planNames = append(planNames, aws.StringValue(plan.Name))
+		}
+		values["usage_plans"] = strings.Join(planNames, ", ")
+		values["stages"] = strings.Join(aws.StringValueSlice(key.StageKeys), ", ")
+		if keyReveal {
+			values["value"] = aws.StringValue(key.Value)
+		}
+		printMap(values)
+	},
+}
+
+// usageapikeyCmd represents the apikey usage command
+var usageapikeyCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show the number of requests made with an API key per day",
+	Long: `Shows the number of requests made with an API key on each day, as counted by
+the usage plans the key is part of.
+
+Example: aqua apikey usage --key keyID --days 30
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !requireKey() {
+			return
+		}
+		usage, err := builder.GetAPIKeyUsage(awsClients(), keyID, usageDays)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		if len(usage) == 0 {
+			printSuccess(fmt.Sprintf("No usage has been found for API key %s.", keyID))
+			return
+		}
+		values := make([]map[string]string, len(usage))
+		for index, day := range usage {
+			values[index] = map[string]string{
+				"date":      day.Date,
+				"plan":      day.Plan,
+				"used":      strconv.FormatInt(day.Used, 10),
+				"remaining": strconv.FormatInt(day.Remaining, 10),
+			}
+		}
+		printSliceMaps(values)
+	},
+}
+
+func init() {
+	for _, command := range []*cobra.Command{deleteapikeyCmd, enableapikeyCmd, disableapikeyCmd, rotateapikeyCmd, showapikeyCmd, usageapikeyCmd} {
+		apikeyCmd.AddCommand(command)
+		command.Flags().StringVar(&keyID, "key", "", "The ID of the API key")
+	}
+	apikeyCmd.AddCommand(expireapikeyCmd)
+	rotateapikeyCmd.Flags().BoolVar(&keyDisableOld, "disable-old", false, "Disable the old key as soon as the new one is created")
+	rotateapikeyCmd.Flags().DurationVar(&keyGrace, "grace-period", 0, "Disable the old key once this period has passed, for example 24h")
+	rotateapikeyCmd.Flags().BoolVar(&keyWait, "wait", false, "Wait for the grace period and disable the old key, instead of scheduling it")
+	showapikeyCmd.Flags().BoolVar(&keyReveal, "reveal", false, "Show the value of the key")
+	usageapikeyCmd.Flags().IntVar(&usageDays, "days", 7, "The number of days up to and including today to show")
+}
+
+func requireKey() bool {
+	if keyID == "" {
+		printFailure("Please provide the ID of the API key using the --key flag")
+		return false
+	}
+	return true
+}
+
+func setKeyEnabled(enabled bool) {
+	if !requireKey() {
+		return
+	}
+	key, err := builder.SetAPIKeyEnabled(awsClients(), keyID, enabled)
+	if err != nil {
+		printFailure(err.Error())
+		return
+	}
+	printMap(keyValues(key))
+}
+
+func keyValues(key *apigateway.ApiKey) map[string]string {
+	return map[string]string{
+		"apikey":      aws.StringValue(key.Id),
+		"name":        aws.StringValue(key.Name),
+		"description": aws.StringValue(key.Description),
+		"enabled":     strconv.FormatBool(aws.BoolValue(key.Enabled)),
+		"created":     aws.TimeValue(key.CreatedDate).Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright © 2016 Arjen Schwarz <developer@arjen.eu>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create or update everything in a project file",
+	Long: `Makes your account match the roles, functions, gateways, schedules, and API
+keys described in a project file. See "aqua plan --help" for the format.
+
+Everything that doesn't match is created or updated in that order, and the
+result is shown for each of them. Resources that already match are left alone.
+Later resources can depend on earlier ones, so once something fails the rest
+is skipped.
+
+Example: aqua apply --project aqua.yaml
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, items, ok := planProject()
+		if !ok {
+			return
+		}
+		values := make([]map[string]string, len(items))
+		failed := false
+		for index, item := range items {
+			values[index] = planItemValues(item)
+			if failed {
+				values[index]["result"] = "skipped"
+				continue
+			}
+			values[index]["result"] = "done"
+			if err := item.Apply(); err != nil {
+				values[index]["result"] = err.Error()
+				failed = true
+			}
+		}
+		printSliceMaps(values)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().StringVarP(&projectFile, "project", "p", "aqua.yaml", "The project file describing your resources")
+}
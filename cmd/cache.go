@@ -0,0 +1,95 @@
+// Copyright © 2016 Arjen Schwarz <developer@arjen.eu>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ArjenSchwarz/aqua/builder"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "List and clean the download cache",
+	Long: `Files downloaded for --file and aqua install are cached, and only downloaded
+again when the server reports they have changed.
+
+The cache is stored in your user cache directory, unless you set the
+AQUA_CACHE_DIR environment variable. Cleaning the cache only removes the
+entries and objects directories Aqua creates in it, and refuses to do so if
+they contain files Aqua didn't put there.
+
+Example: aqua cache list
+
+Example: aqua cache clean
+`,
+}
+
+// cacheListCmd represents the cache list command
+var cacheListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the cached downloads",
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := builder.ListCache()
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		if len(entries) == 0 {
+			printSuccess("The download cache is empty.")
+			return
+		}
+		values := make([]map[string]string, len(entries))
+		for index, entry := range entries {
+			values[index] = map[string]string{
+				"url":     entry.URL,
+				"sha256":  entry.SHA256,
+				"size":    strconv.FormatInt(entry.Size, 10),
+				"fetched": entry.Fetched.Format(time.RFC3339),
+			}
+		}
+		printSliceMaps(values)
+	},
+}
+
+// cacheCleanCmd represents the cache clean command
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove all cached downloads",
+	Run: func(cmd *cobra.Command, args []string) {
+		count, err := builder.CleanCache()
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printSuccess(fmt.Sprintf("Removed %d cached downloads", count))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheListCmd)
+	cacheCmd.AddCommand(cacheCleanCmd)
+}
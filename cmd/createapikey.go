@@ -30,14 +30,35 @@ import (
 var createapikeyCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Create an API key",
-	Long:  `Creates an API key in the region specified (defaults to us-east-1)`,
+	Long: `Creates an API key in the region specified (defaults to us-east-1)
+
+When an API is provided, the key gets access to its stage through a new usage
+plan with the same name as the key. The rate, burst, and quota flags set the
+limits of that plan.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		key, err := builder.CreateAPIKey(keyname, keydescription, keyenabled, apiid, settings.Region)
+		if apiid != "" {
+			if err := planSettings.Validate(); err != nil {
+				printFailure(err.Error())
+				return
+			}
+		}
+		stage := aws.StringValue(settings.Stage)
+		if stage == "" {
+			stage = builder.DefaultStage
+		}
+		key, plan, err := builder.CreateAPIKey(awsClients(), keyname, keydescription, keyenabled, apiid, stage, planSettings)
 		if err != nil {
 			printFailure(err.Error())
 			return
 		}
-		printSuccess(aws.StringValue(key.Id))
+		if plan == nil {
+			printSuccess(aws.StringValue(key.Id))
+			return
+		}
+		printMap(map[string]string{
+			"apikey":     aws.StringValue(key.Id),
+			"usage_plan": aws.StringValue(plan.Id),
+		})
 	},
 }
 
@@ -63,4 +84,5 @@ func init() {
 	createapikeyCmd.Flags().StringVar(&keyname, "keyname", "", "The name for the key")
 	createapikeyCmd.Flags().StringVar(&keydescription, "description", "", "The description for the key")
 	createapikeyCmd.Flags().StringVar(&apiid, "apiid", "", "The ID of the API you wish to attach the key to")
+	addPlanFlags(createapikeyCmd)
 }
@@ -22,9 +22,6 @@ package cmd
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
-	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/spf13/cobra"
@@ -32,10 +29,6 @@ import (
 	"github.com/ArjenSchwarz/aqua/builder"
 )
 
-var (
-	roleType string
-)
-
 // createCmd represents the create command
 var createCmd = &cobra.Command{
 	Use:   "create",
@@ -63,31 +56,12 @@ func init() {
 }
 
 func createRole(cmd *cobra.Command, args []string) {
-	roleType = strings.ToLower(aws.StringValue(settings.RoleType))
-	var roleTemplate string
-	switch roleType {
-	case "basic":
-		roleTemplate = builder.BasicRole
-	case "s3":
-		roleTemplate = builder.S3Role
-	case "aqua":
-		roleTemplate = builder.AquaRole
-	case "custom":
-		if _, err := os.Stat(*settings.RoleFilename); err != nil {
-			printFailure(err.Error())
-			return
-		}
-		roleContents, err := ioutil.ReadFile(*settings.RoleFilename)
-		if err != nil {
-			printFailure(err.Error())
-			return
-		}
-		roleTemplate = string(roleContents)
-	default:
-		printFailure("I'm sorry, but I can't create that role for you.")
+	roleTemplate, err := builder.RoleTemplate(aws.StringValue(settings.RoleType), aws.StringValue(settings.RoleFilename))
+	if err != nil {
+		printFailure(err.Error())
 		return
 	}
-	err := builder.CreateIAMRole(roleTemplate, settings.RoleName)
+	err = builder.CreateIAMRole(awsClients(), roleTemplate, settings.RoleName)
 	if err != nil {
 		printFailure(err.Error())
 		return
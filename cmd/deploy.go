@@ -0,0 +1,106 @@
+// Copyright © 2016 Arjen Schwarz <developer@arjen.eu>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/cobra"
+
+	"github.com/ArjenSchwarz/aqua/builder"
+)
+
+// deployCmd represents the deploy command
+var deployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "Create or update a Lambda function",
+	Long: `Deploys the provided code and configuration to a Lambda function, without
+touching its Gateway.
+
+If the function doesn't exist yet, it will be created. Otherwise the code is
+replaced with the provided file or source directory unless it is unchanged,
+and the runtime, role, handler, memory, and timeout are updated if they are
+provided and different.
+
+Example: aqua deploy --name functionName --file path/to/function.zip
+
+Example: aqua deploy --name functionName --source path/to/function
+
+Example: aqua deploy --name functionName --memory 256 --timeout 30
+
+Example (publish a version and point the dev alias at it):
+aqua deploy --name functionName --file path/to/function.zip --publish --alias dev
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		settings.Update = aws.Bool(true)
+		settings.NoGateway = aws.Bool(true)
+		builder := builder.GatewayBuilder{Settings: settings, Clients: awsClients()}
+		err := builder.Build()
+
+		if err != nil {
+			failBuild(&builder, err)
+			return
+		}
+
+		values := make(map[string]string)
+		if builder.FunctionUpdate == nil {
+			values["function"] = aws.StringValue(builder.Lambda.FunctionArn)
+			values["code_sha256"] = aws.StringValue(builder.Lambda.CodeSha256)
+			values["version"] = aws.StringValue(builder.Lambda.Version)
+		} else {
+			values = functionUpdateValues(builder.FunctionUpdate)
+		}
+		buildValues(values, &builder)
+		printMap(values)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(deployCmd)
+}
+
+func functionUpdateValues(update *builder.FunctionUpdate) map[string]string {
+	values := make(map[string]string)
+	values["function"] = aws.StringValue(update.After.FunctionArn)
+	values["code_sha256_before"] = aws.StringValue(update.Before.CodeSha256)
+	values["code_sha256_after"] = aws.StringValue(update.After.CodeSha256)
+	values["version_before"] = aws.StringValue(update.Before.Version)
+	values["version_after"] = aws.StringValue(update.After.Version)
+	return values
+}
+
+// buildValues adds the package, published version, and alias that were
+// deployed to the values
+func buildValues(values map[string]string, builder *builder.GatewayBuilder) {
+	if builder.Package != nil {
+		if builder.Package.SHA256 != "" {
+			values["package_sha256"] = builder.Package.SHA256
+		}
+		if builder.Package.Location != nil {
+			values["package_location"] = builder.Package.Location.String()
+		}
+	}
+	if builder.Version != nil {
+		values["published_version"] = aws.StringValue(builder.Version.Version)
+	}
+	if builder.Alias != nil {
+		values["alias"] = aws.StringValue(builder.Alias.AliasArn)
+	}
+}
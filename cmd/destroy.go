@@ -0,0 +1,110 @@
+// Copyright © 2016 Arjen Schwarz <developer@arjen.eu>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/cobra"
+
+	"github.com/ArjenSchwarz/aqua/builder"
+)
+
+var (
+	destroyConfirmed bool
+	keepFunction     bool
+)
+
+// destroyCmd represents the destroy command
+var destroyCmd = &cobra.Command{
+	Use:   "destroy",
+	Short: "Delete everything Aqua created for a function",
+	Long: `Deletes the API, Lambda permissions, schedule, and the Lambda function
+that Aqua created for the function with the provided name.
+
+The resources are found by the names Aqua gives them, or for the API by the
+--api-id you provide. Before an API is deleted, the permissions of its Lambda
+authorizers, the base path mappings that point to it, and its stages in usage
+plans are removed. Everything is shown before anything is deleted. Unless you provide --yes, you will be asked to confirm.
+
+Example: aqua destroy --name functionName
+
+Example (keep the function itself): aqua destroy --name functionName --keep-function --yes
+`,
+	Run: destroy,
+}
+
+func init() {
+	RootCmd.AddCommand(destroyCmd)
+	destroyCmd.Flags().BoolVarP(&destroyConfirmed, "yes", "y", false, "Delete without asking for confirmation")
+	destroyCmd.Flags().BoolVar(&keepFunction, "keep-function", false, "Only delete the resources around the Lambda function, not the function itself")
+}
+
+func destroy(cmd *cobra.Command, args []string) {
+	if aws.StringValue(settings.FunctionName) == "" {
+		printFailure("Please provide the name of the function using the --name flag")
+		return
+	}
+	items, err := builder.PlanTeardown(awsClients(), settings, keepFunction)
+	if err != nil {
+		printFailure(err.Error())
+		return
+	}
+	if len(items) == 0 {
+		printSuccess(fmt.Sprintf("Nothing to delete for %s", aws.StringValue(settings.FunctionName)))
+		return
+	}
+
+	if !destroyConfirmed {
+		if aws.BoolValue(settings.JSONOutput) {
+			printFailure("Please use --yes to confirm the deletion when using JSON output")
+			return
+		}
+		fmt.Println("The following resources will be deleted:")
+		for _, item := range items {
+			fmt.Printf("* %s: %s\n", item.Type, item.Name)
+		}
+		fmt.Print("Do you want to continue? [y/N] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			printSuccess("Nothing has been deleted")
+			return
+		}
+	}
+
+	values := make([]map[string]string, len(items))
+	for index, item := range items {
+		result := make(map[string]string)
+		result["type"] = item.Type
+		result["name"] = item.Name
+		result["result"] = "deleted"
+		if err := item.Remove(); err != nil {
+			result["result"] = err.Error()
+		}
+		values[index] = result
+	}
+	printSliceMaps(values)
+}
@@ -0,0 +1,235 @@
+// Copyright © 2016 Arjen Schwarz <developer@arjen.eu>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/spf13/cobra"
+
+	"github.com/ArjenSchwarz/aqua/builder"
+)
+
+var (
+	domainName     string
+	certificateARN string
+	endpointType   string
+	basePath       string
+	route53File    string
+)
+
+// domainCmd represents the domain command
+var domainCmd = &cobra.Command{
+	Use:   "domain",
+	Short: "Manage custom domain names for APIs",
+	Long: `Create custom domain names for your APIs, and map base paths of those domains
+to the stages of the APIs Aqua created.
+
+A custom domain name needs an existing ACM certificate. For REGIONAL domains
+(the default) the certificate has to be in the same region, for EDGE domains
+it has to be in us-east-1. Afterwards your DNS needs to point the domain to
+the target DNS name Aqua shows. With --route53-file Aqua writes a Route 53
+change batch that does this, which you can apply using:
+
+aws route53 change-resource-record-sets --hosted-zone-id <zone> --change-batch file://<file>
+
+Example: aqua domain create --domain api.example.com --certificate-arn arn:aws:acm:us-east-1:123456789012:certificate/abc --route53-file dns.json
+
+Example: aqua domain map --domain api.example.com --name functionName --stage prod --base-path v1
+
+Example: aqua domain mappings --domain api.example.com
+
+Example: aqua domain unmap --domain api.example.com --base-path v1
+`,
+}
+
+// domainCreateCmd represents the domain create command
+var domainCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a custom domain name",
+	Run: func(cmd *cobra.Command, args []string) {
+		if domainName == "" || certificateARN == "" {
+			printFailure("Please provide the domain using the --domain flag and its certificate using the --certificate-arn flag")
+			return
+		}
+		domain, err := builder.CreateDomain(awsClients(), domainName, certificateARN, endpointType)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printDomain(domain)
+	},
+}
+
+// domainDNSCmd represents the domain dns command
+var domainDNSCmd = &cobra.Command{
+	Use:   "dns",
+	Short: "Show the DNS name a custom domain name has to point to",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := requireDomain(); err != nil {
+			printFailure(err.Error())
+			return
+		}
+		domain, err := builder.GetDomain(awsClients(), domainName)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printDomain(domain)
+	},
+}
+
+// domainMapCmd represents the domain map command
+var domainMapCmd = &cobra.Command{
+	Use:   "map",
+	Short: "Map a base path of a custom domain name to a stage of an API",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := requireDomain(); err != nil {
+			printFailure(err.Error())
+			return
+		}
+		api, err := stageAPI()
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		stage := aws.StringValue(settings.Stage)
+		if stage == "" {
+			stage = builder.DefaultStage
+		}
+		mapping, err := builder.MapBasePath(awsClients(), domainName, basePath, api.Id, stage)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printMap(mappingValues(mapping))
+	},
+}
+
+// domainMappingsCmd represents the domain mappings command
+var domainMappingsCmd = &cobra.Command{
+	Use:   "mappings",
+	Short: "List the base path mappings of a custom domain name",
+	Long: `Lists the base path mappings of a custom domain name. Large lists can be
+limited, filtered by the start of their base path, and sorted.
+
+Example: aqua domain mappings --domain api.example.com --filter name=v1 --sort base_path
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := requireDomain(); err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printer, options, err := newListPrinter(mappingFilters, mappingFields)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		err = builder.EachBasePathMapping(awsClients(), domainName, options, func(mapping *apigateway.BasePathMapping) bool {
+			printer.add(mappingValues(mapping))
+			return true
+		})
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printer.done(fmt.Sprintf("%s doesn't have any base path mappings.", domainName))
+	},
+}
+
+// mappingFilters are the filters base path mappings can be listed with
+var mappingFilters = []string{"name"}
+
+// mappingFields are the fields base path mappings can be sorted by
+var mappingFields = []string{"base_path", "api", "stage"}
+
+// domainUnmapCmd represents the domain unmap command
+var domainUnmapCmd = &cobra.Command{
+	Use:   "unmap",
+	Short: "Delete a base path mapping of a custom domain name",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := requireDomain(); err != nil {
+			printFailure(err.Error())
+			return
+		}
+		if err := builder.DeleteBasePathMapping(awsClients(), domainName, basePath); err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printSuccess(fmt.Sprintf("Deleted the mapping of %s", builder.DomainURL(domainName, basePath)))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(domainCmd)
+	domainCmd.AddCommand(domainCreateCmd)
+	domainCmd.AddCommand(domainDNSCmd)
+	domainCmd.AddCommand(domainMapCmd)
+	domainCmd.AddCommand(domainMappingsCmd)
+	addListFlags(domainMappingsCmd, "name=base path prefix", mappingFields)
+	domainCmd.AddCommand(domainUnmapCmd)
+	domainCmd.PersistentFlags().StringVar(&domainName, "domain", "", "The custom domain name, for example api.example.com")
+	domainCreateCmd.Flags().StringVar(&certificateARN, "certificate-arn", "", "The ARN of the ACM certificate for the domain")
+	domainCreateCmd.Flags().StringVar(&endpointType, "endpoint-type", "REGIONAL", "The type of the domain: REGIONAL or EDGE")
+	for _, command := range []*cobra.Command{domainCreateCmd, domainDNSCmd} {
+		command.Flags().StringVar(&route53File, "route53-file", "", "Write a Route 53 change batch that points the domain to its target to this file")
+	}
+	for _, command := range []*cobra.Command{domainMapCmd, domainUnmapCmd} {
+		command.Flags().StringVar(&basePath, "base-path", "", "The base path of the mapping. Leave it out for the root of the domain")
+	}
+}
+
+func requireDomain() error {
+	if domainName == "" {
+		return errors.New("Please provide the domain using the --domain flag")
+	}
+	return nil
+}
+
+// printDomain shows the target of the domain, and writes the Route 53 change
+// batch if asked for
+func printDomain(domain *apigateway.DomainName) {
+	dnsName, hostedZoneID := builder.DomainTarget(domain)
+	values := map[string]string{
+		"domain":         aws.StringValue(domain.DomainName),
+		"target":         dnsName,
+		"hosted_zone_id": hostedZoneID,
+	}
+	if route53File != "" {
+		if err := builder.WriteRoute53ChangeBatch(domain, route53File); err != nil {
+			printFailure(err.Error())
+			return
+		}
+		values["route53_change_batch"] = route53File
+	}
+	printMap(values)
+}
+
+func mappingValues(mapping *apigateway.BasePathMapping) map[string]string {
+	return map[string]string{
+		"url":       builder.DomainURL(domainName, aws.StringValue(mapping.BasePath)),
+		"base_path": aws.StringValue(mapping.BasePath),
+		"api":       aws.StringValue(mapping.RestApiId),
+		"stage":     aws.StringValue(mapping.Stage),
+	}
+}
@@ -21,6 +21,8 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/ArjenSchwarz/aqua/builder"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/spf13/cobra"
@@ -44,16 +46,74 @@ required permissions. You can use the following command to create such a role:
 
 aqua role create --role role_name --type aqua
 
+The downloaded file is checked against the checksum manifest published with the
+release, after verifying the signature of the manifest with the signing key Aqua
+was built with or the one you provide with --signing-key. Without a signing key
+the installation stops, unless you accept an unsigned manifest with
+--allow-unsigned.
+
+With --usage-plan, an API key is created together with a usage plan for the
+endpoint, using the throttling and quota you provide.
+
 Example:
 aqua install --name aqua -role aquarole
+
+Example (with an API key limited to 1000 requests per day):
+aqua install --name aqua --role aquarole --usage-plan aqua --quota 1000 --quota-period DAY
 `,
 	Run: func(cmd *cobra.Command, args []string) {
+		if installPlan != "" {
+			if err := planSettings.Validate(); err != nil {
+				printFailure(err.Error())
+				return
+			}
+		}
 		settings.FilePath = &builder.AquaLambdaURL
 		settings.ApikeyRequired = aws.Bool(true)
-		buildGateway(cmd, args)
+		key := *signingKey
+		if allowUnsigned {
+			key = ""
+		} else if key == "" {
+			printFailure("There is no signing key to verify the Aqua release with, please provide one with --signing-key or use --allow-unsigned to only verify its checksum")
+			return
+		}
+		checksum, verified, err := builder.ReleaseChecksum(builder.AquaLambdaURL, builder.AquaChecksumsURL, key)
+		if err != nil {
+			printFailure(fmt.Sprintf("Unable to verify the Aqua release: %s", err.Error()))
+			return
+		}
+		if !verified {
+			printFailure("The signature of the checksum manifest wasn't verified, only the checksum of the release is")
+		}
+		settings.SHA256 = &checksum
+		gateway, messages := gatewayBuild()
+		if gateway == nil {
+			return
+		}
+		if installPlan != "" {
+			key, plan, err := builder.CreateKeyWithPlan(awsClients(), installPlan, gateway.APIGateway.Id, gateway.StageName(), planSettings)
+			if err != nil {
+				printFailure(fmt.Sprintf("Unable to create the usage plan: %s", err.Error()))
+			} else {
+				messages["apikey"] = aws.StringValue(key.Id)
+				messages["usage_plan"] = aws.StringValue(plan.Id)
+				delete(messages, "note")
+			}
+		}
+		printMap(messages)
 	},
 }
 
+var (
+	signingKey    *string
+	allowUnsigned bool
+	installPlan   string
+)
+
 func init() {
 	RootCmd.AddCommand(installCmd)
+	signingKey = installCmd.Flags().String("signing-key", builder.AquaSigningKey, "The base64 encoded ed25519 public key the release's checksum manifest is signed with")
+	installCmd.Flags().BoolVar(&allowUnsigned, "allow-unsigned", false, "Install the release without verifying the signature of its checksum manifest")
+	installCmd.Flags().StringVar(&installPlan, "usage-plan", "", "Create an API key and a usage plan with this name for the installed endpoint")
+	addPlanFlags(installCmd)
 }
@@ -0,0 +1,99 @@
+// Copyright © 2016 Arjen Schwarz <developer@arjen.eu>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/cobra"
+
+	"github.com/ArjenSchwarz/aqua/builder"
+)
+
+var projectFile string
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show what apply would change",
+	Long: `Compares the roles, functions, gateways, schedules, and API keys described in
+a project file with what exists in your account, and shows for each of them
+whether it would be created, updated, or left alone (no-op).
+
+The project file can be written in YAML or JSON, for example:
+
+region: us-east-1
+roles:
+  - name: basic_execution_role
+    type: basic
+functions:
+  - name: myFunction
+    role: basic_execution_role
+    file: path/to/function.zip
+    methods: [GET, POST]
+    schedule: rate(10 minutes)
+apikeys:
+  - name: myKey
+    function: myFunction
+
+Example: aqua plan --project aqua.yaml
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, items, ok := planProject()
+		if !ok {
+			return
+		}
+		values := make([]map[string]string, len(items))
+		for index, item := range items {
+			values[index] = planItemValues(item)
+		}
+		printSliceMaps(values)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(planCmd)
+	planCmd.Flags().StringVarP(&projectFile, "project", "p", "aqua.yaml", "The project file describing your resources")
+}
+
+// planProject loads the project file and plans the changes required for it.
+// Failures are reported directly.
+func planProject() (*builder.Project, []builder.PlanItem, bool) {
+	project, err := builder.LoadProject(projectFile, aws.StringValue(settings.Region))
+	if err != nil {
+		printFailure(err.Error())
+		return nil, nil, false
+	}
+	settings.Region = aws.String(project.Region)
+	items, err := project.Plan(awsClients())
+	if err != nil {
+		printFailure(err.Error())
+		return nil, nil, false
+	}
+	return project, items, true
+}
+
+func planItemValues(item builder.PlanItem) map[string]string {
+	values := make(map[string]string)
+	values["type"] = item.Type
+	values["name"] = item.Name
+	values["action"] = item.Action
+	return values
+}
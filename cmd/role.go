@@ -21,9 +21,10 @@
 package cmd
 
 import (
-	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/spf13/cobra"
 
 	"github.com/ArjenSchwarz/aqua/builder"
@@ -33,31 +34,43 @@ import (
 var roleCmd = &cobra.Command{
 	Use:   "role",
 	Short: "Display or create IAM roles",
-	Long: `Running aqua role will display the names of all the roles you have access to.
+	Long: `Running aqua role will display all the roles you have access to. Large lists
+can be limited, filtered, and sorted.
 
 To create an IAM role, please use aqua role create.
+
+Example: aqua role --filter path=/service-role/ --filter name=lambda --sort created
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		resp, err := builder.GetRoles()
-
+		printer, options, err := newListPrinter(roleFilters, roleFields)
 		if err != nil {
 			printFailure(err.Error())
 			return
 		}
-
-		if len(resp.Roles) == 0 {
-			printSuccess("No roles have been found.")
+		err = builder.EachRole(awsClients(), options, func(role *iam.Role) bool {
+			printer.add(map[string]string{
+				"name":    aws.StringValue(role.RoleName),
+				"path":    aws.StringValue(role.Path),
+				"arn":     aws.StringValue(role.Arn),
+				"created": aws.TimeValue(role.CreateDate).Format(time.RFC3339),
+			})
+			return true
+		})
+		if err != nil {
+			printFailure(err.Error())
 			return
 		}
-		var list = "The following roles have been found:"
-		for _, role := range resp.Roles {
-			list += fmt.Sprintf("\n* %s", aws.StringValue(role.RoleName))
-		}
-
-		printSuccess(list)
+		printer.done("No roles have been found.")
 	},
 }
 
+// roleFilters are the filters roles can be listed with
+var roleFilters = []string{"name", "path"}
+
+// roleFields are the fields roles can be sorted by
+var roleFields = []string{"name", "path", "created"}
+
 func init() {
 	RootCmd.AddCommand(roleCmd)
+	addListFlags(roleCmd, "name=prefix or path=prefix", roleFields)
 }
@@ -21,7 +21,9 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/ArjenSchwarz/aqua/builder"
 	"github.com/aws/aws-sdk-go/aws"
@@ -29,10 +31,6 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var (
-	httpMethod = "POST"
-)
-
 var settings = new(builder.Config)
 
 // RootCmd represents the base command when called without any subcommands
@@ -44,7 +42,11 @@ var RootCmd = &cobra.Command{
 If the function doesn't exist yet, it will first create it using the provided
 file or a basic example that echoes back your parameters.
 
+If an API for the function already exists, or you provide one with --api-id,
+the endpoint is updated and redeployed instead of creating a new API.
+
 For function code located online, the file will first be downloaded locally.
+Files in S3 are passed on to Lambda directly.
 
 Example (only create Gateway):
 aqua --name functionName --region us-west-1
@@ -52,10 +54,33 @@ aqua --name functionName --region us-west-1
 Example (create Lambda function from local file):
 aqua --name functionName --role basic_execution_role --file path/to/function.zip
 
+Example (listen to GET and POST requests):
+aqua --name functionName --method GET,POST
+
+Example (allow a web application on another domain to call the endpoint):
+aqua --name functionName --method GET,POST --cors --cors-origins https://example.com
+
+Example (pass all requests on using a Lambda proxy integration):
+aqua --name functionName --proxy
+
+Example (create a Python Lambda function with more memory and an environment variable):
+aqua --name functionName --role basic_execution_role --file path/to/function.zip --runtime python3.12 --handler app.handler --memory 512 --env STAGE=prod
+
+Example (publish a version, point the staging alias at it, and deploy the staging stage):
+aqua --name functionName --update --file path/to/function.zip --publish --alias staging
+
+Example (create Lambda function from a directory):
+aqua --name functionName --role basic_execution_role --source path/to/function
+
+Example (create Lambda function from a file in S3):
+aqua --name functionName --role basic_execution_role --file s3://bucket/path/to/function.zip
+
 Example (create Lambda function from web file):
 aqua --name functionName --role basic_execution_role --file https://github.com/ArjenSchwarz/aqua/releases/download/latest/igor.zip
 `,
-	Run: buildGateway,
+	Run:               buildGateway,
+	PersistentPreRun:  captureDryRunOutput,
+	PersistentPostRun: printDryRun,
 }
 
 // Execute is the main execution command as created by Cobra
@@ -71,63 +96,105 @@ func init() {
 	settings.RoleName = RootCmd.PersistentFlags().StringP("role", "r", "", "The name of the IAM Role")
 	settings.Region = RootCmd.PersistentFlags().String("region", "us-east-1", "The region for the lambda function and API Gateway")
 	settings.Authentication = RootCmd.Flags().StringP("authentication", "a", "NONE", "The Authentication method to be used")
-	settings.FilePath = RootCmd.PersistentFlags().StringP("file", "f", "", "The zip file for your Lambda function, either locally or http(s). The file will first be downloaded locally.")
+	settings.FilePath = RootCmd.PersistentFlags().StringP("file", "f", "", "The zip file for your Lambda function, either locally or http(s). Files in S3 are provided as s3://bucket/key?versionId=version")
+	settings.Source = RootCmd.PersistentFlags().String("source", "", "A directory to package into the zip file for your Lambda function, instead of providing a file")
+	settings.UploadBucket = RootCmd.PersistentFlags().String("upload-bucket", "", "An S3 bucket in the same region to upload the zip file for your Lambda function to when it is too large to send directly")
+	settings.SHA256 = RootCmd.PersistentFlags().String("sha256", "", "The hex encoded SHA256 checksum the zip file for your Lambda function must have")
 	settings.ApikeyRequired = RootCmd.Flags().BoolP("apikey", "k", false, "Endpoint can only be accessed with an API key")
 	settings.JSONOutput = RootCmd.PersistentFlags().Bool("json", false, "Set to true to print output in JSON format")
-	settings.Runtime = RootCmd.Flags().String("runtime", "nodejs4.3", "The runtime of the Lambda function.")
+	settings.Runtime = RootCmd.PersistentFlags().String("runtime", "", fmt.Sprintf("The runtime of the Lambda function. New functions default to %s", builder.DefaultRuntime))
+	settings.Handler = RootCmd.PersistentFlags().String("handler", "", fmt.Sprintf("The handler of the Lambda function. New functions default to %s", builder.DefaultHandler))
+	settings.MemorySize = RootCmd.PersistentFlags().Int64("memory", 0, "The memory size of the Lambda function in MB")
+	settings.Timeout = RootCmd.PersistentFlags().Int64("timeout", 0, "The timeout of the Lambda function in seconds")
+	settings.Description = RootCmd.PersistentFlags().String("description", "", "The description of the Lambda function")
+	settings.Environment = RootCmd.PersistentFlags().StringArray("env", nil, "An environment variable for the Lambda function as KEY=VALUE. Can be used multiple times")
+	settings.EnvFile = RootCmd.PersistentFlags().String("env-file", "", "A file with an environment variable for the Lambda function as KEY=VALUE on each line")
+	settings.Architecture = RootCmd.PersistentFlags().String("architecture", "", "The architecture of the Lambda function: x86_64 or arm64")
+	settings.TracingMode = RootCmd.PersistentFlags().String("tracing", "", "The X-Ray tracing mode of the Lambda function: Active or PassThrough")
+	settings.Concurrency = RootCmd.PersistentFlags().Int64("concurrency", -1, "The reserved concurrency of the Lambda function")
+	settings.DeadLetterARN = RootCmd.PersistentFlags().String("dead-letter", "", "The ARN of the SQS queue or SNS topic for failed invocations of the Lambda function")
+	settings.Tags = RootCmd.PersistentFlags().StringArray("tag", nil, "A tag for the Lambda function as KEY=VALUE. Can be used multiple times")
+	settings.Update = RootCmd.PersistentFlags().Bool("update", false, "Update the code and configuration of an existing Lambda function with the provided values")
+	settings.Publish = RootCmd.PersistentFlags().Bool("publish", false, "Publish a version of the Lambda function after creating or updating it")
+	settings.Alias = RootCmd.PersistentFlags().String("alias", "", "Point this alias at the published version, or $LATEST without --publish. The stage the API is deployed to invokes the alias")
+	settings.Stage = RootCmd.PersistentFlags().String("stage", "", fmt.Sprintf("The stage to deploy the API to. Defaults to the alias if provided, or %s", builder.DefaultStage))
 	settings.NoGateway = RootCmd.Flags().Bool("nogateway", false, "Disable the creation of a Gateway. Only create the Lambda function.")
+	settings.HTTPMethods = RootCmd.Flags().StringSliceP("method", "m", []string{"POST"}, "The HTTP method(s) the endpoint listens to: GET, POST, PUT, PATCH, DELETE, or ANY")
+	settings.Proxy = RootCmd.Flags().Bool("proxy", false, "Pass every request and path below the endpoint to the function using a Lambda proxy integration")
+	settings.CORS = RootCmd.Flags().Bool("cors", false, "Allow browsers on other origins to call the endpoint")
+	settings.CORSOriginList = RootCmd.Flags().StringSlice("cors-origins", nil, "The origins allowed to call the endpoint with --cors (default *)")
+	settings.CORSHeaderList = RootCmd.Flags().StringSlice("cors-headers", nil, fmt.Sprintf("The request headers browsers are allowed to send with --cors (default %s)", strings.Join(builder.DefaultCORSHeaders, ",")))
+	settings.CORSMethodList = RootCmd.Flags().StringSlice("cors-methods", nil, "The HTTP methods browsers are allowed to use with --cors (default the methods of the endpoint)")
+	settings.CORSMaxAge = RootCmd.Flags().Int64("cors-max-age", 0, "The number of seconds browsers can cache the CORS preflight response")
+	settings.AuthorizerFunction = RootCmd.Flags().String("authorizer-function", "", "The name or ARN of a Lambda function that authorizes every request to the endpoint")
+	settings.AuthorizerType = RootCmd.Flags().String("authorizer-type", "TOKEN", "The type of the Lambda authorizer: TOKEN or REQUEST")
+	settings.AuthorizerIdentity = RootCmd.Flags().String("authorizer-identity", builder.DefaultAuthorizerIdentity, "The header that identifies the caller to the Lambda authorizer. REQUEST authorizers can use a comma separated list of headers")
+	settings.AuthorizerTTL = RootCmd.Flags().Int64("authorizer-ttl", builder.DefaultAuthorizerTTL, "The number of seconds the result of the Lambda authorizer is cached, 0 disables caching")
+	settings.UserPools = RootCmd.Flags().StringArray("cognito-user-pool", nil, "The ARN of a Cognito user pool whose users can call the endpoint. Can be used multiple times")
+	settings.OAuthScopes = RootCmd.Flags().StringSlice("oauth-scopes", nil, "The OAuth scopes the access token of a Cognito user needs to call the endpoint")
+	settings.APIID = RootCmd.PersistentFlags().String("api-id", "", "The ID of an existing API to use instead of the one Aqua created for the function")
+	settings.DryRun = RootCmd.PersistentFlags().Bool("dry-run", false, "Show the calls to AWS that would make changes, without making them")
+	settings.KeepOnFailure = RootCmd.PersistentFlags().Bool("keep-on-failure", false, "Don't remove the resources that were created when a later step fails")
 }
 
 func buildGateway(cmd *cobra.Command, args []string) {
-	settings.HTTPMethod = aws.String("POST") //For now we force the HTTP method to POST
-	builder := builder.GatewayBuilder{Settings: settings}
-	err := builder.EnsureLambdaFunction()
-
-	if err != nil {
-		printFailure(err.Error())
-		return
-	}
-
-	if *settings.NoGateway {
-		return
+	if _, messages := gatewayBuild(); len(messages) > 0 {
+		printMap(messages)
 	}
+}
 
-	err = builder.CreateAPIGateway()
+// gatewayBuild builds the gateway from the settings and returns the builder
+// together with the values that describe the result. If the build failed,
+// the failure is reported and nothing is returned.
+func gatewayBuild() (*builder.GatewayBuilder, map[string]string) {
+	builder := builder.GatewayBuilder{Settings: settings, Clients: awsClients()}
+	err := builder.Build()
 
 	if err != nil {
-		printFailure(err.Error())
-		return
+		failBuild(&builder, err)
+		return nil, nil
 	}
 
-	err = builder.AddResources()
-	if err != nil {
-		printFailure(err.Error())
-		return
+	messages := make(map[string]string)
+	if builder.FunctionUpdate != nil {
+		messages = functionUpdateValues(builder.FunctionUpdate)
 	}
-
-	err = builder.ConfigureResources()
-	if err != nil {
-		printFailure(err.Error())
-		return
+	if len(builder.Warnings) > 0 {
+		messages["warning"] = strings.Join(builder.Warnings, "\n")
 	}
+	buildValues(messages, &builder)
 
-	err = builder.DeployAPI()
-	if err != nil {
-		printFailure(err.Error())
-		return
+	if *settings.NoGateway {
+		return &builder, messages
 	}
 
-	err = builder.AddPermissions()
+	messages["endpoint"] = builder.Endpoint()
+	endpoints, err := builder.Endpoints()
 	if err != nil {
 		printFailure(err.Error())
-		return
 	}
-
-	messages := make(map[string]string)
-	messages["endpoint"] = builder.Endpoint()
+	for stage, endpoint := range endpoints {
+		messages[fmt.Sprintf("endpoint_%s", stage)] = endpoint
+	}
 	messages["api"] = aws.StringValue(builder.APIGateway.Id)
 	if aws.BoolValue(settings.ApikeyRequired) {
 		messages["note"] = "Remember to configure your API keys before you can use this endpoint."
 	}
-	printMap(messages)
+	return &builder, messages
+}
+
+// failBuild reports the error that stopped the build and, unless asked to
+// keep them, removes everything that was created up to that point
+func failBuild(builder *builder.GatewayBuilder, err error) {
+	printFailure(err.Error())
+	if aws.BoolValue(settings.KeepOnFailure) {
+		return
+	}
+	done, errs := builder.Rollback()
+	for _, action := range done {
+		printFailure(fmt.Sprintf("Rolled back: %s", action))
+	}
+	for _, rollbackErr := range errs {
+		printFailure(fmt.Sprintf("Rollback failed: %s", rollbackErr.Error()))
+	}
 }
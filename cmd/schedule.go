@@ -21,7 +21,11 @@
 package cmd
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/ArjenSchwarz/aqua/builder"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/spf13/cobra"
 )
 
@@ -32,9 +36,15 @@ var scheduleCmd = &cobra.Command{
 	Long: `Create a schedule for a Lambda function
 
 Example: aqua schedule --function-name MyLambdaFunction --schedule "rate(10 minutes)"
+
+Example: aqua schedule preview --schedule "cron(0 12 ? * MON-FRI *)" --count 10
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := builder.CreateSchedule(settings, schedule)
+		if _, err := settings.Validate(nil); err != nil {
+			printFailure(err.Error())
+			return
+		}
+		err := builder.CreateSchedule(awsClients(), settings, aws.StringValue(settings.Schedule))
 		if err != nil {
 			printFailure(err.Error())
 			return
@@ -43,9 +53,46 @@ Example: aqua schedule --function-name MyLambdaFunction --schedule "rate(10 minu
 	},
 }
 
+// schedulePreviewCmd represents the schedule preview command
+var schedulePreviewCmd = &cobra.Command{
+	Use:   "preview",
+	Short: "Show when a schedule runs",
+	Long: `Show the upcoming times in UTC a schedule runs, without creating it.
+Schedules using a rate run every interval from the moment they are created,
+so their times are counted from now.
+
+Example: aqua schedule preview --schedule "cron(0 12 ? * MON-FRI *)" --count 10
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		schedule, err := builder.ParseSchedule(aws.StringValue(settings.Schedule))
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		if previewCount < 1 {
+			printFailure(fmt.Sprintf("The count has to be positive, not %d", previewCount))
+			return
+		}
+		times := schedule.Upcoming(time.Now(), previewCount)
+		if len(times) == 0 {
+			printSuccess(fmt.Sprintf("%s doesn't run anymore, or selects dates that don't exist", schedule.Expression))
+			return
+		}
+		values := make([]map[string]string, len(times))
+		for index, runTime := range times {
+			values[index] = map[string]string{
+				"run": runTime.Format("2006-01-02T15:04:05Z (Mon)"),
+			}
+		}
+		printSliceMaps(values)
+	},
+}
+
 func init() {
 	RootCmd.AddCommand(scheduleCmd)
-	scheduleCmd.Flags().StringVar(&schedule, "schedule", "", "A schedule to run the Lambda function.")
+	scheduleCmd.AddCommand(schedulePreviewCmd)
+	settings.Schedule = scheduleCmd.PersistentFlags().String("schedule", "", "A schedule to run the Lambda function.")
+	schedulePreviewCmd.Flags().IntVar(&previewCount, "count", 10, "The number of upcoming times to show")
 }
 
-var schedule string
+var previewCount int
@@ -4,14 +4,83 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
 
+	"github.com/ArjenSchwarz/aqua/builder"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/spf13/cobra"
 )
 
+var clients *builder.Clients
+
+// recorder holds the calls that were recorded during a dry run
+var recorder *builder.Recorder
+
+// output is where the results of a command are written. For a dry run with
+// JSON output the results are collected, so they can be combined with the
+// recorded calls into a single document.
+var output io.Writer = os.Stdout
+
+// captureDryRunOutput collects the output of a dry run with JSON output
+func captureDryRunOutput(cmd *cobra.Command, args []string) {
+	if aws.BoolValue(settings.DryRun) && aws.BoolValue(settings.JSONOutput) {
+		output = new(bytes.Buffer)
+	}
+}
+
+// awsClients returns the AWS clients for the configured region, creating
+// them the first time they are needed. For a dry run the clients only
+// record the changes they would make.
+func awsClients() *builder.Clients {
+	if clients == nil {
+		clients = builder.NewClients(settings.Region)
+		if aws.BoolValue(settings.DryRun) {
+			clients, recorder = builder.NewRecordingClients(clients, settings.Region)
+		}
+	}
+	return clients
+}
+
+// printDryRun shows the calls that were recorded during a dry run. With JSON
+// output, the result of the command and the calls are printed together as
+// {"Result": ..., "DryRun": [...]}.
+func printDryRun(cmd *cobra.Command, args []string) {
+	if recorder == nil {
+		if collected, ok := output.(*bytes.Buffer); ok {
+			collected.WriteTo(os.Stdout)
+		}
+		return
+	}
+	buf := new(bytes.Buffer)
+	if !aws.BoolValue(settings.JSONOutput) {
+		fmt.Fprintf(buf, "Dry run, the following %d calls would have been made:\n", len(recorder.Calls))
+		for index, call := range recorder.Calls {
+			fmt.Fprintf(buf, "%d. %s %s\n%s\n", index+1, call.Service, call.Operation, call.Input)
+		}
+	} else {
+		response := struct {
+			Result json.RawMessage `json:",omitempty"`
+			DryRun []builder.Call
+		}{DryRun: recorder.Calls}
+		if collected, ok := output.(*bytes.Buffer); ok && collected.Len() > 0 {
+			response.Result = collected.Bytes()
+			// Commands that print more than a single document are nested as text
+			if !json.Valid(response.Result) {
+				response.Result, _ = json.Marshal(collected.String())
+			}
+		}
+		responseString, _ := json.Marshal(response)
+		buf.Write(responseString)
+	}
+	buf.WriteTo(os.Stdout)
+}
+
 func printSuccess(value string) {
 	if !aws.BoolValue(settings.JSONOutput) {
-		fmt.Println(value)
+		fmt.Fprintln(output, value)
 	} else {
 		buf := new(bytes.Buffer)
 		response := struct {
@@ -20,7 +89,7 @@ func printSuccess(value string) {
 
 		responseString, _ := json.Marshal(response)
 		fmt.Fprintf(buf, "%s", responseString)
-		buf.WriteTo(os.Stdout)
+		buf.WriteTo(output)
 	}
 }
 
@@ -36,7 +105,7 @@ func printSliceMaps(values []map[string]string) {
 		responseString, _ := json.Marshal(values)
 		fmt.Fprintf(buf, "%s", responseString)
 	}
-	buf.WriteTo(os.Stdout)
+	buf.WriteTo(output)
 }
 
 func printMap(values map[string]string) {
@@ -49,7 +118,7 @@ func printMap(values map[string]string) {
 		responseString, _ := json.Marshal(values)
 		fmt.Fprintf(buf, "%s", responseString)
 	}
-	buf.WriteTo(os.Stdout)
+	buf.WriteTo(output)
 }
 
 func printFailure(value string) {
@@ -66,3 +135,98 @@ func printFailure(value string) {
 		buf.WriteTo(os.Stderr)
 	}
 }
+
+var (
+	listLimit   int
+	listFilters []string
+	listSort    string
+)
+
+// addListFlags adds the flags for limiting, filtering, and sorting a list to
+// the command
+func addListFlags(command *cobra.Command, filters string, fields []string) {
+	command.Flags().IntVar(&listLimit, "limit", 0, "The maximum number of items to show")
+	command.Flags().StringArrayVar(&listFilters, "filter", nil, fmt.Sprintf("Only show items matching %s. Can be used multiple times", filters))
+	command.Flags().StringVar(&listSort, "sort", "", fmt.Sprintf("Sort the items by %s. Items are shown as they are found unless they are sorted", strings.Join(fields, ", ")))
+}
+
+// listPrinter prints the items of a list as soon as they are found, so long
+// lists start showing immediately. Items that have to be sorted are collected
+// and printed once the list is done instead.
+type listPrinter struct {
+	sortKey string
+	limit   int
+	items   []map[string]string
+	printed int
+}
+
+// newListPrinter returns a listPrinter for the list flags, together with the
+// options for finding the items, after checking the list supports the filters
+// and can be sorted by the requested field. Sorted lists are limited after
+// sorting.
+func newListPrinter(filters []string, fields []string) (*listPrinter, builder.ListOptions, error) {
+	options, err := builder.ParseListFilters(listFilters, filters, listLimit)
+	if err != nil {
+		return nil, options, err
+	}
+	if listSort != "" {
+		sortable := false
+		for _, field := range fields {
+			sortable = sortable || field == listSort
+		}
+		if !sortable {
+			return nil, options, fmt.Errorf("The list can't be sorted by %s, please use one of %s", listSort, strings.Join(fields, ", "))
+		}
+		options.Limit = 0
+	}
+	return &listPrinter{sortKey: listSort, limit: listLimit}, options, nil
+}
+
+func (printer *listPrinter) add(item map[string]string) {
+	if printer.sortKey != "" {
+		printer.items = append(printer.items, item)
+		return
+	}
+	printer.print(item)
+}
+
+// done prints the collected items, or the message if the list was empty
+func (printer *listPrinter) done(empty string) {
+	sort.SliceStable(printer.items, func(i, j int) bool {
+		return printer.items[i][printer.sortKey] < printer.items[j][printer.sortKey]
+	})
+	for index, item := range printer.items {
+		if printer.limit > 0 && index >= printer.limit {
+			break
+		}
+		printer.print(item)
+	}
+	switch {
+	case printer.printed == 0 && aws.BoolValue(settings.JSONOutput):
+		fmt.Fprint(output, "[]")
+	case printer.printed == 0:
+		printSuccess(empty)
+	case aws.BoolValue(settings.JSONOutput):
+		fmt.Fprint(output, "]")
+	}
+}
+
+// print writes a single item in the same format as printSliceMaps
+func (printer *listPrinter) print(item map[string]string) {
+	buf := new(bytes.Buffer)
+	if !aws.BoolValue(settings.JSONOutput) {
+		for key, value := range item {
+			fmt.Fprintf(buf, "%s: %s\n", key, value)
+		}
+	} else {
+		if printer.printed == 0 {
+			buf.WriteString("[")
+		} else {
+			buf.WriteString(",")
+		}
+		responseString, _ := json.Marshal(item)
+		buf.Write(responseString)
+	}
+	printer.printed++
+	buf.WriteTo(output)
+}
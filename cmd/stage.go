@@ -0,0 +1,209 @@
+// Copyright © 2016 Arjen Schwarz <developer@arjen.eu>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/spf13/cobra"
+
+	"github.com/ArjenSchwarz/aqua/builder"
+)
+
+var (
+	stageDescription string
+	stageVariables   []string
+	stageRateLimit   float64
+	stageBurstLimit  int64
+)
+
+// stageCmd represents the stage command
+var stageCmd = &cobra.Command{
+	Use:   "stage",
+	Short: "Manage the stages of an API",
+	Long: `Create, list, redeploy, and delete the stages of the API Aqua created for the
+function with the provided name, or the API with the provided --api-id.
+
+When creating or redeploying a stage, you can set its description, stage
+variables, and the throttling that applies to every method in the stage.
+
+Example: aqua stage list --name functionName
+
+Example: aqua stage create --name functionName --stage dev --variable lambdaAlias=dev --throttle-rate 10 --throttle-burst 20
+
+Example: aqua stage redeploy --name functionName --stage dev
+
+Example: aqua stage delete --name functionName --stage dev
+`,
+}
+
+// stageListCmd represents the stage list command
+var stageListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the stages of an API",
+	Long: `Lists the stages of an API. Large lists can be limited, filtered, and sorted.
+
+Example: aqua stage list --name functionName --filter name=dev --sort deployment
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		api, err := stageAPI()
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printer, options, err := newListPrinter(stageFilters, stageFields)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		err = builder.EachStage(awsClients(), api.Id, options, func(stage *apigateway.Stage) bool {
+			printer.add(stageValues(api, stage))
+			return true
+		})
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printer.done(fmt.Sprintf("The API %s doesn't have any stages.", aws.StringValue(api.Id)))
+	},
+}
+
+// stageFilters are the filters stages can be listed with
+var stageFilters = []string{"name"}
+
+// stageFields are the fields stages can be sorted by
+var stageFields = []string{"stage", "deployment"}
+
+// stageCreateCmd represents the stage create command
+var stageCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Deploy an API to a new stage",
+	Run: func(cmd *cobra.Command, args []string) {
+		deployStage(builder.CreateStage)
+	},
+}
+
+// stageRedeployCmd represents the stage redeploy command
+var stageRedeployCmd = &cobra.Command{
+	Use:   "redeploy",
+	Short: "Deploy the current state of an API to an existing stage",
+	Run: func(cmd *cobra.Command, args []string) {
+		deployStage(builder.RedeployStage)
+	},
+}
+
+// stageDeleteCmd represents the stage delete command
+var stageDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a stage of an API",
+	Run: func(cmd *cobra.Command, args []string) {
+		api, err := stageAPI()
+		if err == nil {
+			err = requireStage()
+		}
+		if err == nil {
+			err = builder.DeleteStage(awsClients(), api.Id, aws.StringValue(settings.Stage))
+		}
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printSuccess(fmt.Sprintf("Deleted stage %s of API %s", aws.StringValue(settings.Stage), aws.StringValue(api.Id)))
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(stageCmd)
+	stageCmd.AddCommand(stageListCmd)
+	addListFlags(stageListCmd, "name=prefix", stageFields)
+	stageCmd.AddCommand(stageCreateCmd)
+	stageCmd.AddCommand(stageRedeployCmd)
+	stageCmd.AddCommand(stageDeleteCmd)
+	for _, command := range []*cobra.Command{stageCreateCmd, stageRedeployCmd} {
+		command.Flags().StringVar(&stageDescription, "stage-description", "", "The description of the stage")
+		command.Flags().StringArrayVar(&stageVariables, "variable", nil, "A stage variable as KEY=VALUE. Can be used multiple times")
+		command.Flags().Float64Var(&stageRateLimit, "throttle-rate", 0, "The number of requests per second allowed for every method in the stage")
+		command.Flags().Int64Var(&stageBurstLimit, "throttle-burst", 0, "The number of concurrent requests allowed for every method in the stage")
+	}
+}
+
+// deployStage deploys the API to the stage using the provided function, and
+// applies the stage settings from the flags
+func deployStage(deploy func(*builder.Clients, *string, string, builder.StageSettings) (*apigateway.Stage, error)) {
+	api, err := stageAPI()
+	if err == nil {
+		err = requireStage()
+	}
+	if err != nil {
+		printFailure(err.Error())
+		return
+	}
+	variables, err := builder.StageVariables(stageVariables)
+	if err != nil {
+		printFailure(err.Error())
+		return
+	}
+	stage, err := deploy(awsClients(), api.Id, aws.StringValue(settings.Stage), builder.StageSettings{
+		Description: stageDescription,
+		Variables:   variables,
+		RateLimit:   stageRateLimit,
+		BurstLimit:  stageBurstLimit,
+	})
+	if err != nil {
+		printFailure(err.Error())
+		return
+	}
+	printMap(stageValues(api, stage))
+}
+
+// stageAPI returns the API selected by the flags
+func stageAPI() (*apigateway.RestApi, error) {
+	if aws.StringValue(settings.FunctionName) == "" && aws.StringValue(settings.APIID) == "" {
+		return nil, errors.New("Please provide the name of the function using the --name flag, or the API using the --api-id flag")
+	}
+	return builder.FindAPI(awsClients(), settings)
+}
+
+func requireStage() error {
+	if aws.StringValue(settings.Stage) == "" {
+		return errors.New("Please provide the name of the stage using the --stage flag")
+	}
+	return nil
+}
+
+func stageValues(api *apigateway.RestApi, stage *apigateway.Stage) map[string]string {
+	values := map[string]string{
+		"stage":       aws.StringValue(stage.StageName),
+		"url":         builder.StageURL(aws.StringValue(api.Id), aws.StringValue(settings.Region), aws.StringValue(stage.StageName)),
+		"deployment":  aws.StringValue(stage.DeploymentId),
+		"description": aws.StringValue(stage.Description),
+	}
+	for key, value := range stage.Variables {
+		values["variable_"+key] = aws.StringValue(value)
+	}
+	if throttling, ok := stage.MethodSettings["*/*"]; ok {
+		values["throttle_rate"] = fmt.Sprintf("%g", aws.Float64Value(throttling.ThrottlingRateLimit))
+		values["throttle_burst"] = fmt.Sprintf("%d", aws.Int64Value(throttling.ThrottlingBurstLimit))
+	}
+	return values
+}
@@ -0,0 +1,233 @@
+// Copyright © 2016 Arjen Schwarz <developer@arjen.eu>
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/apigateway"
+	"github.com/spf13/cobra"
+
+	"github.com/ArjenSchwarz/aqua/builder"
+)
+
+var (
+	planName        string
+	planDescription string
+	planKey         string
+	planSettings    builder.UsagePlanSettings
+)
+
+// usageplanCmd represents the usageplan command
+var usageplanCmd = &cobra.Command{
+	Use:   "usageplan",
+	Short: "Manage usage plans for API keys",
+	Long: `Usage plans set the throttling and quota for the API keys that are added to
+them, and determine which stages of which APIs those keys can be used for.
+
+The stage of an API is selected with --name (for the API Aqua created for the
+function) or --api-id, and --stage (defaults to prod).
+
+Example: aqua usageplan create --plan basic --name functionName --rate 10 --burst 20 --quota 10000 --quota-period MONTH
+
+Example: aqua usageplan list
+
+Example: aqua usageplan attach --plan basic --key keyID
+
+Example: aqua usageplan attach --plan basic --name otherFunction --stage dev
+
+Example: aqua usageplan detach --plan basic --key keyID
+`,
+}
+
+// usageplanCreateCmd represents the usageplan create command
+var usageplanCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a usage plan",
+	Run: func(cmd *cobra.Command, args []string) {
+		if planName == "" {
+			printFailure("Please provide the name of the usage plan using the --plan flag")
+			return
+		}
+		var stages []*apigateway.ApiStage
+		if planSelectsStage() {
+			api, err := stageAPI()
+			if err != nil {
+				printFailure(err.Error())
+				return
+			}
+			stages = append(stages, &apigateway.ApiStage{ApiId: api.Id, Stage: aws.String(planStage())})
+		}
+		plan, err := builder.CreateUsagePlan(awsClients(), planName, planDescription, planSettings, stages)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printMap(planValues(plan))
+	},
+}
+
+// usageplanListCmd represents the usageplan list command
+var usageplanListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the usage plans",
+	Long: `Lists the usage plans in the region. Large lists can be limited, filtered,
+and sorted.
+
+Example: aqua usageplan list --filter name=basic --sort name --limit 10
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		printer, options, err := newListPrinter(planFilters, planFields)
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		err = builder.EachUsagePlan(awsClients(), options, func(plan *apigateway.UsagePlan) bool {
+			printer.add(planValues(plan))
+			return true
+		})
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		printer.done("No usage plans have been found.")
+	},
+}
+
+// planFilters are the filters usage plans can be listed with
+var planFilters = []string{"name"}
+
+// planFields are the fields usage plans can be sorted by
+var planFields = []string{"name", "id"}
+
+// usageplanAttachCmd represents the usageplan attach command
+var usageplanAttachCmd = &cobra.Command{
+	Use:   "attach",
+	Short: "Add an API key or the stage of an API to a usage plan",
+	Run: func(cmd *cobra.Command, args []string) {
+		changePlan(builder.AttachAPIKey, builder.AttachStage, "Attached")
+	},
+}
+
+// usageplanDetachCmd represents the usageplan detach command
+var usageplanDetachCmd = &cobra.Command{
+	Use:   "detach",
+	Short: "Remove an API key or the stage of an API from a usage plan",
+	Run: func(cmd *cobra.Command, args []string) {
+		changePlan(builder.DetachAPIKey, builder.DetachStage, "Detached")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(usageplanCmd)
+	usageplanCmd.AddCommand(usageplanCreateCmd)
+	usageplanCmd.AddCommand(usageplanListCmd)
+	addListFlags(usageplanListCmd, "name=prefix", planFields)
+	usageplanCmd.AddCommand(usageplanAttachCmd)
+	usageplanCmd.AddCommand(usageplanDetachCmd)
+	for _, command := range []*cobra.Command{usageplanCreateCmd, usageplanAttachCmd, usageplanDetachCmd} {
+		command.Flags().StringVar(&planName, "plan", "", "The name or ID of the usage plan")
+	}
+	usageplanCreateCmd.Flags().StringVar(&planDescription, "plan-description", "", "The description of the usage plan")
+	addPlanFlags(usageplanCreateCmd)
+	for _, command := range []*cobra.Command{usageplanAttachCmd, usageplanDetachCmd} {
+		command.Flags().StringVar(&planKey, "key", "", "The ID of the API key")
+	}
+}
+
+// addPlanFlags adds the flags for the throttling and quota of a usage plan
+func addPlanFlags(command *cobra.Command) {
+	command.Flags().Float64Var(&planSettings.RateLimit, "rate", 0, "The number of requests per second allowed for each API key")
+	command.Flags().Int64Var(&planSettings.BurstLimit, "burst", 0, "The number of concurrent requests allowed for each API key")
+	command.Flags().Int64Var(&planSettings.QuotaLimit, "quota", 0, "The number of requests allowed for each API key per quota period")
+	command.Flags().StringVar(&planSettings.QuotaPeriod, "quota-period", "MONTH", fmt.Sprintf("The period the quota applies to: %s", strings.Join(builder.SupportedQuotaPeriods, ", ")))
+}
+
+// changePlan adds or removes the API key and the stage selected by the flags
+func changePlan(changeKey func(*builder.Clients, *string, string) error, changeStage func(*builder.Clients, *string, *string, string) error, action string) {
+	if planName == "" {
+		printFailure("Please provide the usage plan using the --plan flag")
+		return
+	}
+	if planKey == "" && !planSelectsStage() {
+		printFailure("Please provide an API key using the --key flag, or an API using the --name or --api-id flag")
+		return
+	}
+	plan, err := builder.FindUsagePlan(awsClients(), planName)
+	if err != nil {
+		printFailure(err.Error())
+		return
+	}
+	var changes []string
+	if planKey != "" {
+		if err = changeKey(awsClients(), plan.Id, planKey); err != nil {
+			printFailure(err.Error())
+			return
+		}
+		changes = append(changes, fmt.Sprintf("API key %s", planKey))
+	}
+	if planSelectsStage() {
+		api, err := stageAPI()
+		if err == nil {
+			err = changeStage(awsClients(), plan.Id, api.Id, planStage())
+		}
+		if err != nil {
+			printFailure(err.Error())
+			return
+		}
+		changes = append(changes, fmt.Sprintf("stage %s of API %s", planStage(), aws.StringValue(api.Id)))
+	}
+	printSuccess(fmt.Sprintf("%s %s for usage plan %s", action, strings.Join(changes, " and "), aws.StringValue(plan.Name)))
+}
+
+// planSelectsStage checks if the flags select the stage of an API
+func planSelectsStage() bool {
+	return aws.StringValue(settings.FunctionName) != "" || aws.StringValue(settings.APIID) != ""
+}
+
+func planStage() string {
+	if stage := aws.StringValue(settings.Stage); stage != "" {
+		return stage
+	}
+	return builder.DefaultStage
+}
+
+func planValues(plan *apigateway.UsagePlan) map[string]string {
+	values := map[string]string{
+		"id":          aws.StringValue(plan.Id),
+		"name":        aws.StringValue(plan.Name),
+		"description": aws.StringValue(plan.Description),
+	}
+	var stages []string
+	for _, stage := range plan.ApiStages {
+		stages = append(stages, fmt.Sprintf("%s:%s", aws.StringValue(stage.ApiId), aws.StringValue(stage.Stage)))
+	}
+	values["stages"] = strings.Join(stages, ", ")
+	if plan.Throttle != nil {
+		values["rate"] = fmt.Sprintf("%g", aws.Float64Value(plan.Throttle.RateLimit))
+		values["burst"] = fmt.Sprintf("%d", aws.Int64Value(plan.Throttle.BurstLimit))
+	}
+	if plan.Quota != nil {
+		values["quota"] = fmt.Sprintf("%d per %s", aws.Int64Value(plan.Quota.Limit), aws.StringValue(plan.Quota.Period))
+	}
+	return values
+}